@@ -0,0 +1,384 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"chivomap.com/spatial"
+	"chivomap.com/types"
+	"chivomap.com/utils"
+)
+
+// dissolvedLevels son los niveles que se construyen uniendo distritos, con las
+// propiedades que identifican a cada unidad
+var dissolvedLevels = []struct {
+	nivel string
+	keys  []string
+}{
+	{types.NivelDepartamento, []string{"D"}},
+	{types.NivelMunicipio, []string{"D", "M"}},
+}
+
+// levelData contiene las geometrías disueltas de un nivel y lo derivado de ellas.
+// Las geometrías referencian los arcs de topo.json, por lo que se simplifican y
+// exportan a TopoJSON igual que los distritos.
+type levelData struct {
+	object  types.TopoObject
+	geo     *types.GeoFeatureCollection
+	index   *spatial.FeatureIndex
+	metrics []types.FeatureMetrics // se calculan al pedirse, una sola vez
+
+	metricsOnce sync.Once
+}
+
+// dissolveGroup acumula los anillos de los distritos de una unidad
+type dissolveGroup struct {
+	id         string
+	properties map[string]interface{}
+	rings      [][]int
+}
+
+// buildLevels construye los departamentos y municipios uniendo los polígonos de
+// sus distritos. Los features de geo deben seguir el orden de las geometrías
+// del TopoJSON; los que no tienen geometría válida se omiten.
+func (s *StaticFileCache) buildLevels(topo *types.TopoJSON, geo *types.GeoFeatureCollection) map[string]*levelData {
+	start := time.Now()
+	geometries := objectGeometries(topo.Objects["collection"])
+
+	levels := make(map[string]*levelData, len(dissolvedLevels))
+	for _, level := range dissolvedLevels {
+		object := s.dissolve(topo, geometries, geo.Features, level.nivel, level.keys)
+		features, _ := s.convertObject(object, topo)
+		collection := &types.GeoFeatureCollection{Type: "FeatureCollection", Features: features}
+		levels[level.nivel] = &levelData{
+			object: object,
+			geo:    collection,
+			index:  spatial.NewFeatureIndex(collection),
+		}
+	}
+	utils.Info("%d departamentos y %d municipios disueltos en %s",
+		len(levels[types.NivelDepartamento].geo.Features), len(levels[types.NivelMunicipio].geo.Features), time.Since(start))
+	return levels
+}
+
+// dissolve agrupa las geometrías por las propiedades indicadas y une los
+// polígonos de cada grupo: los arcs que aparecen una sola vez en el grupo son su
+// borde y los que aparecen dos veces (compartidos entre distritos del mismo
+// grupo) se descartan. Retorna una GeometryCollection de MultiPolygon ordenada
+// por ID; los IDs son los de la jerarquía administrativa.
+func (s *StaticFileCache) dissolve(topo *types.TopoJSON, geometries []types.Geometry, features []types.GeoFeature, nivel string, keys []string) types.TopoObject {
+	groups := make(map[string]*dissolveGroup)
+	for i, geom := range geometries {
+		if i >= len(features) || features[i].Geometry == nil {
+			continue
+		}
+
+		values := make([]string, len(keys))
+		for k, key := range keys {
+			values[k], _ = geom.Properties[key].(string)
+			if strings.TrimSpace(values[k]) == "" {
+				values = nil
+				break
+			}
+		}
+		if values == nil {
+			continue
+		}
+
+		id := utils.HierarchicalID(values...)
+		group, ok := groups[id]
+		if !ok {
+			props := make(map[string]interface{}, len(keys)+3)
+			for k, key := range keys {
+				props[key] = values[k]
+			}
+			props["slug"] = utils.Slugify(values[len(values)-1])
+			props["nivel"] = nivel
+			props["distritos"] = 0
+			group = &dissolveGroup{id: id, properties: props}
+			groups[id] = group
+		}
+		group.rings = append(group.rings, polygonRings(geom)...)
+		group.properties["distritos"] = group.properties["distritos"].(int) + 1
+	}
+
+	ids := make([]string, 0, len(groups))
+	for id := range groups {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	object := types.TopoObject{Type: "GeometryCollection", Geometries: make([]types.Geometry, 0, len(ids))}
+	for _, id := range ids {
+		group := groups[id]
+		rings, open := s.stitchRings(topo, group.rings)
+		if open > 0 {
+			utils.Error("%s %s: %d bordes sin cerrar al unir sus distritos", nivel, id, open)
+		}
+		arcs, err := json.Marshal(s.classifyRings(topo, rings))
+		if err != nil {
+			utils.Error("%s %s: %v", nivel, id, err)
+			continue
+		}
+		object.Geometries = append(object.Geometries, types.Geometry{
+			Type:       "MultiPolygon",
+			ID:         id,
+			Arcs:       arcs,
+			Properties: group.properties,
+		})
+	}
+	return object
+}
+
+// objectGeometries retorna las geometrías de un objeto de la topología: las de
+// una GeometryCollection o la geometría suelta
+func objectGeometries(object types.TopoObject) []types.Geometry {
+	if object.Type == "GeometryCollection" {
+		return object.Geometries
+	}
+	return []types.Geometry{{
+		Type:        object.Type,
+		ID:          object.ID,
+		Arcs:        object.Arcs,
+		Coordinates: object.Coordinates,
+		Properties:  object.Properties,
+	}}
+}
+
+// polygonRings retorna los anillos (como índices de arcs) de un Polygon o
+// MultiPolygon; otros tipos no aportan anillos
+func polygonRings(geom types.Geometry) [][]int {
+	switch geom.Type {
+	case "Polygon":
+		var rings [][]int
+		if json.Unmarshal(geom.Arcs, &rings) == nil {
+			return rings
+		}
+	case "MultiPolygon":
+		var polygons [][][]int
+		if json.Unmarshal(geom.Arcs, &polygons) == nil {
+			var rings [][]int
+			for _, polygon := range polygons {
+				rings = append(rings, polygon...)
+			}
+			return rings
+		}
+	}
+	return nil
+}
+
+// stitchRings descarta los arcs que los anillos usan más de una vez y encadena
+// los restantes por sus extremos en anillos cerrados. Cuando una cadena vuelve
+// a pasar por un vértice suyo (dos partes que se tocan en un punto), el tramo
+// recorrido desde ese vértice se separa como anillo propio, así ningún anillo
+// se toca a sí mismo. Retorna los anillos y la cantidad de cadenas que no se
+// pudieron cerrar.
+func (s *StaticFileCache) stitchRings(topo *types.TopoJSON, rings [][]int) ([][]int, int) {
+	uses := make(map[int]int)
+	for _, ring := range rings {
+		for _, ref := range ring {
+			uses[arcIndex(ref)]++
+		}
+	}
+
+	type segment struct {
+		ref        int
+		start, end [2]float64
+	}
+	var segments []segment
+	starts := make(map[[2]float64][]int)
+	for _, ring := range rings {
+		for _, ref := range ring {
+			if uses[arcIndex(ref)] != 1 {
+				continue
+			}
+			start, end, ok := arcEnds(topo, ref)
+			if !ok {
+				continue
+			}
+			starts[start] = append(starts[start], len(segments))
+			segments = append(segments, segment{ref: ref, start: start, end: end})
+		}
+	}
+
+	used := make([]bool, len(segments))
+	var stitched [][]int
+	open := 0
+	for i, first := range segments {
+		if used[i] {
+			continue
+		}
+		used[i] = true
+		ring := []int{first.ref}
+		vertices := [][2]float64{first.start}    // vértice inicial de cada arc de ring
+		at := map[[2]float64]int{first.start: 0} // posición de cada vértice en ring
+		end := first.end
+		for {
+			if k, seen := at[end]; seen {
+				// La cadena volvió a uno de sus vértices: lo recorrido desde ahí es un anillo cerrado
+				stitched = append(stitched, append([]int(nil), ring[k:]...))
+				for _, vertex := range vertices[k+1:] {
+					delete(at, vertex)
+				}
+				ring, vertices = ring[:k], vertices[:k+1]
+				if k == 0 {
+					break
+				}
+			} else {
+				at[end] = len(ring)
+				vertices = append(vertices, end)
+			}
+
+			next := -1
+			for _, j := range starts[end] {
+				if !used[j] {
+					next = j
+					break
+				}
+			}
+			if next < 0 {
+				open++
+				break
+			}
+			used[next] = true
+			ring = append(ring, segments[next].ref)
+			end = segments[next].end
+		}
+	}
+	return stitched, open
+}
+
+// classifyRings arma los polígonos de un MultiPolygon según la profundidad de
+// cada anillo: cuántos otros anillos lo contienen. Los de profundidad par son
+// exteriores y los de profundidad impar son huecos del anillo más pequeño que
+// los contiene. No se usa la orientación porque al unir los anillos se sigue
+// el sentido de los arcs de cada distrito, que no siempre es consistente; los
+// anillos se reorientan como pide RFC 7946 (exteriores antihorarios y huecos
+// horarios). La contención se prueba con un punto estrictamente interior de
+// cada anillo, porque sus vértices pueden estar sobre el borde de otro que lo
+// toca.
+func (s *StaticFileCache) classifyRings(topo *types.TopoJSON, rings [][]int) [][][]int {
+	coords := make([][][]float64, len(rings))
+	areas := make([]float64, len(rings))
+	for i, ring := range rings {
+		coords[i], _ = s.processArcRing(ring, topo)
+		areas[i] = spatial.RingSignedArea(coords[i])
+	}
+
+	depths := make([]int, len(rings))
+	parents := make([]int, len(rings)) // anillo más pequeño que contiene a cada uno
+	for i := range rings {
+		parents[i] = -1
+		if len(coords[i]) == 0 {
+			continue
+		}
+		lon, lat := spatial.PoleOfInaccessibility([][][][]float64{{coords[i]}})
+		for j := range rings {
+			if j == i || math.Abs(areas[j]) <= math.Abs(areas[i]) {
+				continue
+			}
+			if !spatial.PolygonsContain([][][][]float64{{coords[j]}}, lon, lat) {
+				continue
+			}
+			depths[i]++
+			if parents[i] < 0 || math.Abs(areas[j]) < math.Abs(areas[parents[i]]) {
+				parents[i] = j
+			}
+		}
+	}
+
+	var polygons [][][]int
+	polygonOf := make(map[int]int) // anillo exterior -> posición en polygons
+	for i := range rings {
+		if depths[i]%2 == 0 {
+			polygonOf[i] = len(polygons)
+			polygons = append(polygons, [][]int{orientRing(rings[i], areas[i], true)})
+		}
+	}
+	for i := range rings {
+		if depths[i]%2 == 0 {
+			continue
+		}
+		polygon, ok := polygonOf[parents[i]]
+		if !ok {
+			// Anillos superpuestos sin anidarse: se conserva como polígono propio
+			polygons = append(polygons, [][]int{orientRing(rings[i], areas[i], true)})
+			continue
+		}
+		polygons[polygon] = append(polygons[polygon], orientRing(rings[i], areas[i], false))
+	}
+	return polygons
+}
+
+// orientRing retorna el anillo en sentido antihorario (ccw) u horario. Para
+// invertir un anillo se recorren sus arcs al revés, cada uno invertido.
+func orientRing(ring []int, area float64, ccw bool) []int {
+	if area == 0 || (area > 0) == ccw {
+		return ring
+	}
+	reversed := make([]int, len(ring))
+	for i, ref := range ring {
+		reversed[len(ring)-1-i] = ^ref
+	}
+	return reversed
+}
+
+// arcIndex retorna el índice del arc referenciado (los negativos van invertidos)
+func arcIndex(ref int) int {
+	if ref < 0 {
+		return ^ref
+	}
+	return ref
+}
+
+// arcEnds retorna las posiciones inicial y final del arc referenciado en el
+// sentido en que se recorre. Con transform se usan las posiciones cuantizadas,
+// que coinciden exactamente entre arcs vecinos.
+func arcEnds(topo *types.TopoJSON, ref int) ([2]float64, [2]float64, bool) {
+	index := arcIndex(ref)
+	if index >= len(topo.Arcs) || len(topo.Arcs[index]) == 0 {
+		return [2]float64{}, [2]float64{}, false
+	}
+	arc := topo.Arcs[index]
+
+	var first, last [2]float64
+	if len(topo.Transform.Scale) >= 2 && len(topo.Transform.Translate) >= 2 {
+		for i, delta := range arc {
+			if len(delta) < 2 {
+				return first, last, false
+			}
+			last[0] += delta[0]
+			last[1] += delta[1]
+			if i == 0 {
+				first = last
+			}
+		}
+	} else {
+		if len(arc[0]) < 2 || len(arc[len(arc)-1]) < 2 {
+			return first, last, false
+		}
+		first = [2]float64{arc[0][0], arc[0][1]}
+		last = [2]float64{arc[len(arc)-1][0], arc[len(arc)-1][1]}
+	}
+
+	if ref < 0 {
+		return last, first, true
+	}
+	return first, last, true
+}
+
+// levelName valida el nivel pedido; "" equivale a distrito
+func levelName(nivel string) (string, error) {
+	switch nivel {
+	case "", types.NivelDistrito:
+		return types.NivelDistrito, nil
+	case types.NivelDepartamento, types.NivelMunicipio:
+		return nivel, nil
+	}
+	return "", fmt.Errorf("nivel inválido '%s': debe ser departamento, municipio o distrito", nivel)
+}
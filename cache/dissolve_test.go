@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"testing"
+
+	"chivomap.com/spatial"
+	"chivomap.com/types"
+)
+
+// dissolveRings une los anillos como dissolve: descarta los arcs compartidos,
+// une los demás y arma los polígonos
+func dissolveRings(t *testing.T, s *StaticFileCache, topo *types.TopoJSON, rings [][]int) [][][]int {
+	t.Helper()
+
+	stitched, open := s.stitchRings(topo, rings)
+	if open != 0 {
+		t.Fatalf("%d rings left open", open)
+	}
+	return s.classifyRings(topo, stitched)
+}
+
+// ringArea retorna el área con signo del anillo formado por los arcs
+func ringArea(s *StaticFileCache, topo *types.TopoJSON, ring []int) float64 {
+	coords, _ := s.processArcRing(ring, topo)
+	return spatial.RingSignedArea(coords)
+}
+
+// square retorna un anillo cerrado en un solo arc, antihorario si ccw
+func square(minX, minY, maxX, maxY float64, ccw bool) [][]float64 {
+	ring := [][]float64{{minX, minY}, {maxX, minY}, {maxX, maxY}, {minX, maxY}, {minX, minY}}
+	if !ccw {
+		for i, j := 0, len(ring)-1; i < j; i, j = i+1, j-1 {
+			ring[i], ring[j] = ring[j], ring[i]
+		}
+	}
+	return ring
+}
+
+func TestDissolveSharedEdge(t *testing.T) {
+	s := &StaticFileCache{}
+	topo := &types.TopoJSON{Arcs: [][][]float64{
+		{{1, 0}, {1, 1}},                 // borde compartido
+		{{1, 1}, {0, 1}, {0, 0}, {1, 0}}, // resto del cuadrado izquierdo
+		{{1, 0}, {2, 0}, {2, 1}, {1, 1}}, // resto del cuadrado derecho
+	}}
+
+	polygons := dissolveRings(t, s, topo, [][]int{{1, 0}, {2, ^0}})
+	if len(polygons) != 1 || len(polygons[0]) != 1 {
+		t.Fatalf("got %v, want one polygon with one ring", polygons)
+	}
+	ring := polygons[0][0]
+	for _, ref := range ring {
+		if arcIndex(ref) == 0 {
+			t.Errorf("shared arc kept in dissolved ring %v", ring)
+		}
+	}
+	if area := ringArea(s, topo, ring); area != 2 {
+		t.Errorf("dissolved area = %g, want 2", area)
+	}
+}
+
+func TestDissolvePinchedFigureEight(t *testing.T) {
+	s := &StaticFileCache{}
+	topo := &types.TopoJSON{Arcs: [][][]float64{
+		{{0, 0}, {1, 0}, {1, 1}},
+		{{1, 1}, {2, 1}, {2, 2}},
+		{{2, 2}, {1, 2}, {1, 1}},
+		{{1, 1}, {0, 1}, {0, 0}},
+	}}
+
+	// Un solo anillo que pasa dos veces por (1, 1)
+	polygons := dissolveRings(t, s, topo, [][]int{{0, 1, 2, 3}})
+	if len(polygons) != 2 {
+		t.Fatalf("got %d polygons, want 2: %v", len(polygons), polygons)
+	}
+	for _, polygon := range polygons {
+		if len(polygon) != 1 {
+			t.Errorf("polygon %v has holes", polygon)
+		}
+		coords, _ := s.processArcRing(polygon[0], topo)
+		if _, touches := spatial.RingSelfIntersection(coords); touches {
+			t.Errorf("ring %v touches itself", polygon[0])
+		}
+		if area := ringArea(s, topo, polygon[0]); area != 1 {
+			t.Errorf("ring %v area = %g, want 1", polygon[0], area)
+		}
+	}
+}
+
+func TestDissolveHolesByDepth(t *testing.T) {
+	s := &StaticFileCache{}
+	// Orientaciones inconsistentes, como en algunos distritos de topo.json:
+	// el hueco gira como el exterior y las islas al revés
+	topo := &types.TopoJSON{Arcs: [][][]float64{
+		square(0, 0, 10, 10, true),    // exterior
+		square(2, 2, 8, 8, true),      // hueco
+		square(4, 4, 6, 6, false),     // isla dentro del hueco
+		square(20, 20, 22, 22, false), // isla separada
+	}}
+
+	polygons := dissolveRings(t, s, topo, [][]int{{0}, {1}, {2}, {3}})
+	if len(polygons) != 3 {
+		t.Fatalf("got %d polygons, want 3: %v", len(polygons), polygons)
+	}
+
+	holes := 0
+	for _, polygon := range polygons {
+		if area := ringArea(s, topo, polygon[0]); area <= 0 {
+			t.Errorf("exterior %v is not counter-clockwise (area %g)", polygon[0], area)
+		}
+		for _, hole := range polygon[1:] {
+			holes++
+			if arcIndex(hole[0]) != 1 || arcIndex(polygon[0][0]) != 0 {
+				t.Errorf("ring %v is a hole of %v, want arc 1 in arc 0", hole, polygon[0])
+			}
+			if area := ringArea(s, topo, hole); area >= 0 {
+				t.Errorf("hole %v is not clockwise (area %g)", hole, area)
+			}
+		}
+	}
+	if holes != 1 {
+		t.Errorf("got %d holes, want 1", holes)
+	}
+}
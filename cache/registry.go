@@ -0,0 +1,456 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"chivomap.com/spatial"
+	"chivomap.com/types"
+	"chivomap.com/utils"
+)
+
+// datasetExtensions son las extensiones de archivo que se cargan como capas
+var datasetExtensions = map[string]bool{".json": true, ".topojson": true, ".geojson": true}
+
+// DatasetRegistry carga como capas todos los archivos TopoJSON y GeoJSON de
+// ASSETS_DIR: cada objeto de una topología es una capa ("rios.principales") y
+// cada archivo GeoJSON es una capa con el nombre del archivo ("volcanes").
+// topo.json se toma del StaticFileCache para no decodificarlo dos veces.
+type DatasetRegistry struct {
+	dir     string
+	primary *StaticFileCache
+
+	scanMu sync.Mutex              // serializa los escaneos
+	files  map[string]*datasetFile // por ruta; solo lo usa Scan
+
+	mu       sync.RWMutex
+	datasets map[string]*registeredDataset // nil hasta el primer escaneo
+}
+
+// datasetFile recuerda el estado de un archivo entre escaneos
+type datasetFile struct {
+	modTime  time.Time
+	size     int64
+	version  string
+	err      error // el último intento falló; se conservan las capas anteriores
+	datasets []*registeredDataset
+}
+
+// registeredDataset es una capa con sus features indexados por rectángulo
+type registeredDataset struct {
+	info  types.DatasetInfo
+	geo   *types.GeoFeatureCollection
+	index *spatial.Index
+}
+
+// NewDatasetRegistry crea el registro de capas de assetsDir. primary es el
+// cache de topo.json, cuyo objeto "collection" se reutiliza tal cual.
+func NewDatasetRegistry(assetsDir string, primary *StaticFileCache) *DatasetRegistry {
+	return &DatasetRegistry{
+		dir:     assetsDir,
+		primary: primary,
+		files:   make(map[string]*datasetFile),
+	}
+}
+
+// Datasets retorna la descripción de todas las capas, ordenadas por nombre
+func (r *DatasetRegistry) Datasets() []types.DatasetInfo {
+	r.ensureScanned()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	infos := make([]types.DatasetInfo, 0, len(r.datasets))
+	for _, dataset := range r.datasets {
+		infos = append(infos, dataset.info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Nombre < infos[j].Nombre })
+	return infos
+}
+
+// Dataset retorna los features de una capa y su índice por rectángulo (los IDs
+// del índice son posiciones en la colección)
+func (r *DatasetRegistry) Dataset(name string) (*types.GeoFeatureCollection, *spatial.Index, bool) {
+	r.ensureScanned()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	dataset, ok := r.datasets[name]
+	if !ok {
+		return nil, nil, false
+	}
+	return dataset.geo, dataset.index, true
+}
+
+// ensureScanned escanea el directorio la primera vez que se consulta el registro
+func (r *DatasetRegistry) ensureScanned() {
+	r.mu.RLock()
+	scanned := r.datasets != nil
+	r.mu.RUnlock()
+
+	if !scanned {
+		if err := r.Scan(); err != nil {
+			utils.Error("Error escaneando datasets: %v", err)
+		}
+	}
+}
+
+// Watch vuelve a escanear el directorio cada WatchInterval, hasta que ctx se
+// cancele, para agregar, actualizar o quitar capas sin reiniciar
+func (r *DatasetRegistry) Watch(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(WatchInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := r.Scan(); err != nil {
+					utils.Debug("Escaneo de datasets omitido: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Scan carga los archivos nuevos o modificados de ASSETS_DIR y quita las capas
+// de los archivos que ya no existen. Si un archivo modificado no se puede
+// cargar, se siguen sirviendo sus capas anteriores.
+func (r *DatasetRegistry) Scan() error {
+	r.scanMu.Lock()
+	defer r.scanMu.Unlock()
+
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return fmt.Errorf("error leyendo directorio de datasets %s: %w", r.dir, err)
+	}
+
+	r.mu.RLock()
+	changed := r.datasets == nil
+	r.mu.RUnlock()
+
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !datasetExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			continue
+		}
+		path := filepath.Join(r.dir, entry.Name())
+		fileInfo, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		seen[path] = true
+
+		if r.scanFile(path, fileInfo) {
+			changed = true
+		}
+	}
+
+	for path := range r.files {
+		if !seen[path] {
+			utils.Info("Archivo de datasets eliminado: %s", path)
+			delete(r.files, path)
+			changed = true
+		}
+	}
+
+	if changed {
+		r.rebuild()
+	}
+	return nil
+}
+
+// scanFile carga un archivo si cambió desde el último escaneo. Retorna si sus
+// capas cambiaron.
+func (r *DatasetRegistry) scanFile(path string, fileInfo os.FileInfo) bool {
+	previous := r.files[path]
+
+	if path == r.primary.filePath {
+		// topo.json lo recarga el StaticFileCache; aquí solo se sigue su versión
+		if _, err := r.primary.LoadTopoJSON(); err != nil {
+			return false
+		}
+		if previous != nil && previous.version == r.primary.Version() {
+			return false
+		}
+	} else if previous != nil && previous.modTime.Equal(fileInfo.ModTime()) && previous.size == fileInfo.Size() {
+		return false
+	}
+
+	datasets, version, err := r.loadFile(path)
+	if err != nil {
+		utils.Error("Error cargando datasets de %s: %v", path, err)
+		file := &datasetFile{modTime: fileInfo.ModTime(), size: fileInfo.Size(), err: err}
+		if previous != nil {
+			file.version, file.datasets = previous.version, previous.datasets
+		}
+		r.files[path] = file
+		return false
+	}
+
+	r.files[path] = &datasetFile{
+		modTime:  fileInfo.ModTime(),
+		size:     fileInfo.Size(),
+		version:  version,
+		datasets: datasets,
+	}
+	utils.Info("Datasets cargados de %s: %d capas (versión %s)", filepath.Base(path), len(datasets), version)
+	return true
+}
+
+// rebuild reemplaza el mapa de capas con las de todos los archivos. Si dos
+// archivos generan el mismo nombre, se conserva la del primero en orden alfabético.
+func (r *DatasetRegistry) rebuild() {
+	paths := make([]string, 0, len(r.files))
+	for path := range r.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	datasets := make(map[string]*registeredDataset)
+	for _, path := range paths {
+		for _, dataset := range r.files[path].datasets {
+			if existing, ok := datasets[dataset.info.Nombre]; ok {
+				utils.Error("Dataset %q de %s omitido: el nombre ya lo usa %s",
+					dataset.info.Nombre, dataset.info.Archivo, existing.info.Archivo)
+				continue
+			}
+			datasets[dataset.info.Nombre] = dataset
+		}
+	}
+
+	r.mu.Lock()
+	r.datasets = datasets
+	r.mu.Unlock()
+}
+
+// loadFile carga las capas de un archivo TopoJSON o GeoJSON y retorna su versión
+func (r *DatasetRegistry) loadFile(path string) ([]*registeredDataset, string, error) {
+	fileName := filepath.Base(path)
+	stem := utils.Slugify(strings.TrimSuffix(fileName, filepath.Ext(fileName)))
+	if stem == "" {
+		return nil, "", fmt.Errorf("el nombre del archivo no genera un nombre de capa válido")
+	}
+
+	if path == r.primary.filePath {
+		topo, err := r.primary.LoadTopoJSON()
+		if err != nil {
+			return nil, "", err
+		}
+		geo, err := r.primary.GetGeoData()
+		if err != nil {
+			return nil, "", err
+		}
+		version := r.primary.Version()
+		return r.topologyDatasets(stem, fileName, topo, geo, version), version, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("error leyendo archivo: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	version := hex.EncodeToString(sum[:])[:12]
+
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, "", fmt.Errorf("JSON inválido: %w", err)
+	}
+
+	if header.Type == "Topology" {
+		var topo types.TopoJSON
+		if err := json.Unmarshal(data, &topo); err != nil {
+			return nil, "", fmt.Errorf("error deserializando TopoJSON: %w", err)
+		}
+		if len(topo.Objects) == 0 {
+			return nil, "", fmt.Errorf("TopoJSON inválido: no tiene objects")
+		}
+		return r.topologyDatasets(stem, fileName, &topo, nil, version), version, nil
+	}
+
+	features, err := decodeGeoJSON(data)
+	if err != nil {
+		return nil, "", err
+	}
+	assignDatasetIDs(features)
+	markInvalid(features)
+	info := types.DatasetInfo{Nombre: stem, Archivo: fileName, Formato: "geojson", Version: version}
+	return []*registeredDataset{newRegisteredDataset(info, features)}, version, nil
+}
+
+// topologyDatasets crea una capa por cada objeto de la topología, nombrada
+// archivo.objeto. primaryGeo, si no es nil, son los features ya convertidos del
+// objeto "collection".
+func (r *DatasetRegistry) topologyDatasets(stem, fileName string, topo *types.TopoJSON, primaryGeo *types.GeoFeatureCollection, version string) []*registeredDataset {
+	objects := make([]string, 0, len(topo.Objects))
+	for name := range topo.Objects {
+		objects = append(objects, name)
+	}
+	sort.Strings(objects)
+
+	datasets := make([]*registeredDataset, 0, len(objects))
+	for _, object := range objects {
+		var features []types.GeoFeature
+		if primaryGeo != nil && object == "collection" {
+			features = primaryGeo.Features
+		} else {
+			features, _ = r.primary.convertObject(topo.Objects[object], topo)
+			assignDatasetIDs(features)
+			markInvalid(features)
+		}
+
+		info := types.DatasetInfo{
+			Nombre:  stem + "." + utils.Slugify(object),
+			Archivo: fileName,
+			Objeto:  object,
+			Formato: "topojson",
+			Version: version,
+		}
+		datasets = append(datasets, newRegisteredDataset(info, features))
+	}
+	return datasets
+}
+
+// newRegisteredDataset completa la descripción de la capa e indexa sus features
+func newRegisteredDataset(info types.DatasetInfo, features []types.GeoFeature) *registeredDataset {
+	geometryTypes := make(map[string]bool)
+	properties := make(map[string]bool)
+	bounds := spatial.EmptyBBox()
+	items := make([]spatial.Item, 0, len(features))
+
+	for i, feature := range features {
+		for key := range feature.Properties {
+			properties[key] = true
+		}
+		if feature.Geometry == nil {
+			info.FeaturesInvalidos++
+			continue
+		}
+		if geomMap, ok := feature.Geometry.(map[string]interface{}); ok {
+			if geomType, ok := geomMap["type"].(string); ok {
+				geometryTypes[geomType] = true
+			}
+		}
+		if featureBounds := spatial.GeometryBounds(feature.Geometry); !featureBounds.IsEmpty() {
+			bounds.Union(featureBounds)
+			items = append(items, spatial.Item{BBox: featureBounds, ID: i})
+		}
+	}
+
+	info.TotalFeatures = len(features)
+	info.TiposGeometria = sortedKeys(geometryTypes)
+	info.Propiedades = sortedKeys(properties)
+	if !bounds.IsEmpty() {
+		info.BBox = bounds[:]
+	}
+
+	return &registeredDataset{
+		info:  info,
+		geo:   &types.GeoFeatureCollection{Type: "FeatureCollection", Features: features},
+		index: spatial.NewIndex(items),
+	}
+}
+
+// decodeGeoJSON convierte un documento GeoJSON (FeatureCollection, Feature o
+// geometría suelta) en features. Los features sin una geometría reconocible
+// quedan con geometría nil.
+func decodeGeoJSON(data []byte) ([]types.GeoFeature, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error deserializando GeoJSON: %w", err)
+	}
+
+	var rawFeatures []interface{}
+	switch doc["type"] {
+	case "FeatureCollection":
+		var ok bool
+		if rawFeatures, ok = doc["features"].([]interface{}); !ok {
+			return nil, fmt.Errorf("GeoJSON inválido: FeatureCollection sin features")
+		}
+	case "Feature":
+		rawFeatures = []interface{}{doc}
+	case "Point", "MultiPoint", "LineString", "MultiLineString", "Polygon", "MultiPolygon", "GeometryCollection":
+		rawFeatures = []interface{}{map[string]interface{}{"type": "Feature", "geometry": doc}}
+	default:
+		return nil, fmt.Errorf("el archivo no es TopoJSON ni GeoJSON (type %v)", doc["type"])
+	}
+
+	features := make([]types.GeoFeature, 0, len(rawFeatures))
+	for _, raw := range rawFeatures {
+		featureMap, _ := raw.(map[string]interface{})
+		properties, _ := featureMap["properties"].(map[string]interface{})
+		feature := types.GeoFeature{Type: "Feature", Properties: properties}
+
+		switch id := featureMap["id"].(type) {
+		case string:
+			feature.ID = id
+		case float64:
+			feature.ID = strconv.FormatFloat(id, 'f', -1, 64)
+		}
+		if geometry, ok := featureMap["geometry"].(map[string]interface{}); ok && validGeoJSONGeometry(geometry) {
+			feature.Geometry = geometry
+		}
+		features = append(features, feature)
+	}
+	return features, nil
+}
+
+// validGeoJSONGeometry indica si la geometría tiene tipo y coordenadas (o
+// geometrías, en una GeometryCollection)
+func validGeoJSONGeometry(geometry map[string]interface{}) bool {
+	if _, ok := geometry["type"].(string); !ok {
+		return false
+	}
+	if geometries, ok := geometry["geometries"].([]interface{}); ok {
+		return len(geometries) > 0
+	}
+	coords, ok := geometry["coordinates"].([]interface{})
+	return ok && len(coords) > 0
+}
+
+// assignDatasetIDs completa los IDs de los features de una capa: conserva el
+// ID del archivo y, si no tiene, usa su posición (1, 2, ...). Los IDs repetidos
+// reciben un sufijo como en assignFeatureIDs. Las propiedades se copian.
+func assignDatasetIDs(features []types.GeoFeature) {
+	seen := make(map[string]int, len(features))
+	for i := range features {
+		id := features[i].ID
+		if id == "" {
+			id = strconv.Itoa(i + 1)
+		}
+		seen[id]++
+		if seen[id] > 1 {
+			id = fmt.Sprintf("%s-%d", id, seen[id])
+		}
+		features[i].ID = id
+
+		props := make(map[string]interface{}, len(features[i].Properties))
+		for k, v := range features[i].Properties {
+			props[k] = v
+		}
+		features[i].Properties = props
+	}
+}
+
+// sortedKeys retorna las claves del conjunto en orden alfabético
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,25 +1,46 @@
 package cache
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
+	"chivomap.com/interfaces"
+	"chivomap.com/services"
+	"chivomap.com/spatial"
 	"chivomap.com/types"
 	"chivomap.com/utils"
 )
 
 // StaticFileCache maneja el cache de archivos estáticos como TopoJSON
 type StaticFileCache struct {
-	topoData   *types.TopoJSON
-	geoData    *types.GeoFeatureCollection
-	loadedAt   time.Time
-	filePath   string
+	data        *dataset // nil hasta la primera carga; se reemplaza completo al recargar
+	onReload    []func(version string)
+	loadedAt    time.Time
+	filePath    string
 	fileModTime time.Time
-	mu         sync.RWMutex
+	fileSize    int64
+	mu          sync.RWMutex
+
+	// loadMu serializa las recargas; los campos rejected* recuerdan el último
+	// archivo rechazado para no volver a procesarlo en cada revisión
+	loadMu          sync.Mutex
+	rejectedModTime time.Time
+	rejectedSize    int64
+	rejectedErr     error
+}
+
+// simplifiedKey identifica una versión simplificada de un nivel
+type simplifiedKey struct {
+	nivel     string
+	tolerance float64
 }
 
 // StaticFileCacheService implements the StaticCacheService interface
@@ -31,23 +52,136 @@ func NewStaticFileCache(assetsDir string) *StaticFileCache {
 	}
 }
 
-// LoadTopoJSON carga el archivo TopoJSON en memoria si no está cacheado o si ha cambiado
+// Snapshot retorna el dataset vigente, leyéndolo la primera vez. Todo lo que se
+// obtiene de un mismo snapshot (features, índices, métricas, adyacencias y
+// arcs) corresponde a la misma versión de topo.json aunque el archivo se
+// recargue mientras se usa; los cambios posteriores los detecta Watch.
+func (s *StaticFileCache) Snapshot() (interfaces.GeoSnapshot, error) {
+	data, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// current retorna el dataset vigente, cargándolo si aún no se lee
+func (s *StaticFileCache) current() (*dataset, error) {
+	s.mu.RLock()
+	data := s.data
+	s.mu.RUnlock()
+	if data != nil {
+		return data, nil
+	}
+
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.data == nil {
+		return nil, fmt.Errorf("TopoJSON no disponible")
+	}
+	return s.data, nil
+}
+
+// LoadTopoJSON retorna el TopoJSON del dataset vigente
 func (s *StaticFileCache) LoadTopoJSON() (*types.TopoJSON, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	data, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return data.TopoJSON(), nil
+}
+
+// GetGeoData retorna el GeoJSON generado a partir del TopoJSON
+func (s *StaticFileCache) GetGeoData() (*types.GeoFeatureCollection, error) {
+	data, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return data.GeoData(), nil
+}
+
+// Reload vuelve a leer topo.json si cambió desde la última carga. El archivo se
+// decodifica, convierte a GeoJSON, valida e indexa fuera del lock, y solo si
+// tiene al menos un feature válido reemplaza de una sola vez el TopoJSON y todo
+// lo derivado de él; si no, se siguen sirviendo los datos anteriores. Después
+// de un reemplazo se notifica a los callbacks de OnReload. Retorna si los datos
+// cambiaron.
+func (s *StaticFileCache) Reload() (bool, error) {
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
 
-	// Verificar si el archivo ha cambiado
 	fileInfo, err := os.Stat(s.filePath)
 	if err != nil {
-		return nil, fmt.Errorf("error obteniendo información del archivo TopoJSON %s: %w", s.filePath, err)
+		return false, fmt.Errorf("error obteniendo información del archivo TopoJSON %s: %w", s.filePath, err)
+	}
+
+	s.mu.RLock()
+	loaded := s.data != nil
+	unchanged := loaded && fileInfo.ModTime().Equal(s.fileModTime) && fileInfo.Size() == s.fileSize
+	s.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+	// No volver a intentar un archivo que ya fue rechazado
+	if fileInfo.ModTime().Equal(s.rejectedModTime) && fileInfo.Size() == s.rejectedSize {
+		return false, s.rejectedErr
+	}
+
+	next, err := s.loadDataset(fileInfo)
+	if err != nil {
+		s.rejectedModTime, s.rejectedSize, s.rejectedErr = fileInfo.ModTime(), fileInfo.Size(), err
+		if loaded {
+			utils.Error("TopoJSON rechazado, se mantiene la versión %s: %v", s.Version(), err)
+		}
+		return false, err
+	}
+	s.rejectedModTime, s.rejectedSize, s.rejectedErr = time.Time{}, 0, nil
+
+	s.mu.Lock()
+	if loaded && next.version == s.data.version {
+		// Mismo contenido (por ejemplo, solo cambió la fecha del archivo)
+		s.fileModTime, s.fileSize = fileInfo.ModTime(), fileInfo.Size()
+		s.mu.Unlock()
+		return false, nil
+	}
+	var previous string
+	if loaded {
+		previous = s.data.version
 	}
+	s.data = next
+	s.loadedAt = time.Now()
+	s.fileModTime, s.fileSize = fileInfo.ModTime(), fileInfo.Size()
+	callbacks := append([]func(string){}, s.onReload...)
+	s.mu.Unlock()
 
-	// Si ya está cacheado y el archivo no ha cambiado, retornar el cache
-	if s.topoData != nil && !fileInfo.ModTime().After(s.fileModTime) {
-		return s.topoData, nil
+	if loaded {
+		utils.Info("Dataset actualizado: versión %s -> %s (%d features)", previous, next.version, len(next.GeoData().Features))
 	}
+	for _, callback := range callbacks {
+		callback(next.version)
+	}
+	return true, nil
+}
 
-	// Cargar el archivo
+// dataset agrupa un TopoJSON y todo lo que se deriva de él, para reemplazarlo
+// de una sola vez. No se modifica después de cargarse: las métricas y las
+// simplificaciones que se calculan al pedirse se guardan en el propio dataset,
+// así un snapshot nunca mezcla datos de dos versiones.
+type dataset struct {
+	cache      *StaticFileCache // convierte y simplifica los arcs
+	topo       *types.TopoJSON
+	levels     map[string]*levelData // distritos, municipios y departamentos
+	simplified *services.LRUCache[simplifiedKey, *types.GeoFeatureCollection]
+	adjacency  [][]spatial.Neighbor // vecinos de cada distrito
+	validation *types.ValidationReport
+	version    string // SHA-256 abreviado de topo.json
+}
+
+// loadDataset lee, decodifica, convierte, valida e indexa topo.json
+func (s *StaticFileCache) loadDataset(fileInfo os.FileInfo) (*dataset, error) {
 	utils.Info("Cargando TopoJSON desde: %s", s.filePath)
 	file, err := os.ReadFile(s.filePath)
 	if err != nil {
@@ -63,77 +197,561 @@ func (s *StaticFileCache) LoadTopoJSON() (*types.TopoJSON, error) {
 		return nil, fmt.Errorf("TopoJSON inválido en %s: faltan objects o arcs", s.filePath)
 	}
 
-	// Actualizar cache
-	s.topoData = &topo
-	s.loadedAt = time.Now()
-	s.fileModTime = fileInfo.ModTime()
-	s.geoData = nil // Invalidar cache de GeoJSON
+	// Convertir TopoJSON a GeoJSON
+	geo, report, err := s.topoToGeo(&topo)
+	if err != nil {
+		return nil, fmt.Errorf("error convirtiendo TopoJSON a GeoJSON: %w", err)
+	}
+	if report.FeaturesValidos == 0 {
+		return nil, fmt.Errorf("TopoJSON inválido en %s: ninguno de sus %d features tiene geometría válida", s.filePath, report.TotalFeatures)
+	}
 
-	utils.Info("TopoJSON cargado exitosamente (%.2f MB)", float64(len(file))/1024/1024)
-	return s.topoData, nil
+	sum := sha256.Sum256(file)
+	version := hex.EncodeToString(sum[:])[:12]
+	report.Version = version
+
+	utils.Info("TopoJSON cargado exitosamente (%.2f MB, versión %s, %d features)",
+		float64(len(file))/1024/1024, version, len(geo.Features))
+	if report.FeaturesInvalidos > 0 {
+		utils.Error("%d features sin geometría válida; ver /geo/validation", report.FeaturesInvalidos)
+	}
+
+	// Construir el índice espacial una sola vez junto con los features
+	start := time.Now()
+	index := spatial.NewFeatureIndex(geo)
+	adjacency := s.buildAdjacency(&topo)
+	utils.Info("Índice espacial y adyacencias construidos en %s", time.Since(start))
+	levels := s.buildLevels(&topo, geo)
+	levels[types.NivelDistrito] = &levelData{object: topo.Objects["collection"], geo: geo, index: index}
+
+	return &dataset{
+		cache:      s,
+		topo:       &topo,
+		levels:     levels,
+		simplified: services.NewLRUCache[simplifiedKey, *types.GeoFeatureCollection](maxSimplifiedLevels),
+		adjacency:  adjacency,
+		validation: report,
+		version:    version,
+	}, nil
 }
 
-// GetGeoData convierte TopoJSON a GeoJSON y lo cachea
-func (s *StaticFileCache) GetGeoData() (*types.GeoFeatureCollection, error) {
+// Version retorna la versión del dataset cargado: los primeros 12 caracteres
+// del SHA-256 de topo.json, o "" si aún no se carga
+func (s *StaticFileCache) Version() string {
 	s.mu.RLock()
-	if s.geoData != nil {
-		defer s.mu.RUnlock()
-		return s.geoData, nil
+	defer s.mu.RUnlock()
+	if s.data == nil {
+		return ""
 	}
-	s.mu.RUnlock()
+	return s.data.version
+}
 
-	// Cargar TopoJSON si no está disponible
-	topo, err := s.LoadTopoJSON()
+// OnReload registra una función que se llama con la nueva versión cada vez
+// que se reemplaza el dataset, por ejemplo para vaciar caches derivados
+func (s *StaticFileCache) OnReload(callback func(version string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onReload = append(s.onReload, callback)
+}
+
+// GetValidationReport retorna el resultado de validar las geometrías al generar
+// el GeoJSON
+func (s *StaticFileCache) GetValidationReport() (*types.ValidationReport, error) {
+	data, err := s.current()
 	if err != nil {
 		return nil, err
 	}
+	return data.validation, nil
+}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// GetSpatialIndex retorna el índice espacial de los features de GetGeoData
+func (s *StaticFileCache) GetSpatialIndex() (*spatial.FeatureIndex, error) {
+	return s.GetLevelIndex(types.NivelDistrito)
+}
 
-	// Verificar de nuevo por si otro goroutine ya lo convirtió
-	if s.geoData != nil {
-		return s.geoData, nil
+// GetAdjacency retorna, por cada feature de GetGeoData (en el mismo orden), los
+// features con los que comparte arcs de borde en el TopoJSON
+func (s *StaticFileCache) GetAdjacency() ([][]spatial.Neighbor, error) {
+	data, err := s.current()
+	if err != nil {
+		return nil, err
 	}
+	return data.Adjacency(), nil
+}
 
-	// Convertir TopoJSON a GeoJSON
-	geo, err := s.topoToGeo(topo)
+// GetMetrics retorna, por cada feature de GetGeoData (en el mismo orden), su
+// área, perímetro, centroide y punto de etiqueta. Se calculan sobre las
+// geometrías a resolución completa la primera vez que se piden.
+func (s *StaticFileCache) GetMetrics() ([]types.FeatureMetrics, error) {
+	return s.GetLevelMetrics(types.NivelDistrito)
+}
+
+// GetLevelMetrics es como GetMetrics pero para los features de GetLevelGeoData
+func (s *StaticFileCache) GetLevelMetrics(nivel string) ([]types.FeatureMetrics, error) {
+	data, err := s.current()
 	if err != nil {
-		return nil, fmt.Errorf("error convirtiendo TopoJSON a GeoJSON: %w", err)
+		return nil, err
+	}
+	return data.LevelMetrics(nivel)
+}
+
+// computeMetrics calcula las métricas de cada feature del índice
+func computeMetrics(index *spatial.FeatureIndex) []types.FeatureMetrics {
+	start := time.Now()
+	metrics := make([]types.FeatureMetrics, index.Len())
+	for id := range metrics {
+		polys := index.Polygons(id)
+		if len(polys) == 0 {
+			continue
+		}
+		cLon, cLat := spatial.PolygonsCentroid(polys)
+		lLon, lLat := spatial.PoleOfInaccessibility(polys)
+		metrics[id] = types.FeatureMetrics{
+			AreaKm2:     roundTo(spatial.PolygonsAreaKm2(polys), 3),
+			PerimeterKm: roundTo(spatial.PolygonsPerimeterKm(polys), 3),
+			Centroid:    [2]float64{roundTo(cLon, 6), roundTo(cLat, 6)},
+			LabelPoint:  [2]float64{roundTo(lLon, 6), roundTo(lLat, 6)},
+		}
+	}
+	utils.Info("Métricas de %d features calculadas en %s", len(metrics), time.Since(start))
+	return metrics
+}
+
+// roundTo redondea a la cantidad de decimales indicada
+func roundTo(value float64, decimals int) float64 {
+	factor := math.Pow(10, float64(decimals))
+	return math.Round(value*factor) / factor
+}
+
+// buildAdjacency calcula los vecinos de cada geometría a partir de los arcs que
+// comparten. Un arc compartido por dos geometrías es un tramo de su borde común.
+func (s *StaticFileCache) buildAdjacency(topo *types.TopoJSON) [][]spatial.Neighbor {
+	collection := topo.Objects["collection"]
+
+	// Geometrías que usan cada arc
+	owners := make(map[int][]int)
+	for i, geom := range collection.Geometries {
+		var arcs any
+		if len(geom.Arcs) == 0 || json.Unmarshal(geom.Arcs, &arcs) != nil {
+			continue
+		}
+		used := make(map[int]bool)
+		collectArcIndices(arcs, used)
+		for arc := range used {
+			owners[arc] = append(owners[arc], i)
+		}
+	}
+
+	// Longitud del borde compartido por cada par de geometrías
+	borders := make([]map[int]float64, len(collection.Geometries))
+	for arc, geoms := range owners {
+		if len(geoms) < 2 || arc >= len(topo.Arcs) {
+			continue
+		}
+		length := spatial.PathLengthKm(s.convertArcToCoords(topo.Arcs[arc], topo.Transform))
+		for _, a := range geoms {
+			for _, b := range geoms {
+				if a == b {
+					continue
+				}
+				if borders[a] == nil {
+					borders[a] = make(map[int]float64)
+				}
+				borders[a][b] += length
+			}
+		}
+	}
+
+	adjacency := make([][]spatial.Neighbor, len(collection.Geometries))
+	for i, neighbors := range borders {
+		adjacency[i] = make([]spatial.Neighbor, 0, len(neighbors))
+		for id, km := range neighbors {
+			adjacency[i] = append(adjacency[i], spatial.Neighbor{ID: id, BorderKm: km})
+		}
+		sort.Slice(adjacency[i], func(a, b int) bool { return adjacency[i][a].ID < adjacency[i][b].ID })
+	}
+	return adjacency
+}
+
+// collectArcIndices agrega a used los índices (sin dirección) de los arcs de una
+// geometría, sin importar su anidamiento
+func collectArcIndices(arcs any, used map[int]bool) {
+	switch t := arcs.(type) {
+	case float64:
+		index := int(t)
+		if index < 0 {
+			index = ^index
+		}
+		used[index] = true
+	case []interface{}:
+		for _, child := range t {
+			collectArcIndices(child, used)
+		}
+	}
+}
+
+// maxSimplifiedLevels limita los niveles de simplificación cacheados; al
+// superarlo se descartan los usados hace más tiempo
+const maxSimplifiedLevels = 32
+
+// GetSimplifiedGeoData retorna los features de GetGeoData con los arcs
+// simplificados (Douglas-Peucker, tolerancia en grados). Como se simplifican
+// los arcs compartidos del TopoJSON y sus extremos se conservan, los polígonos
+// vecinos siguen sin huecos entre sí. Los features conservan el orden de
+// GetGeoData y cada nivel se cachea.
+func (s *StaticFileCache) GetSimplifiedGeoData(tolerance float64) (*types.GeoFeatureCollection, error) {
+	return s.GetLevelGeoData(types.NivelDistrito, tolerance)
+}
+
+// GetLevelGeoData retorna los features de un nivel administrativo del dataset
+// vigente; ver dataset.LevelGeoData
+func (s *StaticFileCache) GetLevelGeoData(nivel string, tolerance float64) (*types.GeoFeatureCollection, error) {
+	data, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return data.LevelGeoData(nivel, tolerance)
+}
+
+// GetLevelIndex retorna el índice espacial de los features de GetLevelGeoData
+func (s *StaticFileCache) GetLevelIndex(nivel string) (*spatial.FeatureIndex, error) {
+	data, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return data.LevelIndex(nivel)
+}
+
+// GetLevelObject retorna las geometrías TopoJSON de un nivel, que referencian
+// los arcs de LoadTopoJSON y siguen el orden de GetLevelGeoData
+func (s *StaticFileCache) GetLevelObject(nivel string) (*types.TopoObject, error) {
+	data, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return data.LevelObject(nivel)
+}
+
+// Version retorna la versión del dataset
+func (d *dataset) Version() string {
+	return d.version
+}
+
+// TopoJSON retorna la topología de la que se generó el dataset
+func (d *dataset) TopoJSON() *types.TopoJSON {
+	return d.topo
+}
+
+// GeoData retorna los distritos a resolución completa
+func (d *dataset) GeoData() *types.GeoFeatureCollection {
+	return d.levels[types.NivelDistrito].geo
+}
+
+// Adjacency retorna, por cada distrito (en el orden de GeoData), los distritos
+// con los que comparte arcs de borde
+func (d *dataset) Adjacency() [][]spatial.Neighbor {
+	return d.adjacency
+}
+
+// ValidationReport retorna el resultado de validar las geometrías del dataset
+func (d *dataset) ValidationReport() *types.ValidationReport {
+	return d.validation
+}
+
+// LevelGeoData retorna los features de un nivel administrativo: los distritos
+// de topo.json o los departamentos y municipios que resultan de unirlos. Con
+// tolerance > 0 las geometrías se simplifican como en GetSimplifiedGeoData,
+// con la tolerancia ajustada por spatial.SnapTolerance; el orden de los
+// features es el de LevelIndex.
+func (d *dataset) LevelGeoData(nivel string, tolerance float64) (*types.GeoFeatureCollection, error) {
+	nivel, level, err := d.level(nivel)
+	if err != nil {
+		return nil, err
+	}
+	tolerance = spatial.SnapTolerance(tolerance)
+	if tolerance == 0 {
+		return level.geo, nil
+	}
+
+	key := simplifiedKey{nivel: nivel, tolerance: tolerance}
+	if geo, ok := d.simplified.Get(key); ok {
+		return geo, nil
+	}
+
+	// El dataset no cambia, así que se simplifica sin bloquear a los demás
+	// lectores. Arcs en coordenadas absolutas: convertArcToCoords los usa tal
+	// cual cuando no hay transform.
+	simplifiedTopo := *d.topo
+	simplifiedTopo.Transform = types.Transform{}
+	simplifiedTopo.Arcs = make([][][]float64, len(d.topo.Arcs))
+	for i, arc := range d.topo.Arcs {
+		simplifiedTopo.Arcs[i] = d.cache.simplifyArc(d.cache.convertArcToCoords(arc, d.topo.Transform), tolerance)
+	}
+
+	geo := &types.GeoFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: d.cache.simplifyFeatures(level.object, level.geo.Features, d.topo, &simplifiedTopo),
+	}
+
+	// Si otra solicitud lo calculó al mismo tiempo se conserva el primero
+	if cached, ok := d.simplified.Get(key); ok {
+		return cached, nil
+	}
+	d.simplified.Add(key, geo)
+	utils.Info("GeoJSON de nivel %s simplificado con tolerancia %g y cacheado", nivel, tolerance)
+	return geo, nil
+}
+
+// LevelIndex retorna el índice espacial de los features de LevelGeoData
+func (d *dataset) LevelIndex(nivel string) (*spatial.FeatureIndex, error) {
+	_, level, err := d.level(nivel)
+	if err != nil {
+		return nil, err
+	}
+	return level.index, nil
+}
+
+// LevelMetrics retorna, por cada feature de LevelGeoData (en el mismo orden),
+// su área, perímetro, centroide y punto de etiqueta. Se calculan sobre las
+// geometrías a resolución completa la primera vez que se piden.
+func (d *dataset) LevelMetrics(nivel string) ([]types.FeatureMetrics, error) {
+	_, level, err := d.level(nivel)
+	if err != nil {
+		return nil, err
+	}
+	level.metricsOnce.Do(func() {
+		level.metrics = computeMetrics(level.index)
+	})
+	return level.metrics, nil
+}
+
+// LevelObject retorna las geometrías TopoJSON de un nivel, que referencian los
+// arcs de TopoJSON y siguen el orden de LevelGeoData. Las de los departamentos
+// y municipios ya traen su ID.
+func (d *dataset) LevelObject(nivel string) (*types.TopoObject, error) {
+	_, level, err := d.level(nivel)
+	if err != nil {
+		return nil, err
+	}
+	return &level.object, nil
+}
+
+// level retorna el nombre normalizado y los datos de un nivel
+func (d *dataset) level(nivel string) (string, *levelData, error) {
+	nivel, err := levelName(nivel)
+	if err != nil {
+		return "", nil, err
+	}
+	level, ok := d.levels[nivel]
+	if !ok {
+		return "", nil, fmt.Errorf("nivel %s no disponible", nivel)
+	}
+	return nivel, level, nil
+}
+
+// simplifyArc simplifica un arc ya decodificado. Los arcs cerrados (islas
+// completas en un solo arc) se simplifican como anillo y se conservan intactos
+// si colapsarían.
+func (s *StaticFileCache) simplifyArc(coords [][]float64, tolerance float64) [][]float64 {
+	if len(coords) < 3 {
+		return coords
+	}
+
+	first, last := coords[0], coords[len(coords)-1]
+	if first[0] == last[0] && first[1] == last[1] {
+		if ring := spatial.SimplifyRing(coords, tolerance); ring != nil {
+			return ring
+		}
+		return coords
+	}
+	return spatial.SimplifyPath(coords, tolerance)
+}
+
+// simplifyFeatures retorna copias de los features de full (la conversión a
+// resolución completa del objeto, en el mismo orden) con la geometría armada con
+// los arcs simplificados. La validación es la de resolución completa: los
+// features inválidos siguen sin geometría y ningún feature se marca por la
+// simplificación.
+func (s *StaticFileCache) simplifyFeatures(object types.TopoObject, full []types.GeoFeature, topo, simplifiedTopo *types.TopoJSON) []types.GeoFeature {
+	geometries := objectGeometries(object)
+	features := make([]types.GeoFeature, len(full))
+	copy(features, full)
+	for i := range features {
+		if i >= len(geometries) || features[i].Geometry == nil {
+			continue
+		}
+		if coords := s.simplifiedCoordinates(geometries[i], topo, simplifiedTopo); coords != nil {
+			features[i].Geometry = map[string]interface{}{
+				"type":        geometries[i].Type,
+				"coordinates": coords,
+			}
+		}
+	}
+	return features
+}
+
+// simplifiedCoordinates arma las coordenadas de una geometría ya validada con
+// los arcs simplificados. Un anillo que colapsa (menos de 4 posiciones o área
+// nula) o una línea de menos de 2 posiciones se reemplaza por su versión a
+// resolución completa, así la simplificación nunca hace desaparecer una parte.
+// Retorna nil para los puntos, que no se simplifican.
+func (s *StaticFileCache) simplifiedCoordinates(geom types.Geometry, topo, simplifiedTopo *types.TopoJSON) any {
+	path := func(arcs []int, ring bool) [][]float64 {
+		coords, _ := s.processArcRing(arcs, simplifiedTopo)
+		collapsed := len(coords) < 2
+		if ring {
+			collapsed = len(coords) < 4 || spatial.RingSignedArea(coords) == 0
+		}
+		if collapsed {
+			coords, _ = s.processArcRing(arcs, topo)
+		}
+		return coords
+	}
+	polygon := func(rings [][]int) [][][]float64 {
+		result := make([][][]float64, len(rings))
+		for i, ring := range rings {
+			result[i] = path(ring, true)
+		}
+		return result
 	}
 
-	s.geoData = geo
-	utils.Info("GeoJSON generado y cacheado (%d features)", len(geo.Features))
-	return s.geoData, nil
+	switch geom.Type {
+	case "LineString":
+		var line []int
+		if json.Unmarshal(geom.Arcs, &line) == nil {
+			return path(line, false)
+		}
+	case "MultiLineString":
+		var lines [][]int
+		if json.Unmarshal(geom.Arcs, &lines) == nil {
+			result := make([][][]float64, len(lines))
+			for i, line := range lines {
+				result[i] = path(line, false)
+			}
+			return result
+		}
+	case "Polygon":
+		var rings [][]int
+		if json.Unmarshal(geom.Arcs, &rings) == nil {
+			return polygon(rings)
+		}
+	case "MultiPolygon":
+		var polys [][][]int
+		if json.Unmarshal(geom.Arcs, &polys) == nil {
+			result := make([][][][]float64, len(polys))
+			for i, rings := range polys {
+				result[i] = polygon(rings)
+			}
+			return result
+		}
+	}
+	return nil
 }
 
-// topoToGeo convierte el objeto TopoJSON en un FeatureCollection de GeoJSON
-func (s *StaticFileCache) topoToGeo(topo *types.TopoJSON) (*types.GeoFeatureCollection, error) {
+// topoToGeo convierte el objeto TopoJSON en un FeatureCollection de GeoJSON y
+// valida cada geometría. Los features con errores conservan sus propiedades,
+// con geometry null y la propiedad "geometria_invalida".
+func (s *StaticFileCache) topoToGeo(topo *types.TopoJSON) (*types.GeoFeatureCollection, *types.ValidationReport, error) {
 	collection, ok := topo.Objects["collection"]
 	if !ok {
-		return nil, fmt.Errorf("TopoJSON inválido: la clave 'collection' no existe en objects")
+		return nil, nil, fmt.Errorf("TopoJSON inválido: la clave 'collection' no existe en objects")
 	}
 
+	features, issues := s.convertObject(collection, topo)
+	assignFeatureIDs(features)
+	markInvalid(features)
+
+	return &types.GeoFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: features,
+	}, newValidationReport(features, issues), nil
+}
+
+// convertObject convierte las geometrías de un objeto de la topología (una
+// GeometryCollection o una geometría suelta) en features, junto con los
+// problemas encontrados en cada una
+func (s *StaticFileCache) convertObject(object types.TopoObject, topo *types.TopoJSON) ([]types.GeoFeature, [][]types.GeometryIssue) {
+	geometries := objectGeometries(object)
+
 	// Preallocar slice para mejor performance
-	features := make([]types.GeoFeature, 0, len(collection.Geometries))
+	features := make([]types.GeoFeature, 0, len(geometries))
+	issues := make([][]types.GeometryIssue, 0, len(geometries))
 	
-	for _, geom := range collection.Geometries {
-		geoGeom := s.convertGeometrySimple(geom, topo)
-		
-		features = append(features, types.GeoFeature{
-			Type: "Feature",
-			Geometry: map[string]interface{}{
+	for _, geom := range geometries {
+		geoGeom, geomIssues := s.convertGeometrySimple(geom, topo)
+		issues = append(issues, geomIssues)
+
+		var geometry any
+		if geoGeom != nil {
+			geometry = map[string]interface{}{
 				"type":        geom.Type,
 				"coordinates": geoGeom,
-			},
+			}
+		}
+		
+		features = append(features, types.GeoFeature{
+			Type:       "Feature",
+			ID:         geom.ID,
+			Geometry:   geometry,
 			Properties: geom.Properties,
 		})
 	}
+	return features, issues
+}
 
-	return &types.GeoFeatureCollection{
-		Type:     "FeatureCollection",
-		Features: features,
-	}, nil
+// markInvalid agrega la propiedad "geometria_invalida" a los features sin
+// geometría. Las propiedades ya deben ser una copia de las del TopoJSON.
+func markInvalid(features []types.GeoFeature) {
+	for i := range features {
+		if features[i].Geometry == nil {
+			features[i].Properties["geometria_invalida"] = true
+		}
+	}
+}
+
+// officialCodeKeys son las propiedades que, si existen, contienen el código
+// oficial (CNR/DIGESTYC) del distrito y se prefieren como ID del feature
+var officialCodeKeys = []string{"COD_DIST", "CODIGO", "COD_DIGESTYC", "COD_CNR"}
+
+// assignFeatureIDs asigna a cada feature un ID determinístico: el código oficial
+// si existe en sus propiedades o, si no, el ID jerárquico de D, M y NAM
+// ("la-libertad.la-libertad-costa.isla-tasajera"), que coincide con el de la
+// jerarquía administrativa. Los IDs repetidos reciben un sufijo según su orden
+// en el TopoJSON. También agrega la propiedad "slug" con el nombre del distrito.
+func assignFeatureIDs(features []types.GeoFeature) {
+	seen := make(map[string]int, len(features))
+	for i := range features {
+		props := features[i].Properties
+
+		id := ""
+		for _, key := range officialCodeKeys {
+			if code := utils.Slugify(fmt.Sprint(props[key])); props[key] != nil && code != "" {
+				id = code
+				break
+			}
+		}
+		if id == "" {
+			d, _ := props["D"].(string)
+			m, _ := props["M"].(string)
+			nam, _ := props["NAM"].(string)
+			id = utils.HierarchicalID(d, m, nam)
+		}
+
+		seen[id]++
+		if seen[id] > 1 {
+			id = fmt.Sprintf("%s-%d", id, seen[id])
+		}
+		features[i].ID = id
+
+		// Copiar las propiedades para no modificar las del TopoJSON
+		withSlug := make(map[string]interface{}, len(props)+1)
+		for k, v := range props {
+			withSlug[k] = v
+		}
+		nam, _ := props["NAM"].(string)
+		withSlug["slug"] = utils.Slugify(nam)
+		features[i].Properties = withSlug
+	}
 }
 
 func min(a, b int) int {
@@ -143,107 +761,140 @@ func min(a, b int) int {
 	return b
 }
 
-// convertGeometrySimple convierte geometría con fallback a coordenadas básicas
-func (s *StaticFileCache) convertGeometrySimple(geom types.Geometry, topo *types.TopoJSON) any {
-	// Verificar si hay arcs con contenido real
-	hasArcs := len(geom.Arcs) > 0 && string(geom.Arcs) != "null" && len(topo.Arcs) > 0
-	
-	if hasArcs {
-		switch geom.Type {
-		case "Polygon":
-			result := s.convertPolygonReal(geom, topo)
-			// Si la conversión falló, usar fallback
-			if len(result) > 0 && len(result[0]) > 0 {
-				return result
-			}
-		case "MultiPolygon":
-			result := s.convertMultiPolygonReal(geom, topo)
-			// Si la conversión falló, usar fallback
-			if len(result) > 0 && len(result[0]) > 0 && len(result[0][0]) > 0 {
-				return result
-			}
-		}
-	}
-	
-	// Fallback: coordenadas básicas
+// convertGeometrySimple convierte la geometría TopoJSON de un feature a
+// coordenadas GeoJSON y valida el resultado. Si encuentra un error (arcs que no
+// se pueden decodificar o que no existen, anillos abiertos o degenerados, tipo
+// no soportado) retorna nil en vez de inventar una forma; las advertencias
+// (auto-intersecciones, orientación) no descartan la geometría.
+func (s *StaticFileCache) convertGeometrySimple(geom types.Geometry, topo *types.TopoJSON) (any, []types.GeometryIssue) {
+	v := &geometryValidator{}
+
+	var coords any
 	switch geom.Type {
-	case "Point":
-		return []float64{-89.0, 13.7}
-	case "MultiPoint":
-		return [][]float64{{-89.0, 13.7}}
+	case "Point", "MultiPoint":
+		coords = s.convertPoints(geom, topo.Transform, v)
 	case "LineString":
-		return [][]float64{{-89.0, 13.7}, {-89.1, 13.8}}
+		var line []int
+		if v.unmarshalArcs(geom.Arcs, &line) {
+			coords = s.convertLine(line, topo, 0, v)
+		}
+	case "MultiLineString":
+		var lines [][]int
+		if v.unmarshalArcs(geom.Arcs, &lines) {
+			multiLine := make([][][]float64, 0, len(lines))
+			for i, line := range lines {
+				multiLine = append(multiLine, s.convertLine(line, topo, i, v))
+			}
+			coords = multiLine
+		}
 	case "Polygon":
-		return [][][]float64{{{-89.0, 13.7}, {-89.1, 13.7}, {-89.1, 13.8}, {-89.0, 13.8}, {-89.0, 13.7}}}
+		var rings [][]int
+		if v.unmarshalArcs(geom.Arcs, &rings) {
+			coords = s.convertPolygonReal(rings, topo, 0, v)
+		}
 	case "MultiPolygon":
-		return [][][][]float64{{{{-89.0, 13.7}, {-89.1, 13.7}, {-89.1, 13.8}, {-89.0, 13.8}, {-89.0, 13.7}}}}
+		var multiPoly [][][]int
+		if v.unmarshalArcs(geom.Arcs, &multiPoly) {
+			coords = s.convertMultiPolygonReal(multiPoly, topo, v)
+		}
 	default:
-		return []float64{-89.0, 13.7}
+		v.add(severityError, "tipo_no_soportado", 0, 0, fmt.Sprintf("tipo de geometría no soportado: %q", geom.Type))
 	}
+
+	if v.failed {
+		return nil, v.issues
+	}
+	return coords, v.issues
 }
 
-// convertPolygonReal convierte un polígono usando arcs reales
-func (s *StaticFileCache) convertPolygonReal(geom types.Geometry, topo *types.TopoJSON) [][][]float64 {
-	var rings [][]int
-	if err := json.Unmarshal(geom.Arcs, &rings); err != nil {
-		utils.Error("Error unmarshal polygon arcs: %v, raw: %s", err, string(geom.Arcs))
-		return [][][]float64{{{-89.0, 13.7}, {-89.1, 13.7}, {-89.1, 13.8}, {-89.0, 13.8}, {-89.0, 13.7}}}
+// convertPoints convierte las coordenadas de un Point o MultiPoint (cuantizadas
+// con el Transform, pero sin deltas)
+func (s *StaticFileCache) convertPoints(geom types.Geometry, transform types.Transform, v *geometryValidator) any {
+	decode := func(pos []float64) []float64 {
+		if len(transform.Scale) < 2 || len(transform.Translate) < 2 {
+			return pos
+		}
+		return []float64{
+			transform.Scale[0]*pos[0] + transform.Translate[0],
+			transform.Scale[1]*pos[1] + transform.Translate[1],
+		}
 	}
-	
-	utils.Info("Polygon tiene %d rings", len(rings))
-	
+
+	if geom.Type == "Point" {
+		var pos []float64
+		if err := json.Unmarshal(geom.Coordinates, &pos); err != nil || len(pos) < 2 {
+			v.add(severityError, "coordenadas_invalidas", 0, 0, "el punto no tiene coordenadas válidas")
+			return nil
+		}
+		return decode(pos)
+	}
+
+	var positions [][]float64
+	if err := json.Unmarshal(geom.Coordinates, &positions); err != nil || len(positions) == 0 {
+		v.add(severityError, "coordenadas_invalidas", 0, 0, "el multipunto no tiene coordenadas válidas")
+		return nil
+	}
+	points := make([][]float64, 0, len(positions))
+	for _, pos := range positions {
+		if len(pos) < 2 {
+			v.add(severityError, "coordenadas_invalidas", 0, 0, "posición con menos de dos coordenadas")
+			return nil
+		}
+		points = append(points, decode(pos))
+	}
+	return points
+}
+
+// convertLine convierte una línea de arcs, reportando los arcs inexistentes
+func (s *StaticFileCache) convertLine(line []int, topo *types.TopoJSON, index int, v *geometryValidator) [][]float64 {
+	coords, badArcs := s.processArcRing(line, topo)
+	v.badArcs(badArcs, len(topo.Arcs), 0, index)
+	if len(badArcs) == 0 && len(coords) < 2 {
+		v.add(severityError, "linea_degenerada", 0, index,
+			fmt.Sprintf("la línea tiene %d posiciones; se necesitan al menos 2", len(coords)))
+	}
+	return coords
+}
+
+// convertPolygonReal convierte y valida los anillos de un polígono
+func (s *StaticFileCache) convertPolygonReal(rings [][]int, topo *types.TopoJSON, polygonIndex int, v *geometryValidator) [][][]float64 {
+	if len(rings) == 0 {
+		v.add(severityError, "geometria_vacia", polygonIndex, 0, "el polígono no tiene anillos")
+		return nil
+	}
+
 	polygon := make([][][]float64, 0, len(rings))
 	for i, ring := range rings {
-		utils.Info("Procesando ring %d con %d arcs", i, len(ring))
-		coords := s.processArcRing(ring, topo)
-		utils.Info("Ring %d produjo %d coordenadas", i, len(coords))
-		if len(coords) > 0 {
-			polygon = append(polygon, coords)
+		coords, badArcs := s.processArcRing(ring, topo)
+		v.badArcs(badArcs, len(topo.Arcs), polygonIndex, i)
+		if len(badArcs) == 0 {
+			v.validateRing(coords, polygonIndex, i)
 		}
-	}
-	
-	if len(polygon) == 0 {
-		utils.Error("Polygon conversion failed, usando fallback")
-		return [][][]float64{{{-89.0, 13.7}, {-89.1, 13.7}, {-89.1, 13.8}, {-89.0, 13.8}, {-89.0, 13.7}}}
+		polygon = append(polygon, coords)
 	}
 	return polygon
 }
 
-// convertMultiPolygonReal convierte un multipolígono usando arcs reales
-func (s *StaticFileCache) convertMultiPolygonReal(geom types.Geometry, topo *types.TopoJSON) [][][][]float64 {
-	var multiPoly [][][]int
-	if err := json.Unmarshal(geom.Arcs, &multiPoly); err != nil {
-		return [][][][]float64{{{{-89.0, 13.7}, {-89.1, 13.7}, {-89.1, 13.8}, {-89.0, 13.8}, {-89.0, 13.7}}}}
+// convertMultiPolygonReal convierte y valida los polígonos de un multipolígono
+func (s *StaticFileCache) convertMultiPolygonReal(multiPoly [][][]int, topo *types.TopoJSON, v *geometryValidator) [][][][]float64 {
+	if len(multiPoly) == 0 {
+		v.add(severityError, "geometria_vacia", 0, 0, "el multipolígono no tiene polígonos")
+		return nil
 	}
-	
+
 	polys := make([][][][]float64, 0, len(multiPoly))
-	for _, poly := range multiPoly {
-		polygon := make([][][]float64, 0, len(poly))
-		for _, ring := range poly {
-			coords := s.processArcRing(ring, topo)
-			if len(coords) > 0 {
-				polygon = append(polygon, coords)
-			}
-		}
-		if len(polygon) > 0 {
-			polys = append(polys, polygon)
-		}
-	}
-	
-	if len(polys) == 0 {
-		return [][][][]float64{{{{-89.0, 13.7}, {-89.1, 13.7}, {-89.1, 13.8}, {-89.0, 13.8}, {-89.0, 13.7}}}}
+	for i, poly := range multiPoly {
+		polys = append(polys, s.convertPolygonReal(poly, topo, i, v))
 	}
 	return polys
 }
 
-// processArcRing procesa un anillo de arcs y retorna coordenadas
-func (s *StaticFileCache) processArcRing(ring []int, topo *types.TopoJSON) [][]float64 {
-	if len(ring) == 0 || len(topo.Arcs) == 0 {
-		return [][]float64{}
-	}
-	
+// processArcRing concatena los arcs de un anillo (o línea) y retorna sus
+// coordenadas junto con los índices de los arcs que no existen
+func (s *StaticFileCache) processArcRing(ring []int, topo *types.TopoJSON) ([][]float64, []int) {
 	var coords [][]float64
-	
+	var badArcs []int
+
 	for _, arcIndex := range ring {
 		index := arcIndex
 		reverse := false
@@ -253,19 +904,26 @@ func (s *StaticFileCache) processArcRing(ring []int, topo *types.TopoJSON) [][]f
 			reverse = true
 		}
 		
-		if index >= 0 && index < len(topo.Arcs) {
-			arcCoords := s.convertArcToCoords(topo.Arcs[index], topo.Transform)
+		if index >= len(topo.Arcs) {
+			badArcs = append(badArcs, arcIndex)
+			continue
+		}
+
+		arcCoords := s.convertArcToCoords(topo.Arcs[index], topo.Transform)
+		for i := range arcCoords {
+			point := arcCoords[i]
 			if reverse {
-				for i := len(arcCoords) - 1; i >= 0; i-- {
-					coords = append(coords, arcCoords[i])
-				}
-			} else {
-				coords = append(coords, arcCoords...)
+				point = arcCoords[len(arcCoords)-1-i]
 			}
+			// Los arcs consecutivos comparten el punto de unión
+			if n := len(coords); n > 0 && coords[n-1][0] == point[0] && coords[n-1][1] == point[1] {
+				continue
+			}
+			coords = append(coords, point)
 		}
 	}
 	
-	return coords
+	return coords, badArcs
 }
 
 // convertArcToCoords convierte un arc TopoJSON a coordenadas geográficas
@@ -429,15 +1087,28 @@ func (s *StaticFileCache) GetCacheStats() map[string]interface{} {
 	defer s.mu.RUnlock()
 
 	stats := map[string]interface{}{
-		"loaded":     s.topoData != nil,
-		"loadedAt":   s.loadedAt,
-		"filePath":   s.filePath,
+		"loaded":      s.data != nil,
+		"loadedAt":    s.loadedAt,
+		"filePath":    s.filePath,
 		"fileModTime": s.fileModTime,
+		"version":     "",
+	}
+	if s.data == nil {
+		return stats
 	}
 
-	if s.geoData != nil {
-		stats["geoFeatures"] = len(s.geoData.Features)
+	stats["version"] = s.data.version
+	stats["geoFeatures"] = len(s.data.GeoData().Features)
+	stats["spatialIndexed"] = s.data.levels[types.NivelDistrito].index.Len()
+	if n := s.data.simplified.Len(); n > 0 {
+		stats["simplifiedLevels"] = n
+	}
+	for nivel, level := range s.data.levels {
+		if nivel != types.NivelDistrito {
+			stats[nivel+"Features"] = len(level.geo.Features)
+		}
 	}
+	stats["invalidFeatures"] = s.data.validation.FeaturesInvalidos
 
 	return stats
-}
\ No newline at end of file
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"chivomap.com/spatial"
+	"chivomap.com/types"
+)
+
+// Severidades de los problemas de geometría
+const (
+	severityError   = "error"       // la geometría se descarta
+	severityWarning = "advertencia" // la geometría se conserva
+)
+
+// geometryValidator acumula los problemas encontrados al convertir la geometría
+// de un feature
+type geometryValidator struct {
+	issues []types.GeometryIssue
+	failed bool
+}
+
+// add registra un problema; los errores invalidan la geometría
+func (v *geometryValidator) add(severity, tipo string, polygon, ring int, detail string) *types.GeometryIssue {
+	if severity == severityError {
+		v.failed = true
+	}
+	v.issues = append(v.issues, types.GeometryIssue{
+		Tipo:      tipo,
+		Severidad: severity,
+		Poligono:  polygon,
+		Anillo:    ring,
+		Detalle:   detail,
+	})
+	return &v.issues[len(v.issues)-1]
+}
+
+// unmarshalArcs decodifica el campo "arcs" con el anidamiento esperado
+func (v *geometryValidator) unmarshalArcs(raw json.RawMessage, target any) bool {
+	if len(raw) == 0 || string(raw) == "null" {
+		v.add(severityError, "geometria_vacia", 0, 0, "la geometría no tiene arcs")
+		return false
+	}
+	if err := json.Unmarshal(raw, target); err != nil {
+		v.add(severityError, "arcs_invalidos", 0, 0, fmt.Sprintf("no se pudieron decodificar los arcs: %v", err))
+		return false
+	}
+	return true
+}
+
+// badArcs registra los arcs referenciados que no existen en la topología
+func (v *geometryValidator) badArcs(arcs []int, totalArcs, polygon, ring int) {
+	for _, arc := range arcs {
+		issue := v.add(severityError, "arc_invalido", polygon, ring,
+			fmt.Sprintf("el arc %d no existe (la topología tiene %d arcs)", arc, totalArcs))
+		issue.Arc = &arc
+	}
+}
+
+// validateRing revisa que el anillo esté cerrado, tenga al menos 4 posiciones,
+// no se cruce consigo mismo y siga la orientación de RFC 7946 (exterior
+// antihorario, huecos horario)
+func (v *geometryValidator) validateRing(ring [][]float64, polygon, index int) {
+	if len(ring) < 4 {
+		v.add(severityError, "anillo_degenerado", polygon, index,
+			fmt.Sprintf("el anillo tiene %d posiciones; se necesitan al menos 4", len(ring)))
+		return
+	}
+	if !spatial.RingClosed(ring) {
+		issue := v.add(severityError, "anillo_abierto", polygon, index,
+			"la última posición del anillo no coincide con la primera")
+		issue.Punto = ring[len(ring)-1]
+		return
+	}
+
+	if point, ok := spatial.RingSelfIntersection(ring); ok {
+		issue := v.add(severityWarning, "autointerseccion", polygon, index,
+			"el anillo se cruza o se toca consigo mismo")
+		issue.Punto = point
+	}
+
+	exterior := index == 0
+	if ccw := spatial.RingIsCounterClockwise(ring); exterior && !ccw {
+		v.add(severityWarning, "orientacion", polygon, index,
+			"el anillo exterior está en sentido horario (RFC 7946 pide antihorario)")
+	} else if !exterior && ccw {
+		v.add(severityWarning, "orientacion", polygon, index,
+			"el hueco está en sentido antihorario (RFC 7946 pide horario)")
+	}
+}
+
+// newValidationReport resume los problemas de cada feature. Debe llamarse
+// después de assignFeatureIDs para que los problemas lleven el ID del feature.
+func newValidationReport(features []types.GeoFeature, issues [][]types.GeometryIssue) *types.ValidationReport {
+	report := &types.ValidationReport{
+		TotalFeatures: len(features),
+		PorTipo:       make(map[string]int),
+		Problemas:     make([]types.GeometryIssue, 0),
+		GeneradoEn:    time.Now(),
+	}
+
+	for i, featureIssues := range issues {
+		invalid, warned := false, false
+		nombre, _ := features[i].Properties["NAM"].(string)
+		for _, issue := range featureIssues {
+			issue.FeatureID = features[i].ID
+			issue.Nombre = nombre
+			report.Problemas = append(report.Problemas, issue)
+			report.PorTipo[issue.Tipo]++
+
+			if issue.Severidad == severityError {
+				invalid = true
+			} else {
+				warned = true
+			}
+		}
+
+		switch {
+		case invalid:
+			report.FeaturesInvalidos++
+		case warned:
+			report.FeaturesConAdvertencias++
+			report.FeaturesValidos++
+		default:
+			report.FeaturesValidos++
+		}
+	}
+	return report
+}
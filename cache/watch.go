@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"chivomap.com/utils"
+)
+
+// WatchInterval es cada cuánto Watch revisa si topo.json cambió
+const WatchInterval = 10 * time.Second
+
+// Watch revisa periódicamente en segundo plano la fecha y el tamaño de
+// topo.json y llama a Reload cuando cambian, hasta que ctx se cancele. Se
+// compara el archivo en vez de usar notificaciones del sistema para funcionar
+// igual en volúmenes montados y contenedores.
+func (s *StaticFileCache) Watch(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(WatchInterval)
+		defer ticker.Stop()
+
+		utils.Info("Vigilando cambios en %s cada %s", s.filePath, WatchInterval)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.Reload(); err != nil {
+					utils.Debug("Recarga de TopoJSON omitida: %v", err)
+				}
+			}
+		}
+	}()
+}
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"fmt"
 
+	"chivomap.com/utils"
+
 	_ "github.com/tursodatabase/go-libsql"
 )
 
@@ -56,5 +58,142 @@ func ConnectDB() error {
 		return fmt.Errorf("error creando la tabla: %w", err)
 	}
 
+	return MigrateSismos(DB)
+}
+
+// MigrateSismos crea o actualiza la tabla del catálogo sísmico y sus índices
+func MigrateSismos(db *sql.DB) error {
+	// Catálogo sísmico permanente: un registro por evento; las revisiones del
+	// SNET actualizan el registro original (ver scraping.SameEvent)
+	sismosSchema := []string{
+		`CREATE TABLE IF NOT EXISTS sismos (
+        event_id TEXT PRIMARY KEY,
+        fecha_utc TEXT NOT NULL,
+        origen_unix INTEGER NOT NULL,
+        fecha TEXT NOT NULL,
+        fases INTEGER NOT NULL DEFAULT 0,
+        latitud REAL NOT NULL,
+        longitud REAL NOT NULL,
+        profundidad REAL NOT NULL DEFAULT 0,
+        magnitud REAL NOT NULL DEFAULT 0,
+        localizacion TEXT NOT NULL DEFAULT '',
+        rms REAL NOT NULL DEFAULT 0,
+        estado TEXT NOT NULL DEFAULT '',
+        departamento TEXT,
+        departamento_norm TEXT,
+        municipio TEXT,
+        distrito TEXT,
+        offshore INTEGER,
+        distancia_km REAL,
+        creado_en TEXT NOT NULL,
+        actualizado_en TEXT NOT NULL
+    );`,
+		`CREATE INDEX IF NOT EXISTS idx_sismos_origen ON sismos (origen_unix);`,
+		`CREATE INDEX IF NOT EXISTS idx_sismos_magnitud ON sismos (magnitud);`,
+	}
+	for _, stmt := range sismosSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("error creando la tabla sismos: %w", err)
+		}
+	}
+
+	// Columnas agregadas después de la creación inicial de la tabla
+	if err := ensureColumns(db, "sismos", map[string]string{
+		"departamento":      "TEXT",
+		"departamento_norm": "TEXT",
+		"municipio":         "TEXT",
+		"distrito":          "TEXT",
+		"offshore":          "INTEGER",
+		"distancia_km":      "REAL",
+	}); err != nil {
+		return fmt.Errorf("error migrando la tabla sismos: %w", err)
+	}
+	if err := backfillDepartamentoNorm(db); err != nil {
+		return fmt.Errorf("error migrando la tabla sismos: %w", err)
+	}
+
+	// El filtro por departamento usa la columna normalizada: COLLATE NOCASE
+	// solo ignora mayúsculas ASCII y no las tildes
+	for _, stmt := range []string{
+		`DROP INDEX IF EXISTS idx_sismos_departamento;`,
+		`CREATE INDEX IF NOT EXISTS idx_sismos_departamento_norm ON sismos (departamento_norm);`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("error creando índice de sismos: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// backfillDepartamentoNorm completa departamento_norm en los registros guardados
+// antes de que existiera la columna
+func backfillDepartamentoNorm(db *sql.DB) error {
+	rows, err := db.Query(`SELECT DISTINCT departamento FROM sismos
+        WHERE departamento IS NOT NULL AND departamento_norm IS NULL;`)
+	if err != nil {
+		return fmt.Errorf("error leyendo departamentos de sismos: %w", err)
+	}
+
+	var departamentos []string
+	for rows.Next() {
+		var departamento string
+		if err := rows.Scan(&departamento); err != nil {
+			rows.Close()
+			return fmt.Errorf("error leyendo departamentos de sismos: %w", err)
+		}
+		departamentos = append(departamentos, departamento)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error leyendo departamentos de sismos: %w", err)
+	}
+
+	for _, departamento := range departamentos {
+		if _, err := db.Exec(`UPDATE sismos SET departamento_norm = ?
+            WHERE departamento = ? AND departamento_norm IS NULL;`,
+			utils.NormalizeText(departamento), departamento); err != nil {
+			return fmt.Errorf("error normalizando departamento %q: %w", departamento, err)
+		}
+	}
+	return nil
+}
+
+// ensureColumns agrega a una tabla existente las columnas que aún no tiene
+func ensureColumns(db *sql.DB, table string, columns map[string]string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s);", table))
+	if err != nil {
+		return fmt.Errorf("error leyendo columnas de %s: %w", table, err)
+	}
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &pk); err != nil {
+			rows.Close()
+			return fmt.Errorf("error leyendo columnas de %s: %w", table, err)
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error leyendo columnas de %s: %w", table, err)
+	}
+
+	for name, colType := range columns {
+		if existing[name] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, name, colType)); err != nil {
+			return fmt.Errorf("error agregando columna %s.%s: %w", table, name, err)
+		}
+	}
 	return nil
 }
@@ -7,6 +7,7 @@ import (
 	"chivomap.com/cache"
 	"chivomap.com/interfaces"
 	"chivomap.com/services"
+	"chivomap.com/services/scraping"
 )
 
 // Container holds all application dependencies
@@ -16,6 +17,8 @@ type Container struct {
 	CensoDB     interfaces.DatabaseService
 	Logger      interfaces.Logger
 	StaticCache interfaces.StaticCacheService
+	Datasets    interfaces.DatasetRegistry
+	SismosFeed  interfaces.SismosFeed
 }
 
 // NewContainer creates a new dependency injection container
@@ -37,15 +40,23 @@ func NewContainer(config interfaces.ConfigService, db, censoDB *sql.DB) (*Contai
 	// Create static cache service
 	staticCache := cache.NewStaticFileCache(config.GetAssetsDir())
 
+	// Create registry of every geographic layer in the assets directory
+	datasets := cache.NewDatasetRegistry(config.GetAssetsDir(), staticCache)
+
 	// Create logger service
 	logger := services.NewLogger()
 
+	// Create live earthquake subscription (started by the caller)
+	sismosFeed := scraping.NewSubscriber()
+
 	return &Container{
 		Config:      config,
 		DB:          dbService,
 		CensoDB:     censoDBService,
 		Logger:      logger,
 		StaticCache: staticCache,
+		Datasets:    datasets,
+		SismosFeed:  sismosFeed,
 	}, nil
 }
 
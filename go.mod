@@ -5,7 +5,9 @@ go 1.24
 toolchain go1.24.0
 
 require (
+	github.com/fasthttp/websocket v1.5.3
 	github.com/gofiber/fiber/v2 v2.52.6
+	github.com/gofiber/websocket/v2 v2.2.1
 	github.com/joho/godotenv v1.5.1
 	github.com/playwright-community/playwright-go v0.5200.1
 	github.com/swaggo/fiber-swagger v1.3.0
@@ -48,6 +50,7 @@ require (
 	github.com/r3labs/sse/v2 v2.10.0 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
 	github.com/robertkrimen/otto v0.0.0-20180617131154-15f95af6e78d // indirect
+	github.com/savsgio/gotils v0.0.0-20230208104028-c358bd845dee // indirect
 	github.com/swaggo/files v1.0.1 // indirect
 	github.com/teivah/onecontext v1.3.0 // indirect
 	github.com/tinylib/msgp v1.2.5 // indirect
@@ -1,40 +1,77 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
+	"unicode/utf8"
 	
 	"chivomap.com/interfaces"
 	"chivomap.com/services"
+	"chivomap.com/services/export"
 	"chivomap.com/services/geospatial"
+	"chivomap.com/spatial"
 	"chivomap.com/types"
 	"chivomap.com/utils"
 	"github.com/gofiber/fiber/v2"
 )
 
+// maxCachedFilters limita los resultados de /geo/filter cacheados por formato;
+// al superarlo se descartan los usados hace más tiempo
+const maxCachedFilters = 512
+
 // GeoHandler maneja los endpoints relacionados con datos geoespaciales
 type GeoHandler struct {
 	deps         *Dependencies
 	geoDataCache *services.CacheService[*types.GeoData]
-	municCache   *services.CacheService[map[string]*types.GeoFeatureCollection]
+	municCache   *services.LRUCache[string, *types.GeoFeatureCollection]
+	topoCache    *services.LRUCache[string, *types.TopoJSON]
+	hierarchy    *services.CacheService[*types.GeoHierarchy]
 	cacheMutex   sync.RWMutex // Protege operaciones de cache
 }
 
 // Dependencies holds the dependencies for handlers
 type Dependencies struct {
+	// Ctx se cancela cuando el servidor inicia su cierre
+	Ctx         context.Context
 	Config      interfaces.ConfigService
 	DB          interfaces.DatabaseService
 	CensoDB     interfaces.DatabaseService
 	StaticCache interfaces.StaticCacheService
+	Datasets    interfaces.DatasetRegistry
+	SismosFeed  interfaces.SismosFeed
 	Logger      interfaces.Logger
 }
 
 // NewGeoHandler crea una nueva instancia de GeoHandler
 func NewGeoHandler(deps *Dependencies) *GeoHandler {
-	return &GeoHandler{
+	h := &GeoHandler{
 		deps:         deps,
 		geoDataCache: services.NewCacheService[*types.GeoData](60), // 1 hora
-		municCache:   services.NewCacheService[map[string]*types.GeoFeatureCollection](60),
+		municCache:   services.NewLRUCache[string, *types.GeoFeatureCollection](maxCachedFilters),
+		topoCache:    services.NewLRUCache[string, *types.TopoJSON](maxCachedFilters),
+		hierarchy:    services.NewCacheService[*types.GeoHierarchy](60),
 	}
+	deps.StaticCache.OnReload(h.clearCaches)
+	return h
+}
+
+// clearCaches descarta las respuestas cacheadas cuando cambia el dataset
+func (h *GeoHandler) clearCaches(version string) {
+	h.cacheMutex.Lock()
+	defer h.cacheMutex.Unlock()
+
+	h.geoDataCache.Clear()
+	h.municCache.Clear()
+	h.topoCache.Clear()
+	h.hierarchy.Clear()
+	utils.Info("Caché geo vaciada por cambio de dataset (versión %s)", version)
 }
 
 // GetMunicipios maneja el endpoint para filtrar municipios
@@ -44,6 +81,14 @@ func NewGeoHandler(deps *Dependencies) *GeoHandler {
 // @Produce json
 // @Param query query string true "Cadena de búsqueda"
 // @Param whatIs query string true "Tipo de filtro: D (departamentos), M (municipios), NAM (nombres/ubicaciones)"
+// @Param level query string false "Nivel administrativo: distrito (por defecto), municipio o departamento (unión de sus distritos)"
+// @Param tolerance query number false "Tolerancia de simplificación en grados (0-1)"
+// @Param zoom query int false "Zoom del mapa (0-22); alternativa a tolerance"
+// @Param format query string false "Formato de salida: geojson (por defecto), topojson, o descarga en kml, shp (zip), gpkg o csv (WKT)"
+// @Param crs query string false "Sistema de coordenadas de salida: EPSG:4326 (por defecto), EPSG:5460 (El Salvador Lambert) o EPSG:32616 (UTM 16N)"
+// @Param precision query int false "Decimales de las coordenadas GeoJSON (0-15)"
+// @Param encoding query string false "Codificación de coordenadas GeoJSON: delta (enteros cuantizados con deltas, requiere precision: máximo 7 en grados y 3 en metros)"
+// @Param include query string false "Propiedades adicionales: metrics (area_km2, perimeter_km, centroid, label_point)"
 // @Success 200 {object} GeoFilterResponse "Resultados filtrados"
 // @Failure 400 {object} ErrorResponse "Parámetros inválidos"
 // @Failure 500 {object} ErrorResponse "Error interno"
@@ -61,39 +106,159 @@ func (h *GeoHandler) GetMunicipios(c *fiber.Ctx) error {
 			"Parámetros inválidos. 'query' debe ser una cadena válida (máx 100 chars) y 'whatIs' debe ser: D, M, o NAM")
 	}
 
-	// Usar valores validados
-	cacheKey := validatedWhatIs + ":" + validatedQuery
+	level, msg, ok := parseLevel(c)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+	if !geospatial.LevelHasProperty(level, validatedWhatIs) {
+		return utils.RespondWithError(c, fiber.StatusBadRequest,
+			"Parámetro 'whatIs' inválido para level="+level+": debe ser "+strings.Join(geospatial.LevelProperties[level], " o "))
+	}
+
+	tolerance, msg, ok := parseTolerance(c)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	includeMetrics, msg, ok := parseInclude(c)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	format, msg, ok := parseFormat(c, true)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	crs, msg, ok := parseCRS(c, format)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	encoding, msg, ok := parseEncoding(c, format, crs)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	// Usar valores validados; la versión del dataset evita reutilizar
+	// resultados calculados con datos anteriores a una recarga
+	cacheKey := h.deps.StaticCache.Version() + ":" + level + ":" + validatedWhatIs + ":" + validatedQuery + ":" + strconv.FormatFloat(tolerance, 'g', -1, 64)
+
+	if format == "topojson" {
+		return h.sendTopoJSON(c, cacheKey, validatedQuery, validatedWhatIs, level, tolerance, crs, includeMetrics)
+	}
 	
-	// Check cache with read lock
-	h.cacheMutex.RLock()
-	if cached, ok := h.municCache.Get(); ok {
-		if data, exists := cached[cacheKey]; exists {
-			h.cacheMutex.RUnlock()
-			return utils.SendResponse(c, data)
-		}
+	if data, ok := h.municCache.Get(cacheKey); ok {
+		return h.sendCollection(c, data, level, format, crs, encoding, nil, includeMetrics)
 	}
-	h.cacheMutex.RUnlock()
 
 	// Los valores ya están validados y en el formato correcto (D, M, NAM)
-	data, err := geospatial.GetMunicipios(h.deps.StaticCache, validatedQuery, validatedWhatIs)
+	data, err := geospatial.GetMunicipios(h.deps.StaticCache, validatedQuery, validatedWhatIs, level, tolerance)
 	if err != nil {
 		utils.Error("Error al obtener municipios: %v", err)
 		return utils.RespondWithError(c, fiber.StatusInternalServerError, err.Error())
 	}
 
-	// Update cache with write lock
-	h.cacheMutex.Lock()
-	cached, _ := h.municCache.Get()
-	if cached == nil {
-		cached = make(map[string]*types.GeoFeatureCollection)
+	h.municCache.Add(cacheKey, data)
+
+	return h.sendCollection(c, data, level, format, crs, encoding, nil, includeMetrics)
+}
+
+// sendTopoJSON responde al filtro con una topología que solo incluye los arcs
+// usados, proyectada al sistema pedido
+func (h *GeoHandler) sendTopoJSON(c *fiber.Ctx, cacheKey, query, whatIs, level string, tolerance float64, crs *spatial.CRS, includeMetrics bool) error {
+	data, exists := h.topoCache.Get(cacheKey)
+	if !exists {
+		var err error
+		data, err = geospatial.FilterTopoJSON(h.deps.StaticCache, query, whatIs, level, tolerance)
+		if err != nil {
+			utils.Error("Error al filtrar TopoJSON: %v", err)
+			return utils.RespondWithError(c, fiber.StatusInternalServerError, err.Error())
+		}
+		h.topoCache.Add(cacheKey, data)
+	}
+
+	if includeMetrics {
+		withMetrics, err := geospatial.TopoJSONWithMetrics(h.deps.StaticCache, data, level)
+		if err != nil {
+			utils.Error("Error al agregar métricas: %v", err)
+			return utils.RespondWithError(c, fiber.StatusInternalServerError,
+				"No se pudieron calcular las métricas")
+		}
+		data = withMetrics
+	}
+
+	projected, err := geospatial.ProjectTopoJSON(data, crs)
+	if err != nil {
+		utils.Error("Error al proyectar TopoJSON a EPSG:%d: %v", crs.Code, err)
+		return utils.RespondWithError(c, fiber.StatusInternalServerError,
+			"No se pudieron proyectar los datos")
 	}
-	cached[cacheKey] = data
-	h.municCache.Set(cached)
-	h.cacheMutex.Unlock()
+	setContentCRS(c, crs)
+	return utils.SendResponse(c, projected)
+}
 
+// sendCollection responde con la colección, agregando las métricas de cada
+// feature (del nivel indicado) si se pidieron, filtrada y recortada con query
+// (si no es nil) y proyectada al sistema pedido, en GeoJSON (con la
+// codificación de coordenadas pedida) o como descarga en un formato de
+// exportación. La colección cacheada no se modifica.
+func (h *GeoHandler) sendCollection(c *fiber.Ctx, data *types.GeoFeatureCollection, level, format string, crs *spatial.CRS, encoding *types.CoordinateEncoding, query *geospatial.FeatureQuery, includeMetrics bool) error {
+	if includeMetrics {
+		features, err := geospatial.WithMetrics(h.deps.StaticCache, data.Features, level)
+		if err != nil {
+			utils.Error("Error al agregar métricas: %v", err)
+			return utils.RespondWithError(c, fiber.StatusInternalServerError,
+				"No se pudieron calcular las métricas")
+		}
+		data = &types.GeoFeatureCollection{Type: data.Type, Features: features}
+	}
+	// El filtro va después de las métricas para poder filtrar por area_km2
+	if query != nil {
+		data = &types.GeoFeatureCollection{Type: data.Type, Features: query.Apply(data.Features)}
+	}
+	data = geospatial.ProjectCollection(data, crs)
+	if export.Supported(format) {
+		return sendExport(c, data.Features, format, exportName(c, level), crs)
+	}
+	if encoding != nil {
+		encoded := *data
+		encoded.Encoding = encoding
+		data = &encoded
+	}
+	setContentCRS(c, crs)
 	return utils.SendResponse(c, data)
 }
 
+// sendExport responde con los features como un archivo descargable. El
+// archivo se genera completo antes de responder, así un error retorna 500 en
+// vez de una descarga truncada.
+func sendExport(c *fiber.Ctx, features []types.GeoFeature, format, name string, crs *spatial.CRS) error {
+	var file bytes.Buffer
+	if err := export.Write(&file, format, name, features, crs); err != nil {
+		utils.Error("Error exportando %s como %s: %v", name, format, err)
+		return utils.RespondWithError(c, fiber.StatusInternalServerError,
+			"No se pudo generar el archivo "+format)
+	}
+
+	setContentCRS(c, crs)
+	c.Set(fiber.HeaderContentType, export.ContentType(format))
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+export.FileName(name, format)+`"`)
+	return c.Send(file.Bytes())
+}
+
+// exportName arma el nombre del archivo exportado a partir del nivel y de la
+// consulta o el ID pedidos ("chivomap-distrito-san-salvador")
+func exportName(c *fiber.Ctx, level string) string {
+	name := "chivomap-" + level
+	for _, value := range []string{c.Query("query"), pathParam(c, "id")} {
+		if slug := utils.Slugify(value); slug != "" {
+			return name + "-" + slug
+		}
+	}
+	return name
+}
+
 // GetGeoData maneja el endpoint para obtener datos geográficos
 // @Summary Obtiene datos geográficos
 // @Description Retorna datos geográficos completos de El Salvador
@@ -110,6 +275,7 @@ func (h *GeoHandler) GetGeoData(c *fiber.Ctx) error {
 		return utils.SendResponse(c, data)
 	}
 
+	version := h.deps.StaticCache.Version()
 	data, err := geospatial.GetGeoData(h.deps.StaticCache)
 	if err != nil {
 		utils.Error("Error al obtener geo data: %v", err)
@@ -117,20 +283,898 @@ func (h *GeoHandler) GetGeoData(c *fiber.Ctx) error {
 			"No se pudieron obtener los datos")
 	}
 
-	h.geoDataCache.Set(data)
+	h.setIfCurrent(version, func() { h.geoDataCache.Set(data) })
 	return utils.SendResponse(c, data)
 }
 
+// ReverseGeocode maneja el endpoint para ubicar coordenadas arbitrarias
+// @Summary Geocodificación inversa
+// @Description Retorna el departamento, municipio y distrito que contienen el punto. Si ningún polígono lo contiene, retorna el más cercano con offshore=true y la distancia a su borde, siempre que esté a menos de maxDistanceKm.
+// @Tags geo
+// @Produce json
+// @Param lat query number true "Latitud (WGS84)"
+// @Param lon query number true "Longitud (WGS84)"
+// @Param geometry query bool false "Incluir la geometría del distrito"
+// @Param maxDistanceKm query number false "Distancia máxima al territorio en km para puntos offshore (0-500, por defecto 22.224: 12 millas náuticas)"
+// @Param crs query string false "Sistema de coordenadas de salida: EPSG:4326 (por defecto), EPSG:5460 (El Salvador Lambert) o EPSG:32616 (UTM 16N)"
+// @Success 200 {object} GeoReverseResponse "Unidad administrativa"
+// @Failure 400 {object} ErrorResponse "Parámetros inválidos"
+// @Failure 404 {object} ErrorResponse "Sin datos geoespaciales o punto fuera de la distancia máxima"
+// @Failure 500 {object} ErrorResponse "Error interno"
+// @Router /geo/reverse [get]
+func (h *GeoHandler) ReverseGeocode(c *fiber.Ctx) error {
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lon, errLon := strconv.ParseFloat(c.Query("lon"), 64)
+	if errLat != nil || errLon != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return utils.RespondWithError(c, fiber.StatusBadRequest,
+			"Parámetros inválidos. 'lat' y 'lon' deben ser coordenadas WGS84 válidas")
+	}
+
+	maxDistance := geospatial.TerritorialWatersKm
+	if raw := c.Query("maxDistanceKm"); raw != "" {
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(value) || value < 0 || value > 500 {
+			return utils.RespondWithError(c, fiber.StatusBadRequest,
+				"Parámetro 'maxDistanceKm' inválido. Debe ser un número entre 0 y 500")
+		}
+		maxDistance = value
+	}
+
+	crs, msg, ok := parseCRS(c, "geojson")
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	ubicacion, feature, err := geospatial.ReverseGeocode(h.deps.StaticCache, lon, lat, maxDistance)
+	if err != nil {
+		utils.Error("Error en geocodificación inversa: %v", err)
+		return utils.RespondWithError(c, fiber.StatusInternalServerError,
+			"No se pudieron obtener los datos")
+	}
+	if ubicacion == nil {
+		return utils.RespondWithError(c, fiber.StatusNotFound, "No hay features disponibles")
+	}
+	if feature == nil {
+		return utils.RespondWithError(c, fiber.StatusNotFound,
+			"El punto está fuera del territorio y de sus aguas territoriales")
+	}
+
+	response := GeoReverseResponse{
+		Ubicacion:  *ubicacion,
+		Properties: feature.Properties,
+	}
+	if c.QueryBool("geometry") {
+		response.Geometry = crs.ProjectGeometry(feature.Geometry)
+	}
+
+	setContentCRS(c, crs)
+	return utils.SendResponse(c, response)
+}
+
+// GetFeatures maneja el endpoint para obtener los features dentro de un rectángulo
+// @Summary Features por bounding box
+// @Description Retorna los features cuyo polígono intersecta el rectángulo indicado, filtrados opcionalmente por sus propiedades. Sin bbox, si se usa where, fields o geometry, retorna todos los features del nivel que cumplen where.
+// @Tags geo
+// @Produce json
+// @Param bbox query string false "Rectángulo: minLon,minLat,maxLon,maxLat (obligatorio salvo que se use where, fields o geometry)"
+// @Param level query string false "Nivel administrativo: distrito (por defecto), municipio o departamento (unión de sus distritos)"
+// @Param tolerance query number false "Tolerancia de simplificación en grados (0-1)"
+// @Param zoom query int false "Zoom del mapa (0-22); alternativa a tolerance"
+// @Param include query string false "Propiedades adicionales: metrics (area_km2, perimeter_km, centroid, label_point)"
+// @Param format query string false "Formato de salida: geojson (por defecto), o descarga en kml, shp (zip), gpkg o csv (WKT)"
+// @Param crs query string false "Sistema de coordenadas de salida: EPSG:4326 (por defecto), EPSG:5460 (El Salvador Lambert) o EPSG:32616 (UTM 16N)"
+// @Param precision query int false "Decimales de las coordenadas GeoJSON (0-15)"
+// @Param encoding query string false "Codificación de coordenadas GeoJSON: delta (enteros cuantizados con deltas, requiere precision: máximo 7 en grados y 3 en metros)"
+// @Param where query string false "Filtro por propiedades: clave:operador:valor separados por comas (operadores eq, ne, in con valores separados por |, contains, gt, gte, lt, lte); una coma o barra dentro de un valor, o dos puntos dentro de una clave, se escapan con barra invertida"
+// @Param fields query string false "Propiedades a retornar, separadas por comas (por defecto todas)"
+// @Param geometry query bool false "false para retornar los features sin geometría"
+// @Success 200 {object} GeoFilterResponse "Features que intersectan el rectángulo"
+// @Failure 400 {object} ErrorResponse "Parámetros inválidos"
+// @Failure 500 {object} ErrorResponse "Error interno"
+// @Router /geo/features [get]
+func (h *GeoHandler) GetFeatures(c *fiber.Ctx) error {
+	// Sin bbox se consultan todos los features, solo si se pidió una consulta
+	// por propiedades ('where', 'fields' o 'geometry')
+	raw := c.Query("bbox")
+	if raw == "" {
+		query, msg, ok := parseFeatureQuery(c)
+		if !ok {
+			return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+		}
+		if query != nil {
+			return h.sendIntersecting(c, nil)
+		}
+	}
+
+	bbox, ok := utils.ValidateBBox(raw)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest,
+			"Parámetro 'bbox' inválido: use minLon,minLat,maxLon,maxLat (opcional si se usa 'where', 'fields' o 'geometry')")
+	}
+
+	shape := spatial.ShapeFromBBox(bbox)
+	return h.sendIntersecting(c, &shape)
+}
+
+// QueryFeatures maneja el endpoint para obtener los features que intersectan una geometría
+// @Summary Features que intersectan una geometría
+// @Description Retorna los features cuyo polígono intersecta la geometría GeoJSON enviada (Point, LineString, Polygon, sus variantes Multi, GeometryCollection o un Feature)
+// @Tags geo
+// @Accept json
+// @Produce json
+// @Param geometry body object true "Geometría GeoJSON en WGS84"
+// @Param level query string false "Nivel administrativo: distrito (por defecto), municipio o departamento (unión de sus distritos)"
+// @Param tolerance query number false "Tolerancia de simplificación en grados (0-1)"
+// @Param zoom query int false "Zoom del mapa (0-22); alternativa a tolerance"
+// @Param include query string false "Propiedades adicionales: metrics (area_km2, perimeter_km, centroid, label_point)"
+// @Param format query string false "Formato de salida: geojson (por defecto), o descarga en kml, shp (zip), gpkg o csv (WKT)"
+// @Param crs query string false "Sistema de coordenadas de salida: EPSG:4326 (por defecto), EPSG:5460 (El Salvador Lambert) o EPSG:32616 (UTM 16N)"
+// @Param precision query int false "Decimales de las coordenadas GeoJSON (0-15)"
+// @Param encoding query string false "Codificación de coordenadas GeoJSON: delta (enteros cuantizados con deltas, requiere precision: máximo 7 en grados y 3 en metros)"
+// @Param where query string false "Filtro por propiedades: clave:operador:valor separados por comas (operadores eq, ne, in con valores separados por |, contains, gt, gte, lt, lte); una coma o barra dentro de un valor, o dos puntos dentro de una clave, se escapan con barra invertida"
+// @Param fields query string false "Propiedades a retornar, separadas por comas (por defecto todas)"
+// @Param geometry query bool false "false para retornar los features sin geometría"
+// @Success 200 {object} GeoFilterResponse "Features que intersectan la geometría"
+// @Failure 400 {object} ErrorResponse "Geometría inválida"
+// @Failure 413 {object} ErrorResponse "Geometría con más de 10000 posiciones"
+// @Failure 500 {object} ErrorResponse "Error interno"
+// @Router /geo/features [post]
+func (h *GeoHandler) QueryFeatures(c *fiber.Ctx) error {
+	var geometry map[string]interface{}
+	if err := json.Unmarshal(c.Body(), &geometry); err != nil {
+		return utils.RespondWithError(c, fiber.StatusBadRequest,
+			"El cuerpo debe ser una geometría GeoJSON válida")
+	}
+
+	shape, err := spatial.ParseShape(geometry)
+	if errors.Is(err, spatial.ErrShapeTooLarge) {
+		return utils.RespondWithError(c, fiber.StatusRequestEntityTooLarge,
+			"Geometría demasiado grande: "+err.Error())
+	}
+	if err != nil {
+		return utils.RespondWithError(c, fiber.StatusBadRequest,
+			"Geometría inválida: "+err.Error())
+	}
+	if !shape.Valid() {
+		return utils.RespondWithError(c, fiber.StatusBadRequest,
+			"Geometría inválida: las coordenadas deben estar en WGS84 (lon, lat)")
+	}
+
+	return h.sendIntersecting(c, &shape)
+}
+
+// GetFeatureByID maneja el endpoint para obtener un feature por su ID
+// @Summary Feature por ID
+// @Description Retorna el feature con el ID indicado: el código oficial si existe o el ID jerárquico (departamento.municipio.distrito). Con level=municipio o departamento el ID es el de la jerarquía (la-libertad.la-libertad-costa o la-libertad).
+// @Tags geo
+// @Produce json
+// @Param id path string true "ID del feature, por ejemplo la-libertad.la-libertad-costa.isla-tasajera"
+// @Param level query string false "Nivel administrativo: distrito (por defecto), municipio o departamento (unión de sus distritos)"
+// @Param tolerance query number false "Tolerancia de simplificación en grados (0-1)"
+// @Param zoom query int false "Zoom del mapa (0-22); alternativa a tolerance"
+// @Param include query string false "Propiedades adicionales: metrics (area_km2, perimeter_km, centroid, label_point)"
+// @Param format query string false "Formato de salida: geojson (por defecto), o descarga en kml, shp (zip), gpkg o csv (WKT)"
+// @Param crs query string false "Sistema de coordenadas de salida: EPSG:4326 (por defecto), EPSG:5460 (El Salvador Lambert) o EPSG:32616 (UTM 16N)"
+// @Param precision query int false "Decimales de las coordenadas GeoJSON (0-15)"
+// @Param encoding query string false "Codificación de coordenadas GeoJSON: delta (enteros cuantizados con deltas, requiere precision: máximo 7 en grados y 3 en metros)"
+// @Success 200 {object} types.GeoFeature "Feature"
+// @Failure 400 {object} ErrorResponse "Parámetros inválidos"
+// @Failure 404 {object} ErrorResponse "Feature no encontrado"
+// @Failure 500 {object} ErrorResponse "Error interno"
+// @Router /geo/features/{id} [get]
+func (h *GeoHandler) GetFeatureByID(c *fiber.Ctx) error {
+	level, msg, ok := parseLevel(c)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	tolerance, msg, ok := parseTolerance(c)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	includeMetrics, msg, ok := parseInclude(c)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	format, msg, ok := parseFormat(c, false)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	crs, msg, ok := parseCRS(c, format)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	encoding, msg, ok := parseEncoding(c, format, crs)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	feature, err := geospatial.FeatureByID(h.deps.StaticCache, pathParam(c, "id"), level, tolerance)
+	if err != nil {
+		utils.Error("Error al obtener feature: %v", err)
+		return utils.RespondWithError(c, fiber.StatusInternalServerError,
+			"No se pudieron obtener los datos")
+	}
+	if feature == nil {
+		return utils.RespondWithError(c, fiber.StatusNotFound, "Feature no encontrado")
+	}
+
+	if includeMetrics {
+		features, err := geospatial.WithMetrics(h.deps.StaticCache, []types.GeoFeature{*feature}, level)
+		if err != nil {
+			utils.Error("Error al agregar métricas: %v", err)
+			return utils.RespondWithError(c, fiber.StatusInternalServerError,
+				"No se pudieron calcular las métricas")
+		}
+		feature = &features[0]
+	}
+
+	features := geospatial.ProjectFeatures([]types.GeoFeature{*feature}, crs)
+	if export.Supported(format) {
+		return sendExport(c, features, format, exportName(c, level), crs)
+	}
+	features[0].Encoding = encoding
+	setContentCRS(c, crs)
+	return utils.SendResponse(c, features[0])
+}
+
+// GetFeatureMetrics maneja el endpoint de métricas de un feature
+// @Summary Métricas de un feature
+// @Description Retorna el área (km²), el perímetro (km), el centroide y el punto de etiqueta (polo de inaccesibilidad, siempre interior) del feature, calculados sobre la geometría original
+// @Tags geo
+// @Produce json
+// @Param id path string true "ID del feature"
+// @Param level query string false "Nivel administrativo: distrito (por defecto), municipio o departamento (unión de sus distritos)"
+// @Param crs query string false "Sistema de coordenadas de salida: EPSG:4326 (por defecto), EPSG:5460 (El Salvador Lambert) o EPSG:32616 (UTM 16N)"
+// @Success 200 {object} FeatureMetricsResponse "Métricas"
+// @Failure 400 {object} ErrorResponse "Parámetros inválidos"
+// @Failure 404 {object} ErrorResponse "Feature no encontrado"
+// @Failure 500 {object} ErrorResponse "Error interno"
+// @Router /geo/features/{id}/metrics [get]
+func (h *GeoHandler) GetFeatureMetrics(c *fiber.Ctx) error {
+	level, msg, ok := parseLevel(c)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	crs, msg, ok := parseCRS(c, "geojson")
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	metrics, feature, err := geospatial.MetricsByID(h.deps.StaticCache, pathParam(c, "id"), level)
+	if err != nil {
+		utils.Error("Error al calcular métricas: %v", err)
+		return utils.RespondWithError(c, fiber.StatusInternalServerError,
+			"No se pudieron calcular las métricas")
+	}
+	if metrics == nil {
+		return utils.RespondWithError(c, fiber.StatusNotFound, "Feature no encontrado")
+	}
+
+	setContentCRS(c, crs)
+	return utils.SendResponse(c, FeatureMetricsResponse{
+		ID:             feature.ID,
+		Properties:     feature.Properties,
+		FeatureMetrics: geospatial.ProjectMetrics(*metrics, crs),
+	})
+}
+
+// GetMetrics maneja el endpoint de métricas de todos los features
+// @Summary Métricas de todos los features
+// @Description Retorna el área, perímetro, centroide y punto de etiqueta de cada feature, sin geometrías
+// @Tags geo
+// @Produce json
+// @Param level query string false "Nivel administrativo: distrito (por defecto), municipio o departamento (unión de sus distritos)"
+// @Param crs query string false "Sistema de coordenadas de salida: EPSG:4326 (por defecto), EPSG:5460 (El Salvador Lambert) o EPSG:32616 (UTM 16N)"
+// @Success 200 {object} MetricsListResponse "Métricas por feature"
+// @Failure 400 {object} ErrorResponse "Parámetros inválidos"
+// @Failure 500 {object} ErrorResponse "Error interno"
+// @Router /geo/metrics [get]
+func (h *GeoHandler) GetMetrics(c *fiber.Ctx) error {
+	level, msg, ok := parseLevel(c)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	crs, msg, ok := parseCRS(c, "geojson")
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	geo, metrics, err := geospatial.AllMetrics(h.deps.StaticCache, level)
+	if err != nil {
+		utils.Error("Error al calcular métricas: %v", err)
+		return utils.RespondWithError(c, fiber.StatusInternalServerError,
+			"No se pudieron calcular las métricas")
+	}
+
+	response := MetricsListResponse{
+		Total:    len(geo.Features),
+		Features: make([]FeatureMetricsResponse, len(geo.Features)),
+	}
+	for i, feature := range geo.Features {
+		response.Features[i] = FeatureMetricsResponse{
+			ID:             feature.ID,
+			Properties:     feature.Properties,
+			FeatureMetrics: geospatial.ProjectMetrics(metrics[i], crs),
+		}
+	}
+	setContentCRS(c, crs)
+	return utils.SendResponse(c, response)
+}
+
+// GetNeighbors maneja el endpoint de unidades vecinas
+// @Summary Vecinos de una unidad administrativa
+// @Description Retorna los distritos, municipios o departamentos (según el nivel del ID) que comparten borde con la unidad indicada, con la longitud del borde compartido
+// @Tags geo
+// @Produce json
+// @Param id path string true "ID de un distrito (feature), municipio o departamento"
+// @Success 200 {object} NeighborsResponse "Vecinos"
+// @Failure 404 {object} ErrorResponse "Unidad no encontrada"
+// @Failure 500 {object} ErrorResponse "Error interno"
+// @Router /geo/features/{id}/neighbors [get]
+func (h *GeoHandler) GetNeighbors(c *fiber.Ctx) error {
+	unit, vecinos, err := geospatial.Neighbors(h.deps.StaticCache, pathParam(c, "id"))
+	if err != nil {
+		utils.Error("Error al obtener vecinos: %v", err)
+		return utils.RespondWithError(c, fiber.StatusInternalServerError,
+			"No se pudieron obtener los datos")
+	}
+	if unit == nil {
+		return utils.RespondWithError(c, fiber.StatusNotFound, "Unidad no encontrada")
+	}
+
+	return utils.SendResponse(c, NeighborsResponse{AdminUnit: *unit, Vecinos: vecinos})
+}
+
+// sendIntersecting responde con los features que intersectan la forma, o con
+// todos los del nivel si shape es nil, filtrados con 'where', 'fields' y 'geometry'
+func (h *GeoHandler) sendIntersecting(c *fiber.Ctx, shape *spatial.Shape) error {
+	level, msg, ok := parseLevel(c)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	tolerance, msg, ok := parseTolerance(c)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	includeMetrics, msg, ok := parseInclude(c)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	format, msg, ok := parseFormat(c, false)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	crs, msg, ok := parseCRS(c, format)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	encoding, msg, ok := parseEncoding(c, format, crs)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	query, msg, ok := parseFeatureQuery(c)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	var (
+		data *types.GeoFeatureCollection
+		err  error
+	)
+	if shape != nil {
+		data, err = geospatial.FeaturesIntersecting(h.deps.StaticCache, *shape, level, tolerance)
+	} else {
+		data, err = geospatial.LevelFeatures(h.deps.StaticCache, level, tolerance)
+	}
+	if err != nil {
+		utils.Error("Error en consulta espacial: %v", err)
+		return utils.RespondWithError(c, fiber.StatusInternalServerError,
+			"No se pudieron obtener los datos")
+	}
+
+	return h.sendCollection(c, data, level, format, crs, encoding, query, includeMetrics)
+}
+
+// GetValidation maneja el endpoint del reporte de validación de geometrías
+// @Summary Validación de geometrías
+// @Description Retorna los problemas encontrados al convertir el TopoJSON: arcs inválidos o inexistentes, anillos abiertos o degenerados (errores: el feature queda con geometry null y la propiedad geometria_invalida), auto-intersecciones y orientación contraria a RFC 7946 (advertencias)
+// @Tags geo
+// @Produce json
+// @Param severidad query string false "Filtrar problemas por severidad: error o advertencia"
+// @Success 200 {object} types.ValidationReport "Reporte de validación"
+// @Failure 400 {object} ErrorResponse "Parámetros inválidos"
+// @Failure 500 {object} ErrorResponse "Error interno"
+// @Router /geo/validation [get]
+func (h *GeoHandler) GetValidation(c *fiber.Ctx) error {
+	severidad := c.Query("severidad")
+	if severidad != "" && severidad != "error" && severidad != "advertencia" {
+		return utils.RespondWithError(c, fiber.StatusBadRequest,
+			"Parámetro 'severidad' inválido: debe ser error o advertencia")
+	}
+
+	report, err := h.deps.StaticCache.GetValidationReport()
+	if err != nil {
+		utils.Error("Error al obtener reporte de validación: %v", err)
+		return utils.RespondWithError(c, fiber.StatusInternalServerError,
+			"No se pudieron obtener los datos")
+	}
+	if severidad == "" {
+		return utils.SendResponse(c, report)
+	}
+
+	filtered := *report
+	filtered.Problemas = make([]types.GeometryIssue, 0)
+	for _, issue := range report.Problemas {
+		if issue.Severidad == severidad {
+			filtered.Problemas = append(filtered.Problemas, issue)
+		}
+	}
+	return utils.SendResponse(c, &filtered)
+}
+
+// GetDatasets maneja el endpoint de capas disponibles
+// @Summary Capas geográficas disponibles
+// @Description Lista las capas cargadas de ASSETS_DIR: cada objeto de cada archivo TopoJSON (archivo.objeto) y cada archivo GeoJSON, con sus tipos de geometría, propiedades, rectángulo envolvente y versión
+// @Tags geo
+// @Produce json
+// @Success 200 {object} DatasetsResponse "Capas"
+// @Router /geo/datasets [get]
+func (h *GeoHandler) GetDatasets(c *fiber.Ctx) error {
+	datasets := h.deps.Datasets.Datasets()
+	return utils.SendResponse(c, DatasetsResponse{
+		Total:    len(datasets),
+		Datasets: datasets,
+	})
+}
+
+// FilterDataset maneja el endpoint para filtrar una capa
+// @Summary Filtrar una capa geográfica
+// @Description Retorna los features de la capa cuya propiedad contiene la consulta (sin importar mayúsculas ni tildes) y que tocan el rectángulo indicado. Sin parámetros retorna la capa completa.
+// @Tags geo
+// @Produce json
+// @Param name path string true "Nombre de la capa, por ejemplo topo.collection o volcanes"
+// @Param query query string false "Texto a buscar"
+// @Param property query string false "Propiedad donde buscar; por defecto todas las de texto"
+// @Param bbox query string false "Rectángulo: minLon,minLat,maxLon,maxLat"
+// @Param format query string false "Formato de salida: geojson (por defecto), o descarga en kml, shp (zip), gpkg o csv (WKT)"
+// @Param crs query string false "Sistema de coordenadas de salida: EPSG:4326 (por defecto), EPSG:5460 (El Salvador Lambert) o EPSG:32616 (UTM 16N)"
+// @Success 200 {object} GeoFilterResponse "Features de la capa"
+// @Failure 400 {object} ErrorResponse "Parámetros inválidos"
+// @Failure 404 {object} ErrorResponse "Capa no encontrada"
+// @Failure 500 {object} ErrorResponse "Error interno"
+// @Router /geo/datasets/{name}/filter [get]
+func (h *GeoHandler) FilterDataset(c *fiber.Ctx) error {
+	format, msg, ok := parseFormat(c, false)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	crs, msg, ok := parseCRS(c, format)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, msg)
+	}
+
+	query, property := c.Query("query"), c.Query("property")
+	if query != "" {
+		validated, ok := utils.ValidateQuery(query)
+		if !ok {
+			return utils.RespondWithError(c, fiber.StatusBadRequest,
+				"Parámetro 'query' inválido: debe ser una cadena válida (máx 100 chars)")
+		}
+		query = validated
+	}
+	if property != "" {
+		validated, ok := utils.ValidateQuery(property)
+		if !ok {
+			return utils.RespondWithError(c, fiber.StatusBadRequest,
+				"Parámetro 'property' inválido: debe ser un nombre de propiedad válido (máx 100 chars)")
+		}
+		property = validated
+	}
+
+	var bbox *spatial.BBox
+	if raw := c.Query("bbox"); raw != "" {
+		parsed, ok := utils.ValidateBBox(raw)
+		if !ok {
+			return utils.RespondWithError(c, fiber.StatusBadRequest,
+				"Parámetro 'bbox' inválido: use minLon,minLat,maxLon,maxLat")
+		}
+		b := spatial.BBox(parsed)
+		bbox = &b
+	}
+
+	name := pathParam(c, "name")
+	data, ok := geospatial.FilterDataset(h.deps.Datasets, name, property, query, bbox)
+	if !ok {
+		return utils.RespondWithError(c, fiber.StatusNotFound, "Capa no encontrada")
+	}
+	data = geospatial.ProjectCollection(data, crs)
+	if export.Supported(format) {
+		return sendExport(c, data.Features, format, exportName(c, utils.Slugify(name)), crs)
+	}
+	setContentCRS(c, crs)
+	return utils.SendResponse(c, data)
+}
+
+// GetHierarchy maneja el endpoint del árbol administrativo
+// @Summary Jerarquía administrativa
+// @Description Retorna el árbol departamento → municipio → distrito construido a partir de las propiedades D, M y NAM, con IDs estables y totales
+// @Tags geo
+// @Produce json
+// @Success 200 {object} types.GeoHierarchy "Árbol administrativo"
+// @Failure 500 {object} ErrorResponse "Error interno"
+// @Router /geo/hierarchy [get]
+func (h *GeoHandler) GetHierarchy(c *fiber.Ctx) error {
+	hierarchy, err := h.getHierarchy()
+	if err != nil {
+		return utils.RespondWithError(c, fiber.StatusInternalServerError,
+			"No se pudieron obtener los datos")
+	}
+	return utils.SendResponse(c, hierarchy)
+}
+
+// GetDepartamentos maneja el endpoint que lista los departamentos
+// @Summary Lista de departamentos
+// @Description Retorna los departamentos con sus IDs y totales, sin sus hijos
+// @Tags geo
+// @Produce json
+// @Success 200 {object} DepartamentosResponse "Departamentos"
+// @Failure 500 {object} ErrorResponse "Error interno"
+// @Router /geo/departamentos [get]
+func (h *GeoHandler) GetDepartamentos(c *fiber.Ctx) error {
+	hierarchy, err := h.getHierarchy()
+	if err != nil {
+		return utils.RespondWithError(c, fiber.StatusInternalServerError,
+			"No se pudieron obtener los datos")
+	}
+	return utils.SendResponse(c, DepartamentosResponse{Departamentos: shallowUnits(hierarchy.Departamentos)})
+}
+
+// GetMunicipiosByDepartamento maneja el endpoint que lista los municipios de un departamento
+// @Summary Municipios de un departamento
+// @Description Retorna los municipios del departamento indicado por ID o nombre (sin importar mayúsculas ni tildes)
+// @Tags geo
+// @Produce json
+// @Param d path string true "ID o nombre del departamento"
+// @Success 200 {object} MunicipiosResponse "Municipios"
+// @Failure 404 {object} ErrorResponse "Departamento no encontrado"
+// @Failure 500 {object} ErrorResponse "Error interno"
+// @Router /geo/departamentos/{d}/municipios [get]
+func (h *GeoHandler) GetMunicipiosByDepartamento(c *fiber.Ctx) error {
+	hierarchy, err := h.getHierarchy()
+	if err != nil {
+		return utils.RespondWithError(c, fiber.StatusInternalServerError,
+			"No se pudieron obtener los datos")
+	}
+
+	departamento := geospatial.FindAdminUnit(hierarchy.Departamentos, pathParam(c, "d"))
+	if departamento == nil {
+		return utils.RespondWithError(c, fiber.StatusNotFound, "Departamento no encontrado")
+	}
+
+	return utils.SendResponse(c, MunicipiosResponse{
+		Departamento: shallowUnit(departamento),
+		Municipios:   shallowUnits(departamento.Hijos),
+	})
+}
+
+// GetDistritosByMunicipio maneja el endpoint que lista los distritos de un municipio
+// @Summary Distritos de un municipio
+// @Description Retorna los distritos del municipio indicado por ID o nombre dentro del departamento
+// @Tags geo
+// @Produce json
+// @Param d path string true "ID o nombre del departamento"
+// @Param m path string true "ID o nombre del municipio"
+// @Success 200 {object} DistritosResponse "Distritos"
+// @Failure 404 {object} ErrorResponse "Departamento o municipio no encontrado"
+// @Failure 500 {object} ErrorResponse "Error interno"
+// @Router /geo/departamentos/{d}/municipios/{m}/distritos [get]
+func (h *GeoHandler) GetDistritosByMunicipio(c *fiber.Ctx) error {
+	hierarchy, err := h.getHierarchy()
+	if err != nil {
+		return utils.RespondWithError(c, fiber.StatusInternalServerError,
+			"No se pudieron obtener los datos")
+	}
+
+	departamento := geospatial.FindAdminUnit(hierarchy.Departamentos, pathParam(c, "d"))
+	if departamento == nil {
+		return utils.RespondWithError(c, fiber.StatusNotFound, "Departamento no encontrado")
+	}
+	municipio := geospatial.FindAdminUnit(departamento.Hijos, pathParam(c, "m"))
+	if municipio == nil {
+		return utils.RespondWithError(c, fiber.StatusNotFound, "Municipio no encontrado")
+	}
+
+	return utils.SendResponse(c, DistritosResponse{
+		Departamento: shallowUnit(departamento),
+		Municipio:    shallowUnit(municipio),
+		Distritos:    shallowUnits(municipio.Hijos),
+	})
+}
+
+// Autocomplete maneja el endpoint de autocompletado de lugares
+// @Summary Autocompletado de lugares
+// @Description Sugiere departamentos, municipios y distritos sin importar mayúsculas ni tildes, tolerando errores de escritura. Los resultados se ordenan por calidad de la coincidencia y nivel.
+// @Tags geo
+// @Produce json
+// @Param q query string true "Texto a buscar (1-100 caracteres)"
+// @Param nivel query string false "Restringir a un nivel: departamento, municipio o distrito"
+// @Param limit query int false "Máximo de sugerencias (1-50, por defecto 10)"
+// @Success 200 {object} AutocompleteResponse "Sugerencias"
+// @Failure 400 {object} ErrorResponse "Parámetros inválidos"
+// @Failure 500 {object} ErrorResponse "Error interno"
+// @Router /geo/autocomplete [get]
+func (h *GeoHandler) Autocomplete(c *fiber.Ctx) error {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" || len(q) > 100 || !utf8.ValidString(q) {
+		return utils.RespondWithError(c, fiber.StatusBadRequest,
+			"Parámetro 'q' inválido: debe tener entre 1 y 100 caracteres")
+	}
+
+	nivel := c.Query("nivel")
+	switch nivel {
+	case "", geospatial.NivelDepartamento, geospatial.NivelMunicipio, geospatial.NivelDistrito:
+	default:
+		return utils.RespondWithError(c, fiber.StatusBadRequest,
+			"Parámetro 'nivel' inválido: debe ser departamento, municipio o distrito")
+	}
+
+	limit := c.QueryInt("limit", 10)
+	if limit < 1 || limit > 50 {
+		return utils.RespondWithError(c, fiber.StatusBadRequest,
+			"Parámetro 'limit' inválido: debe estar entre 1 y 50")
+	}
+
+	hierarchy, err := h.getHierarchy()
+	if err != nil {
+		return utils.RespondWithError(c, fiber.StatusInternalServerError,
+			"No se pudieron obtener los datos")
+	}
+
+	return utils.SendResponse(c, AutocompleteResponse{
+		Query:       q,
+		Sugerencias: geospatial.Autocomplete(hierarchy, q, nivel, limit),
+	})
+}
+
+// getHierarchy retorna el árbol administrativo cacheado, construyéndolo si es necesario
+func (h *GeoHandler) getHierarchy() (*types.GeoHierarchy, error) {
+	if hierarchy, ok := h.hierarchy.Get(); ok {
+		return hierarchy, nil
+	}
+
+	version := h.deps.StaticCache.Version()
+	hierarchy, err := geospatial.GetHierarchy(h.deps.StaticCache)
+	if err != nil {
+		utils.Error("Error al construir la jerarquía: %v", err)
+		return nil, err
+	}
+	h.setIfCurrent(version, func() { h.hierarchy.Set(hierarchy) })
+	return hierarchy, nil
+}
+
+// setIfCurrent guarda un resultado en caché solo si el dataset no cambió
+// mientras se calculaba (version es la vigente antes de calcularlo)
+func (h *GeoHandler) setIfCurrent(version string, set func()) {
+	h.cacheMutex.Lock()
+	defer h.cacheMutex.Unlock()
+	if version != "" && h.deps.StaticCache.Version() == version {
+		set()
+	}
+}
+
+// shallowUnit copia una unidad sin sus hijos
+func shallowUnit(unit *types.AdminUnit) types.AdminUnit {
+	copied := *unit
+	copied.Hijos = nil
+	return copied
+}
+
+// shallowUnits copia una lista de unidades sin sus hijos
+func shallowUnits(units []*types.AdminUnit) []types.AdminUnit {
+	result := make([]types.AdminUnit, len(units))
+	for i, unit := range units {
+		result[i] = shallowUnit(unit)
+	}
+	return result
+}
+
+// pathParam retorna un parámetro de ruta decodificado ("San%20Salvador" -> "San Salvador")
+func pathParam(c *fiber.Ctx, key string) string {
+	value := c.Params(key)
+	if decoded, err := url.PathUnescape(value); err == nil {
+		return decoded
+	}
+	return value
+}
+
+// parseLevel lee el nivel administrativo pedido con 'level'; por defecto distrito
+func parseLevel(c *fiber.Ctx) (string, string, bool) {
+	level := c.Query("level", geospatial.NivelDistrito)
+	if !geospatial.ValidLevel(level) {
+		return "", "Parámetro 'level' inválido: debe ser departamento, municipio o distrito", false
+	}
+	return level, "", true
+}
+
+// parseFormat lee el formato de salida: geojson (por defecto), topojson si el
+// endpoint lo admite, o uno de los formatos de exportación
+func parseFormat(c *fiber.Ctx, topojson bool) (string, string, bool) {
+	format := c.Query("format", "geojson")
+	if format == "geojson" || (topojson && format == "topojson") || export.Supported(format) {
+		return format, "", true
+	}
+	if topojson {
+		return "", "Parámetro 'format' inválido: debe ser geojson, topojson, kml, shp, gpkg o csv", false
+	}
+	return "", "Parámetro 'format' inválido: debe ser geojson, kml, shp, gpkg o csv", false
+}
+
+// parseCRS lee el sistema de coordenadas de salida con 'crs'; por defecto WGS84.
+// Las consultas (bbox, geometrías) siempre se reciben en WGS84.
+func parseCRS(c *fiber.Ctx, format string) (*spatial.CRS, string, bool) {
+	raw := c.Query("crs")
+	if raw == "" {
+		return spatial.WGS84, "", true
+	}
+	crs, ok := spatial.ParseCRS(raw)
+	if !ok {
+		return nil, "Parámetro 'crs' inválido: debe ser EPSG:4326 (WGS84), EPSG:5460 (El Salvador Lambert) o EPSG:32616 (UTM 16N)", false
+	}
+	if !export.SupportsCRS(format, crs) {
+		return nil, "Parámetro 'crs' inválido: el formato " + format + " solo admite EPSG:4326 (WGS84)", false
+	}
+	return crs, "", true
+}
+
+// parseEncoding lee la codificación de coordenadas de la salida GeoJSON:
+// 'precision' (decimales) y 'encoding=delta' (enteros cuantizados con deltas).
+// Con delta la precisión se limita según las unidades de crs. Retorna nil si
+// no se pidió ninguna.
+func parseEncoding(c *fiber.Ctx, format string, crs *spatial.CRS) (*types.CoordinateEncoding, string, bool) {
+	rawPrecision, rawEncoding := c.Query("precision"), c.Query("encoding")
+	if rawPrecision == "" && rawEncoding == "" {
+		return nil, "", true
+	}
+	if format != "geojson" {
+		return nil, "Los parámetros 'precision' y 'encoding' solo aplican a format=geojson", false
+	}
+
+	if rawPrecision == "" {
+		return nil, "encoding=delta requiere 'precision' (decimales de la cuadrícula)", false
+	}
+	precision, err := strconv.Atoi(rawPrecision)
+	if err != nil || precision < 0 || precision > 15 {
+		return nil, "Parámetro 'precision' inválido: debe ser un entero entre 0 y 15 (decimales)", false
+	}
+
+	switch rawEncoding {
+	case "":
+		return &types.CoordinateEncoding{Precision: precision}, "", true
+	case "delta":
+		limit, units := types.MaxDeltaPrecisionDegrees, "grados"
+		if !crs.Geographic() {
+			limit, units = types.MaxDeltaPrecisionMeters, "metros"
+		}
+		if precision > limit {
+			return nil, "Parámetro 'precision' inválido: con encoding=delta el máximo es " +
+				strconv.Itoa(limit) + " decimales en " + units, false
+		}
+		return &types.CoordinateEncoding{Precision: precision, Delta: true}, "", true
+	}
+	return nil, "Parámetro 'encoding' inválido: valores permitidos: delta", false
+}
+
+// setContentCRS declara el sistema de coordenadas de la respuesta con el
+// encabezado Content-Crs de OGC API - Features
+func setContentCRS(c *fiber.Ctx, crs *spatial.CRS) {
+	c.Set("Content-Crs", "<"+crs.URI()+">")
+}
+
+// parseFeatureQuery lee el filtro por propiedades ('where', que puede
+// repetirse), los campos a retornar ('fields') y si incluir la geometría
+// ('geometry'). Retorna nil si no se pidió ninguno.
+func parseFeatureQuery(c *fiber.Ctx) (*geospatial.FeatureQuery, string, bool) {
+	var where []string
+	for _, value := range c.Context().QueryArgs().PeekMulti("where") {
+		where = append(where, string(value))
+	}
+	rawFields, rawGeometry := c.Query("fields"), c.Query("geometry")
+	if len(where) == 0 && rawFields == "" && rawGeometry == "" {
+		return nil, "", true
+	}
+
+	conditions, err := geospatial.ParseWhere(where)
+	if err != nil {
+		return nil, "Parámetro 'where' inválido: " + err.Error(), false
+	}
+
+	geometry := true
+	if rawGeometry != "" {
+		if geometry, err = strconv.ParseBool(rawGeometry); err != nil {
+			return nil, "Parámetro 'geometry' inválido: debe ser true o false", false
+		}
+	}
+
+	return &geospatial.FeatureQuery{
+		Where:    conditions,
+		Fields:   geospatial.ParseFields(rawFields),
+		Geometry: geometry,
+	}, "", true
+}
+
+// parseInclude lee el parámetro 'include' (lista separada por comas). Por ahora
+// solo acepta "metrics"; retorna si se pidieron las métricas.
+func parseInclude(c *fiber.Ctx) (bool, string, bool) {
+	raw := c.Query("include")
+	if raw == "" {
+		return false, "", true
+	}
+
+	metrics := false
+	for _, part := range strings.Split(raw, ",") {
+		switch strings.TrimSpace(part) {
+		case "metrics":
+			metrics = true
+		case "":
+		default:
+			return false, "Parámetro 'include' inválido: valores permitidos: metrics", false
+		}
+	}
+	return metrics, "", true
+}
+
+// parseTolerance lee la simplificación pedida con 'tolerance' (grados) o 'zoom'.
+// 'tolerance' se ajusta al nivel de zoom con spatial.SnapTolerance. Retorna 0
+// si no se pidió simplificación.
+func parseTolerance(c *fiber.Ctx) (float64, string, bool) {
+	rawTolerance, rawZoom := c.Query("tolerance"), c.Query("zoom")
+
+	switch {
+	case rawTolerance != "" && rawZoom != "":
+		return 0, "Use solo uno de 'tolerance' o 'zoom'", false
+	case rawTolerance != "":
+		tolerance, err := strconv.ParseFloat(rawTolerance, 64)
+		if err != nil || !(tolerance >= 0 && tolerance <= 1) {
+			return 0, "Parámetro 'tolerance' inválido: debe ser un número entre 0 y 1 (grados)", false
+		}
+		// Solo hay un nivel de simplificación por zoom; así las claves de los
+		// caches no dependen de valores arbitrarios
+		return spatial.SnapTolerance(tolerance), "", true
+	case rawZoom != "":
+		zoom, err := strconv.Atoi(rawZoom)
+		if err != nil || zoom < 0 || zoom > 22 {
+			return 0, "Parámetro 'zoom' inválido: debe ser un entero entre 0 y 22", false
+		}
+		return spatial.ToleranceForZoom(zoom), "", true
+	}
+	return 0, "", true
+}
+
 // updateGeoDataCache actualiza la caché de datos geográficos en segundo plano
 func (h *GeoHandler) updateGeoDataCache() {
 	h.geoDataCache.SetUpdating(true)
 	defer h.geoDataCache.SetUpdating(false)
 
+	version := h.deps.StaticCache.Version()
 	newData, err := geospatial.GetGeoData(h.deps.StaticCache)
 	if err != nil {
 		utils.Error("Error al actualizar caché geo: %v", err)
 		return
 	}
 
-	h.geoDataCache.Set(newData)
+	h.setIfCurrent(version, func() { h.geoDataCache.Set(newData) })
 }
@@ -69,6 +69,13 @@ func (h *HealthHandler) HealthCheck(c *fiber.Ctx) error {
 		overallStatus = "DEGRADED"
 	}
 
+	// Verificar suscripción al hub de sismos
+	feedStatus := h.checkSismosFeed()
+	components["sismos_feed"] = feedStatus
+	if feedStatus.Status != "UP" {
+		overallStatus = "DEGRADED"
+	}
+
 	// Verificar cache
 	cacheStatus := h.checkCache()
 	components["cache"] = cacheStatus
@@ -129,12 +136,37 @@ func (h *HealthHandler) checkStaticFiles() HealthStatus {
 	return HealthStatus{
 		Status: "UP",
 		Details: map[string]interface{}{
-			"topo_file_size": fileInfo.Size(),
-			"topo_mod_time":  fileInfo.ModTime(),
+			"topo_file_size":  fileInfo.Size(),
+			"topo_mod_time":   fileInfo.ModTime(),
+			"dataset_version": h.deps.StaticCache.Version(),
 		},
 	}
 }
 
+// checkSismosFeed verifica la conexión persistente al hub de sismos del SNET
+func (h *HealthHandler) checkSismosFeed() HealthStatus {
+	if h.deps.SismosFeed == nil {
+		return HealthStatus{
+			Status:  "DOWN",
+			Message: "Suscripción a sismos no configurada",
+		}
+	}
+
+	status := h.deps.SismosFeed.Status()
+	if connected, _ := status["connected"].(bool); !connected {
+		return HealthStatus{
+			Status:  "DOWN",
+			Message: "Sin conexión al hub de sismos del SNET",
+			Details: status,
+		}
+	}
+
+	return HealthStatus{
+		Status:  "UP",
+		Details: status,
+	}
+}
+
 // checkCache verifica el estado del cache estático
 func (h *HealthHandler) checkCache() HealthStatus {
 	// Simplificamos la verificación del cache
@@ -25,6 +25,19 @@ type SismosRefreshResponse struct {
 	Data        []scraping.Sismo `json:"data"`
 }
 
+// SismosHistoryResponse representa una página del historial de sismos
+type SismosHistoryResponse struct {
+	TotalSismos int              `json:"totalSismos"`
+	Data        []scraping.Sismo `json:"data"`
+	NextCursor  string           `json:"nextCursor"`
+}
+
+// SismoUpdate representa un sismo nuevo o revisado publicado en tiempo real
+type SismoUpdate struct {
+	Tipo  string         `json:"tipo"` // "nuevo" o "revisado"
+	Sismo scraping.Sismo `json:"sismo"`
+}
+
 // GeoDataResponse representa la respuesta para datos geográficos
 type GeoDataResponse struct {
 	GeoData *types.GeoData `json:"geoData"`
@@ -36,6 +49,62 @@ type GeoFilterResponse struct {
 	Features []map[string]interface{} `json:"features"`
 }
 
+// GeoReverseResponse representa la unidad administrativa de un punto
+type GeoReverseResponse struct {
+	types.Ubicacion
+	Properties map[string]interface{} `json:"properties"`
+	Geometry   any                    `json:"geometry,omitempty"`
+}
+
+// DepartamentosResponse representa la lista de departamentos
+type DepartamentosResponse struct {
+	Departamentos []types.AdminUnit `json:"departamentos"`
+}
+
+// MunicipiosResponse representa los municipios de un departamento
+type MunicipiosResponse struct {
+	Departamento types.AdminUnit   `json:"departamento"`
+	Municipios   []types.AdminUnit `json:"municipios"`
+}
+
+// DistritosResponse representa los distritos de un municipio
+type DistritosResponse struct {
+	Departamento types.AdminUnit   `json:"departamento"`
+	Municipio    types.AdminUnit   `json:"municipio"`
+	Distritos    []types.AdminUnit `json:"distritos"`
+}
+
+// AutocompleteResponse representa las sugerencias del autocompletado
+type AutocompleteResponse struct {
+	Query       string                  `json:"query"`
+	Sugerencias []types.PlaceSuggestion `json:"sugerencias"`
+}
+
+// NeighborsResponse representa las unidades que comparten borde con otra
+type NeighborsResponse struct {
+	types.AdminUnit
+	Vecinos []types.Vecino `json:"vecinos"`
+}
+
+// FeatureMetricsResponse representa las métricas de un feature con sus propiedades
+type FeatureMetricsResponse struct {
+	ID         string                 `json:"id"`
+	Properties map[string]interface{} `json:"properties"`
+	types.FeatureMetrics
+}
+
+// DatasetsResponse representa las capas geográficas disponibles
+type DatasetsResponse struct {
+	Total    int                 `json:"total"`
+	Datasets []types.DatasetInfo `json:"datasets"`
+}
+
+// MetricsListResponse representa las métricas de todos los features
+type MetricsListResponse struct {
+	Total    int                      `json:"total"`
+	Features []FeatureMetricsResponse `json:"features"`
+}
+
 // ScrapeResponse representa la respuesta del endpoint de scraping
 type ScrapeResponse struct {
 	TotalItems int                  `json:"totalItems"`
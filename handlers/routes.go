@@ -3,6 +3,7 @@ package handlers
 import (
 	"chivomap.com/utils"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
 )
 
 // SetupRoutes configura todas las rutas de la API
@@ -14,11 +15,33 @@ func SetupRoutes(app *fiber.App, deps *Dependencies) {
 	sismosHandler := NewSismosHandler(deps)
 	app.Get("/sismos", sismosHandler.GetSismos)
 	app.Get("/sismos/refresh", sismosHandler.ForceRefreshSismos)
+	app.Get("/sismos/history", sismosHandler.GetSismosHistory)
+	app.Get("/sismos/stream", sismosHandler.StreamSismos)
+	app.Get("/ws/sismos", RequireWebSocket, websocket.New(sismosHandler.SismosWebSocket))
 
 	// Geo
 	geoHandler := NewGeoHandler(deps)
 	app.Get("/geo/filter", geoHandler.GetMunicipios)
 	app.Get("/geo/search-data", geoHandler.GetGeoData)
+	app.Get("/geo/reverse", geoHandler.ReverseGeocode)
+	app.Get("/geo/features", geoHandler.GetFeatures)
+	app.Post("/geo/features", geoHandler.QueryFeatures)
+	app.Get("/geo/features/:id", geoHandler.GetFeatureByID)
+	app.Get("/geo/features/:id/neighbors", geoHandler.GetNeighbors)
+	app.Get("/geo/features/:id/metrics", geoHandler.GetFeatureMetrics)
+	app.Get("/geo/metrics", geoHandler.GetMetrics)
+	app.Get("/geo/validation", geoHandler.GetValidation)
+	app.Get("/geo/datasets", geoHandler.GetDatasets)
+	app.Get("/geo/datasets/:name/filter", geoHandler.FilterDataset)
+	app.Get("/geo/hierarchy", geoHandler.GetHierarchy)
+	app.Get("/geo/autocomplete", geoHandler.Autocomplete)
+	app.Get("/geo/departamentos", geoHandler.GetDepartamentos)
+	app.Get("/geo/departamentos/:d/municipios", geoHandler.GetMunicipiosByDepartamento)
+	app.Get("/geo/departamentos/:d/municipios/:m/distritos", geoHandler.GetDistritosByMunicipio)
+
+	// Vector tiles
+	tilesHandler := NewTilesHandler(deps)
+	app.Get("/tiles/:z/:x/:y.mvt", tilesHandler.GetTile)
 
 	// Scraping
 	scrapeHandler := NewScrapeHandler(deps)
@@ -1,7 +1,19 @@
 package handlers
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
 	"chivomap.com/services"
+	"chivomap.com/services/geospatial"
 	"chivomap.com/services/scraping"
 	"chivomap.com/utils"
 
@@ -10,15 +22,210 @@ import (
 
 // SismosHandler maneja los endpoints relacionados con sismos
 type SismosHandler struct {
-	deps  *Dependencies
-	cache *services.CacheService[[]scraping.Sismo]
+	deps    *Dependencies
+	cache   *services.CacheService[[]scraping.Sismo]
+	store   *services.SismoStore
+	updates *services.Broadcaster[SismoUpdate]
+
+	// Listados pendientes; un único worker los identifica y persiste fuera
+	// de la goroutine que lee el hub
+	pending chan sismosBatch
+
+	// Último listado conocido, para detectar sismos nuevos o revisados
+	known   map[string]scraping.Sismo
+	seeded  bool
+	knownMu sync.Mutex
+}
+
+// sismosBatch es un listado de sismos por procesar. Si done no es nil, se
+// cierra cuando el listado ya está identificado y en la caché.
+type sismosBatch struct {
+	data []scraping.Sismo
+	done chan struct{}
 }
 
+// maxPendingSismos es la cantidad de listados del hub que pueden esperar al
+// worker. Cada listado contiene todos los eventos recientes, por lo que si el
+// worker se atrasa se descartan listados sin perder eventos.
+const maxPendingSismos = 8
+
 // NewSismosHandler crea una nueva instancia de SismosHandler
 func NewSismosHandler(deps *Dependencies) *SismosHandler {
-	return &SismosHandler{
-		deps:  deps,
-		cache: services.NewCacheService[[]scraping.Sismo](3), // 3 minutos TTL
+	h := &SismosHandler{
+		deps:    deps,
+		cache:   services.NewCacheService[[]scraping.Sismo](3), // 3 minutos TTL
+		store:   services.NewSismoStore(deps.DB),
+		updates: services.NewBroadcaster[SismoUpdate](),
+		pending: make(chan sismosBatch, maxPendingSismos),
+		known:   make(map[string]scraping.Sismo),
+	}
+	go h.processSismos()
+
+	// Cada EventSignal del hub actualiza la caché en cuanto se publica
+	if deps.SismosFeed != nil {
+		deps.SismosFeed.OnEvents(h.enqueueSismos)
+	}
+
+	return h
+}
+
+// enqueueSismos entrega un listado del hub al worker sin bloquear la lectura
+// del stream
+func (h *SismosHandler) enqueueSismos(data []scraping.Sismo) {
+	select {
+	case h.pending <- sismosBatch{data: data}:
+	default:
+		utils.Error("Listado de sismos descartado: %d listados pendientes", maxPendingSismos)
+	}
+}
+
+// setSismos entrega un listado al worker y espera a que esté identificado y en
+// la caché, para responder con los IDs definitivos
+func (h *SismosHandler) setSismos(data []scraping.Sismo) {
+	done := make(chan struct{})
+	select {
+	case h.pending <- sismosBatch{data: data, done: done}:
+	case <-h.deps.Ctx.Done():
+		return
+	}
+	select {
+	case <-done:
+	case <-h.deps.Ctx.Done():
+	}
+}
+
+// processSismos procesa los listados pendientes en orden hasta el cierre del
+// servidor: ubica los eventos, les asigna el ID de sus versiones anteriores,
+// actualiza la caché y los persiste
+func (h *SismosHandler) processSismos() {
+	for {
+		select {
+		case <-h.deps.Ctx.Done():
+			return
+		case batch := <-h.pending:
+			h.locateSismos(batch.data)
+			h.resolveIDs(batch.data)
+			h.publishChanges(batch.data)
+			h.cache.Set(batch.data)
+			if batch.done != nil {
+				close(batch.done)
+			}
+			h.persistSismos(batch.data)
+		}
+	}
+}
+
+// resolveIDs reutiliza el ID de los eventos ya guardados en el catálogo de los
+// que el listado es una revisión. Si la base de datos falla se conservan los
+// IDs derivados; publishChanges aún reconoce las revisiones del último listado.
+func (h *SismosHandler) resolveIDs(data []scraping.Sismo) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := h.store.ResolveIDs(ctx, data); err != nil {
+		utils.Error("Error al identificar sismos: %v", err)
+	}
+}
+
+// maxSismoDistanceKm es la distancia máxima al territorio a la que un epicentro
+// se atribuye al distrito más cercano. Cubre la zona de subducción frente a la
+// costa; los eventos de otros países que publica el SNET quedan sin ubicación.
+const maxSismoDistanceKm = 150
+
+// locateSismos asigna a cada evento la unidad administrativa de su epicentro
+func (h *SismosHandler) locateSismos(data []scraping.Sismo) {
+	if h.deps.StaticCache == nil {
+		return
+	}
+
+	for i := range data {
+		ubicacion, err := geospatial.Locate(h.deps.StaticCache, data[i].Longitud, data[i].Latitud, maxSismoDistanceKm)
+		if err != nil {
+			utils.Error("Error al ubicar sismos: %v", err)
+			return
+		}
+		data[i].Ubicacion = ubicacion
+	}
+}
+
+// publishChanges compara el listado con el anterior y publica los sismos
+// nuevos o revisados. Un evento cuyo ID no aparece en el listado anterior pero
+// que coincide con uno de sus eventos (scraping.SameEvent) es una revisión y
+// conserva su ID. El primer listado solo se registra, sin publicarse.
+func (h *SismosHandler) publishChanges(data []scraping.Sismo) {
+	h.knownMu.Lock()
+	defer h.knownMu.Unlock()
+
+	current := make(map[string]scraping.Sismo, len(data))
+	for i := range data {
+		if data[i].ID == "" {
+			continue
+		}
+		if _, exists := h.known[data[i].ID]; !exists {
+			if id, ok := h.revisedID(data[i], data); ok {
+				data[i].ID = id
+			}
+		}
+		sismo := data[i]
+		current[sismo.ID] = sismo
+
+		if !h.seeded {
+			continue
+		}
+		previous, exists := h.known[sismo.ID]
+		switch {
+		case !exists:
+			h.updates.Publish(SismoUpdate{Tipo: "nuevo", Sismo: sismo})
+		case sismoRevised(previous, sismo):
+			h.updates.Publish(SismoUpdate{Tipo: "revisado", Sismo: sismo})
+		}
+	}
+
+	h.known = current
+	h.seeded = true
+}
+
+// revisedID busca en el listado anterior el evento del que sismo es una
+// revisión y que no tiene ya su versión en el listado actual
+func (h *SismosHandler) revisedID(sismo scraping.Sismo, data []scraping.Sismo) (string, bool) {
+	for id, previous := range h.known {
+		if !scraping.SameEvent(previous, sismo) {
+			continue
+		}
+		taken := false
+		for _, other := range data {
+			if other.ID == id {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// sismoRevised indica si el SNET modificó los datos publicados de un evento
+func sismoRevised(previous, current scraping.Sismo) bool {
+	return !previous.FechaUTC.Equal(current.FechaUTC) ||
+		previous.Magnitud != current.Magnitud ||
+		previous.Profundidad != current.Profundidad ||
+		previous.Latitud != current.Latitud ||
+		previous.Longitud != current.Longitud ||
+		previous.Fases != current.Fases ||
+		previous.RMS != current.RMS ||
+		previous.Estado != current.Estado ||
+		previous.Localizacion != current.Localizacion
+}
+
+// persistSismos guarda los eventos en el catálogo permanente
+func (h *SismosHandler) persistSismos(data []scraping.Sismo) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, err := h.store.Upsert(ctx, data); err != nil {
+		utils.Error("Error al guardar sismos: %v", err)
 	}
 }
 
@@ -32,7 +239,7 @@ func (h *SismosHandler) updateCacheInBackground() {
 		utils.Error("Error al actualizar caché: %v", err)
 		return
 	}
-	h.cache.Set(newData)
+	h.setSismos(newData)
 }
 
 // GetSismos maneja el endpoint GET /sismos
@@ -62,7 +269,7 @@ func (h *SismosHandler) GetSismos(c *fiber.Ctx) error {
 		utils.Error("Error en el scraping: %v", err)
 		return utils.RespondWithError(c, fiber.StatusInternalServerError, "No se pudieron obtener los datos")
 	}
-	h.cache.Set(data)
+	h.setSismos(data)
 	return utils.SendResponse(c, fiber.Map{
 		"totalSismos": len(data),
 		"data":        data,
@@ -84,10 +291,194 @@ func (h *SismosHandler) ForceRefreshSismos(c *fiber.Ctx) error {
 		utils.Error("Error al refrescar los datos: %v", err)
 		return utils.RespondWithError(c, fiber.StatusInternalServerError, "No se pudieron actualizar los datos")
 	}
-	h.cache.Set(data)
+	h.setSismos(data)
 	return utils.SendResponse(c, fiber.Map{
 		"message":     "Cache actualizada exitosamente",
 		"totalSismos": len(data),
 		"data":        data,
 	})
 }
+
+// StreamSismos emite sismos nuevos o revisados mediante Server-Sent Events
+// @Summary Stream de sismos en tiempo real
+// @Description Emite un evento "sismo" por cada sismo nuevo o revisado y un comentario de heartbeat cada 15 segundos
+// @Tags sismos
+// @Produce text/event-stream
+// @Success 200 {object} SismoUpdate "Stream de eventos \"sismo\""
+// @Router /sismos/stream [get]
+func (h *SismosHandler) StreamSismos(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no") // Desactivar buffering en proxies nginx
+
+	done := h.deps.Ctx.Done()
+	conn := c.Context().Conn()
+
+	// La suscripción se crea dentro del writer: si fasthttp nunca lo ejecuta
+	// (por ejemplo, si el cliente se desconecta antes) no queda suscrita
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		updates, unsubscribe := h.updates.Subscribe(32)
+		defer unsubscribe()
+
+		heartbeat := time.NewTicker(15 * time.Second)
+		defer heartbeat.Stop()
+
+		fmt.Fprint(w, "retry: 5000\n\n")
+		for {
+			// WriteTimeout del servidor aplica a la respuesta completa;
+			// se extiende mientras el cliente siga conectado
+			_ = conn.SetWriteDeadline(time.Now().Add(time.Minute))
+			if err := w.Flush(); err != nil {
+				return
+			}
+
+			select {
+			case <-done:
+				return
+			case update := <-updates:
+				payload, err := json.Marshal(update)
+				if err != nil {
+					utils.Error("Error serializando sismo: %v", err)
+					continue
+				}
+				fmt.Fprintf(w, "event: sismo\nid: %s\ndata: %s\n\n", update.Sismo.ID, payload)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			}
+		}
+	})
+
+	return nil
+}
+
+// GetSismosHistory consulta el catálogo histórico de sismos almacenados
+// @Summary Consulta el historial de sismos
+// @Description Retorna sismos almacenados, del más reciente al más antiguo, filtrados por tiempo, magnitud, profundidad y área
+// @Tags sismos
+// @Produce json
+// @Param from query string false "Inicio del rango (RFC3339 o YYYY-MM-DD)"
+// @Param to query string false "Fin del rango, exclusivo (RFC3339 o YYYY-MM-DD, inclusivo para fechas)"
+// @Param minMag query number false "Magnitud mínima"
+// @Param maxMag query number false "Magnitud máxima"
+// @Param minDepth query number false "Profundidad mínima en km"
+// @Param maxDepth query number false "Profundidad máxima en km"
+// @Param bbox query string false "Área: minLon,minLat,maxLon,maxLat"
+// @Param departamento query string false "Departamento del epicentro"
+// @Param cursor query string false "Cursor de la página siguiente"
+// @Param limit query int false "Cantidad de resultados (1-500, por defecto 100)"
+// @Success 200 {object} SismosHistoryResponse "Página de sismos"
+// @Failure 400 {object} ErrorResponse "Parámetros inválidos"
+// @Failure 500 {object} ErrorResponse "Error al consultar datos"
+// @Router /sismos/history [get]
+func (h *SismosHandler) GetSismosHistory(c *fiber.Ctx) error {
+	query, err := parseSismoQuery(c)
+	if err != nil {
+		return utils.RespondWithError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	page, err := h.store.Query(c.UserContext(), query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			return utils.RespondWithError(c, fiber.StatusBadRequest, "Parámetro 'cursor' inválido")
+		}
+		utils.Error("Error al consultar historial de sismos: %v", err)
+		return utils.RespondWithError(c, fiber.StatusInternalServerError, "No se pudieron obtener los datos")
+	}
+
+	return utils.SendResponse(c, fiber.Map{
+		"totalSismos": len(page.Sismos),
+		"data":        page.Sismos,
+		"nextCursor":  page.NextCursor,
+	})
+}
+
+// parseSismoQuery valida los parámetros de consulta del historial
+func parseSismoQuery(c *fiber.Ctx) (services.SismoQuery, error) {
+	query := services.SismoQuery{Cursor: c.Query("cursor"), Limit: 100}
+	var err error
+
+	if raw := c.Query("departamento"); raw != "" {
+		departamento, ok := utils.ValidateQuery(raw)
+		if !ok {
+			return query, errors.New("Parámetro 'departamento' inválido")
+		}
+		query.Departamento = departamento
+	}
+
+	if query.From, err = parseTimeParam(c.Query("from"), false); err != nil {
+		return query, errors.New("Parámetro 'from' inválido: use RFC3339 o YYYY-MM-DD")
+	}
+	if query.To, err = parseTimeParam(c.Query("to"), true); err != nil {
+		return query, errors.New("Parámetro 'to' inválido: use RFC3339 o YYYY-MM-DD")
+	}
+
+	floats := []struct {
+		name   string
+		target **float64
+	}{
+		{"minMag", &query.MinMag},
+		{"maxMag", &query.MaxMag},
+		{"minDepth", &query.MinDepth},
+		{"maxDepth", &query.MaxDepth},
+	}
+	for _, f := range floats {
+		if *f.target, err = parseFloatParam(c.Query(f.name)); err != nil {
+			return query, errors.New("Parámetro '" + f.name + "' inválido: debe ser numérico")
+		}
+	}
+
+	if raw := c.Query("bbox"); raw != "" {
+		bbox, ok := utils.ValidateBBox(raw)
+		if !ok {
+			return query, errors.New("Parámetro 'bbox' inválido: use minLon,minLat,maxLon,maxLat")
+		}
+		query.BBox = &bbox
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > 500 {
+			return query, errors.New("Parámetro 'limit' inválido: debe estar entre 1 y 500")
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
+}
+
+// parseTimeParam interpreta una fecha RFC3339 o YYYY-MM-DD. Para el final de un
+// rango, una fecha sin hora incluye el día completo.
+func parseTimeParam(raw string, endOfRange bool) (*time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return nil, err
+	}
+	if endOfRange {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// parseFloatParam interpreta un parámetro numérico opcional
+func parseFloatParam(raw string) (*float64, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, errors.New("valor numérico inválido")
+	}
+	return &value, nil
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"chivomap.com/services/scraping"
+	"chivomap.com/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+const (
+	// wsPingInterval debe ser menor que wsReadTimeout para que los pongs
+	// del cliente lleguen antes de que expire la lectura
+	wsPingInterval = 30 * time.Second
+	wsReadTimeout  = 75 * time.Second
+	wsWriteTimeout = 10 * time.Second
+)
+
+// SismoFilter contiene los criterios de una suscripción por WebSocket.
+// Los criterios vacíos no se aplican.
+type SismoFilter struct {
+	MinMagnitud  *float64    `json:"minMagnitud,omitempty"`
+	BBox         *[4]float64 `json:"bbox,omitempty"` // minLon, minLat, maxLon, maxLat
+	Departamento string      `json:"departamento,omitempty"`
+}
+
+// SismoWSRequest representa un mensaje enviado por el cliente
+type SismoWSRequest struct {
+	Tipo string `json:"tipo"` // "suscribir"
+	SismoFilter
+}
+
+// SismoWSResponse representa un mensaje de control enviado al cliente
+type SismoWSResponse struct {
+	Tipo    string           `json:"tipo"` // "suscrito", "snapshot" o "error"
+	Filtro  *SismoFilter     `json:"filtro,omitempty"`
+	Sismos  []scraping.Sismo `json:"sismos,omitempty"`
+	Mensaje string           `json:"mensaje,omitempty"`
+}
+
+// RequireWebSocket rechaza las solicitudes que no son upgrades a WebSocket
+func RequireWebSocket(c *fiber.Ctx) error {
+	if websocket.IsWebSocketUpgrade(c) {
+		return c.Next()
+	}
+	return utils.RespondWithError(c, fiber.StatusUpgradeRequired,
+		"Este endpoint requiere una conexión WebSocket")
+}
+
+// SismosWebSocket maneja suscripciones filtradas a sismos en /ws/sismos.
+// El cliente envía {"tipo":"suscribir", "minMagnitud":4, "bbox":[...], "departamento":"..."}
+// y recibe un snapshot de los sismos actuales que cumplen el filtro, seguido de
+// cada sismo nuevo o revisado que también lo cumpla.
+func (h *SismosHandler) SismosWebSocket(conn *websocket.Conn) {
+	updates, unsubscribe := h.updates.Subscribe(32)
+	defer unsubscribe()
+
+	var (
+		filter    *SismoFilter // nil hasta que el cliente se suscribe
+		filterMu  sync.RWMutex
+		responses = make(chan SismoWSResponse, 4)
+		closed    = make(chan struct{}) // el lector terminó
+		stopped   = make(chan struct{}) // el escritor terminó
+	)
+
+	respond := func(resp SismoWSResponse) bool {
+		select {
+		case responses <- resp:
+			return true
+		case <-stopped:
+			return false
+		}
+	}
+
+	// Lector: procesa los mensajes de suscripción del cliente
+	go func() {
+		defer close(closed)
+
+		conn.SetReadLimit(4096)
+		_ = conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+		})
+
+		for {
+			var req SismoWSRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			_ = conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+
+			if req.Tipo != "suscribir" {
+				if !respond(SismoWSResponse{Tipo: "error", Mensaje: "Tipo de mensaje desconocido: use 'suscribir'"}) {
+					return
+				}
+				continue
+			}
+			if msg, ok := validateSismoFilter(&req.SismoFilter); !ok {
+				if !respond(SismoWSResponse{Tipo: "error", Mensaje: msg}) {
+					return
+				}
+				continue
+			}
+
+			f := req.SismoFilter
+			filterMu.Lock()
+			filter = &f
+			filterMu.Unlock()
+
+			if !respond(SismoWSResponse{Tipo: "suscrito", Filtro: &f}) ||
+				!respond(SismoWSResponse{Tipo: "snapshot", Sismos: h.matchingSismos(&f)}) {
+				return
+			}
+		}
+	}()
+
+	// Al salir el escritor, por cualquier motivo, se cierra la conexión y se
+	// espera al lector: al retornar el handler el *websocket.Conn vuelve al pool
+	// y el lector no debe seguir usándolo
+	defer func() {
+		close(stopped)
+		_ = conn.Close()
+		<-closed
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	// Escritor: único goroutine que escribe en la conexión
+	for {
+		var payload any
+
+		select {
+		case <-h.deps.Ctx.Done():
+			_ = conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "servidor cerrando"),
+				time.Now().Add(wsWriteTimeout))
+			return
+		case <-closed:
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+			continue
+		case resp := <-responses:
+			payload = resp
+		case update := <-updates:
+			filterMu.RLock()
+			f := filter
+			filterMu.RUnlock()
+			if f == nil || !sismoMatches(update.Sismo, f) {
+				continue
+			}
+			payload = update
+		}
+
+		_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if err := conn.WriteJSON(payload); err != nil {
+			return
+		}
+	}
+}
+
+// validateSismoFilter normaliza y valida un filtro recibido del cliente
+func validateSismoFilter(f *SismoFilter) (string, bool) {
+	if f.BBox != nil && !utils.IsValidBBox(*f.BBox) {
+		return "Filtro 'bbox' inválido: use [minLon, minLat, maxLon, maxLat]", false
+	}
+	f.Departamento = strings.TrimSpace(f.Departamento)
+	if len(f.Departamento) > 100 {
+		return "Filtro 'departamento' inválido", false
+	}
+	return "", true
+}
+
+// matchingSismos retorna los sismos en caché que cumplen el filtro
+func (h *SismosHandler) matchingSismos(f *SismoFilter) []scraping.Sismo {
+	data, _ := h.cache.Get()
+	result := make([]scraping.Sismo, 0, len(data))
+	for _, sismo := range data {
+		if sismoMatches(sismo, f) {
+			result = append(result, sismo)
+		}
+	}
+	return result
+}
+
+// sismoMatches evalúa un sismo contra el filtro de una suscripción
+func sismoMatches(sismo scraping.Sismo, f *SismoFilter) bool {
+	if f.MinMagnitud != nil && sismo.Magnitud < *f.MinMagnitud {
+		return false
+	}
+	if f.BBox != nil {
+		b := f.BBox
+		if sismo.Longitud < b[0] || sismo.Longitud > b[2] || sismo.Latitud < b[1] || sismo.Latitud > b[3] {
+			return false
+		}
+	}
+	if f.Departamento != "" {
+		// Los epicentros offshore no pertenecen a ningún departamento; los
+		// nombres se comparan sin importar mayúsculas ni tildes
+		u := sismo.Ubicacion
+		if u == nil || u.Offshore || utils.NormalizeText(u.Departamento) != utils.NormalizeText(f.Departamento) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"strconv"
+
+	"chivomap.com/services"
+	"chivomap.com/services/tiles"
+	"chivomap.com/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxCachedTiles limita la cantidad de tiles en caché; al superarlo se
+// descartan los usados hace más tiempo
+const maxCachedTiles = 20000
+
+// TilesHandler maneja los endpoints de vector tiles
+type TilesHandler struct {
+	deps      *Dependencies
+	tileCache *services.LRUCache[string, []byte]
+}
+
+// NewTilesHandler crea una nueva instancia de TilesHandler
+func NewTilesHandler(deps *Dependencies) *TilesHandler {
+	h := &TilesHandler{
+		deps:      deps,
+		tileCache: services.NewLRUCache[string, []byte](maxCachedTiles),
+	}
+	deps.StaticCache.OnReload(func(string) { h.tileCache.Clear() })
+	return h
+}
+
+// GetTile maneja el endpoint de vector tiles
+// @Summary Vector tile de límites administrativos
+// @Description Retorna un Mapbox Vector Tile con la capa "distritos" y los atributos D, M y NAM. Los tiles sin features retornan 204.
+// @Tags tiles
+// @Produce application/vnd.mapbox-vector-tile
+// @Param z path int true "Zoom (0-22)"
+// @Param x path int true "Columna del tile"
+// @Param y path int true "Fila del tile"
+// @Success 200 {file} binary "Tile MVT"
+// @Success 204 "Tile vacío"
+// @Failure 400 {object} ErrorResponse "Tile inválido"
+// @Failure 500 {object} ErrorResponse "Error interno"
+// @Router /tiles/{z}/{x}/{y}.mvt [get]
+func (h *TilesHandler) GetTile(c *fiber.Ctx) error {
+	z, errZ := strconv.Atoi(c.Params("z"))
+	x, errX := strconv.Atoi(c.Params("x"))
+	y, errY := strconv.Atoi(c.Params("y"))
+	if errZ != nil || errX != nil || errY != nil || !tiles.Valid(z, x, y) {
+		return utils.RespondWithError(c, fiber.StatusBadRequest,
+			"Tile inválido: z debe estar entre 0 y 22, y x, y entre 0 y 2^z-1")
+	}
+
+	cacheKey := h.deps.StaticCache.Version() + ":" + strconv.Itoa(z) + "/" + strconv.Itoa(x) + "/" + strconv.Itoa(y)
+
+	tile, ok := h.tileCache.Get(cacheKey)
+	if !ok {
+		var err error
+		tile, err = tiles.Generate(h.deps.StaticCache, z, x, y)
+		if err != nil {
+			utils.Error("Error generando tile %s: %v", cacheKey, err)
+			return utils.RespondWithError(c, fiber.StatusInternalServerError,
+				"No se pudo generar el tile")
+		}
+		h.tileCache.Add(cacheKey, tile)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "public, max-age=3600")
+	if len(tile) == 0 {
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+	c.Set(fiber.HeaderContentType, "application/vnd.mapbox-vector-tile")
+	return c.Send(tile)
+}
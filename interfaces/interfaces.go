@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 
+	"chivomap.com/services/scraping"
+	"chivomap.com/spatial"
 	"chivomap.com/types"
 )
 
@@ -25,13 +27,58 @@ type DatabaseService interface {
 	Close() error
 }
 
-// StaticCacheService provides access to cached static files
+// GeoSnapshot is an immutable view of one loaded version of topo.json. Feature
+// IDs from an index, positions in a feature collection, metrics and adjacency
+// read from the same snapshot always refer to the same features, even if the
+// file is reloaded meanwhile.
+type GeoSnapshot interface {
+	Version() string
+	TopoJSON() *types.TopoJSON
+	GeoData() *types.GeoFeatureCollection
+	Adjacency() [][]spatial.Neighbor
+	ValidationReport() *types.ValidationReport
+	LevelGeoData(level string, tolerance float64) (*types.GeoFeatureCollection, error)
+	LevelIndex(level string) (*spatial.FeatureIndex, error)
+	LevelMetrics(level string) ([]types.FeatureMetrics, error)
+	LevelObject(level string) (*types.TopoObject, error)
+}
+
+// StaticCacheService provides access to cached static files. Each getter reads
+// the current dataset; callers that combine several values (an index and its
+// features, features and their metrics) must read them from one Snapshot.
 type StaticCacheService interface {
+	Snapshot() (GeoSnapshot, error)
 	GetGeoData() (*types.GeoFeatureCollection, error)
+	GetSimplifiedGeoData(tolerance float64) (*types.GeoFeatureCollection, error)
+	GetSpatialIndex() (*spatial.FeatureIndex, error)
+	GetAdjacency() ([][]spatial.Neighbor, error)
+	GetMetrics() ([]types.FeatureMetrics, error)
+	GetLevelGeoData(level string, tolerance float64) (*types.GeoFeatureCollection, error)
+	GetLevelIndex(level string) (*spatial.FeatureIndex, error)
+	GetLevelMetrics(level string) ([]types.FeatureMetrics, error)
+	GetLevelObject(level string) (*types.TopoObject, error)
+	GetValidationReport() (*types.ValidationReport, error)
+	Version() string
+	OnReload(fn func(version string))
+	Watch(ctx context.Context)
 	LoadTopoJSON() (*types.TopoJSON, error)
 	GetCacheStats() map[string]interface{}
 }
 
+// DatasetRegistry provides access to every geographic layer in the assets directory
+type DatasetRegistry interface {
+	Datasets() []types.DatasetInfo
+	Dataset(name string) (*types.GeoFeatureCollection, *spatial.Index, bool)
+	Watch(ctx context.Context)
+}
+
+// SismosFeed provides a live subscription to SNET earthquake events
+type SismosFeed interface {
+	OnEvents(fn func([]scraping.Sismo))
+	Start(ctx context.Context)
+	Status() map[string]interface{}
+}
+
 // Logger provides logging functionality
 type Logger interface {
 	Info(format string, args ...interface{})
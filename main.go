@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
@@ -92,18 +93,33 @@ func main() {
 	}
 	defer container.Close()
 
+	// Contexto de los procesos de larga duración (suscripciones, streams);
+	// se cancela al iniciar el cierre del servidor
+	appCtx, stopApp := context.WithCancel(context.Background())
+	defer stopApp()
+
 	// Crear dependencias para handlers
 	deps := &handlers.Dependencies{
+		Ctx:         appCtx,
 		Config:      container.Config,
 		DB:          container.DB,
 		CensoDB:     container.CensoDB,
 		StaticCache: container.StaticCache,
+		Datasets:    container.Datasets,
+		SismosFeed:  container.SismosFeed,
 		Logger:      container.Logger,
 	}
 
 	// Configurar rutas
 	handlers.SetupRoutes(app, deps)
 
+	// Suscripción persistente al hub de sismos del SNET
+	container.SismosFeed.Start(appCtx)
+
+	// Recargar los límites administrativos cuando cambie topo.json
+	container.StaticCache.Watch(appCtx)
+	container.Datasets.Watch(appCtx)
+
 	// Configurar Swagger con tema oscuro y toggle
 	utils.SetupSwagger(app)
 
@@ -128,6 +144,7 @@ func main() {
 	// Esperar señal de cierre
 	<-c
 	utils.Info("Cerrando servidor gracefully...")
+	stopApp()
 
 	// Cerrar servidor
 	if err := app.Shutdown(); err != nil {
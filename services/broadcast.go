@@ -0,0 +1,57 @@
+package services
+
+import "sync"
+
+// Broadcaster distribuye mensajes a múltiples suscriptores sin bloquear al publicador.
+type Broadcaster[T any] struct {
+	subscribers map[chan T]struct{}
+	mu          sync.RWMutex
+}
+
+// NewBroadcaster crea una nueva instancia de Broadcaster.
+func NewBroadcaster[T any]() *Broadcaster[T] {
+	return &Broadcaster[T]{
+		subscribers: make(map[chan T]struct{}),
+	}
+}
+
+// Subscribe registra un suscriptor con un buffer del tamaño indicado y retorna
+// su canal junto con la función para cancelar la suscripción.
+func (b *Broadcaster[T]) Subscribe(buffer int) (<-chan T, func()) {
+	ch := make(chan T, buffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+		})
+	}
+	return ch, unsubscribe
+}
+
+// Publish envía el mensaje a todos los suscriptores. Si el buffer de un
+// suscriptor está lleno el mensaje se descarta para ese suscriptor.
+func (b *Broadcaster[T]) Publish(msg T) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+// Count retorna la cantidad de suscriptores activos.
+func (b *Broadcaster[T]) Count() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers)
+}
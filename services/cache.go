@@ -69,3 +69,10 @@ func (c *CacheService[T]) SetUpdating(status bool) {
 		c.cache.IsUpdating = status
 	}
 }
+
+// Clear descarta los datos en caché, por ejemplo cuando cambian los datos de origen.
+func (c *CacheService[T]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache = nil
+}
@@ -0,0 +1,101 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// writeCSV escribe una fila por feature con su ID, sus atributos y la geometría
+// en WKT (columna "wkt", vacía si no tiene), como la lee QGIS con "Texto
+// delimitado"
+func writeCSV(w io.Writer, l *layer) error {
+	writer := csv.NewWriter(w)
+
+	header := make([]string, 0, len(l.attributes)+2)
+	header = append(header, "id")
+	for _, attr := range l.attributes {
+		header = append(header, attr.name)
+	}
+	header = append(header, "wkt")
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	row := make([]string, len(header))
+	for _, r := range l.records {
+		row[0] = r.id
+		for i, value := range r.values {
+			row[i+1] = formatValue(value)
+		}
+		row[len(row)-1] = r.geometry.wkt()
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// wkt representa la geometría en Well-Known Text: MULTIPOLYGON, MULTILINESTRING
+// o MULTIPOINT, o POINT si es un solo punto. Retorna "" si no tiene geometría.
+func (g geometry) wkt() string {
+	var b strings.Builder
+	switch g.family {
+	case familyPoint:
+		if len(g.points) == 1 {
+			b.WriteString("POINT (")
+			writeWKTPosition(&b, g.points[0])
+			b.WriteByte(')')
+			break
+		}
+		b.WriteString("MULTIPOINT ")
+		writeWKTPositions(&b, g.points)
+	case familyLine:
+		b.WriteString("MULTILINESTRING (")
+		for i, line := range g.lines {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeWKTPositions(&b, line)
+		}
+		b.WriteByte(')')
+	case familyPolygon:
+		b.WriteString("MULTIPOLYGON (")
+		for i, polygon := range g.polygons {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteByte('(')
+			for j, ring := range polygon {
+				if j > 0 {
+					b.WriteString(", ")
+				}
+				writeWKTPositions(&b, ring)
+			}
+			b.WriteByte(')')
+		}
+		b.WriteByte(')')
+	}
+	return b.String()
+}
+
+// writeWKTPositions escribe una lista de posiciones entre paréntesis
+func writeWKTPositions(b *strings.Builder, positions [][]float64) {
+	b.WriteByte('(')
+	for i, pos := range positions {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		writeWKTPosition(b, pos)
+	}
+	b.WriteByte(')')
+}
+
+// writeWKTPosition escribe "lon lat"
+func writeWKTPosition(b *strings.Builder, pos []float64) {
+	b.WriteString(formatFloat(pos[0]))
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(pos[1]))
+}
@@ -0,0 +1,365 @@
+// Package export serializa colecciones de features a los formatos que usan
+// QGIS, Google Earth y las hojas de cálculo: KML, Shapefile (en un zip),
+// GeoPackage y CSV con la geometría en WKT.
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"chivomap.com/spatial"
+	"chivomap.com/types"
+	"chivomap.com/utils"
+)
+
+// Formatos de exportación
+const (
+	KML        = "kml"
+	Shapefile  = "shp"
+	GeoPackage = "gpkg"
+	CSV        = "csv"
+)
+
+// formats describe la extensión del archivo descargado y su tipo MIME
+var formats = map[string]struct {
+	extension   string
+	contentType string
+}{
+	KML:        {".kml", "application/vnd.google-earth.kml+xml"},
+	Shapefile:  {".zip", "application/zip"},
+	GeoPackage: {".gpkg", "application/geopackage+sqlite3"},
+	CSV:        {".csv", "text/csv; charset=utf-8"},
+}
+
+// Supported indica si el formato es uno de los de exportación
+func Supported(format string) bool {
+	_, ok := formats[format]
+	return ok
+}
+
+// ContentType retorna el tipo MIME del archivo exportado
+func ContentType(format string) string {
+	return formats[format].contentType
+}
+
+// FileName retorna el nombre del archivo descargado para la capa
+func FileName(name, format string) string {
+	return name + formats[format].extension
+}
+
+// SupportsCRS indica si el formato admite coordenadas en el sistema indicado:
+// KML solo admite WGS84 lon/lat
+func SupportsCRS(format string, crs *spatial.CRS) bool {
+	return format != KML || crs.Geographic()
+}
+
+// Write escribe los features en el formato indicado. name es el nombre de la
+// capa: la tabla del GeoPackage, los archivos dentro del zip del Shapefile y el
+// documento KML. Los features ya deben estar en el sistema crs, que se declara
+// en el .prj del Shapefile y en el SRS del GeoPackage.
+func Write(w io.Writer, format, name string, features []types.GeoFeature, crs *spatial.CRS) error {
+	if !SupportsCRS(format, crs) {
+		return fmt.Errorf("el formato %s no admite el sistema EPSG:%d", format, crs.Code)
+	}
+	layer := newLayer(features)
+	switch format {
+	case KML:
+		return writeKML(w, name, layer)
+	case Shapefile:
+		return writeShapefile(w, name, layer, crs)
+	case GeoPackage:
+		return writeGeoPackage(w, name, layer, crs)
+	case CSV:
+		return writeCSV(w, layer)
+	}
+	return fmt.Errorf("formato de exportación no soportado: %q", format)
+}
+
+// Tipos de los atributos
+const (
+	attrText = iota
+	attrInteger
+	attrReal
+	attrBool
+)
+
+// attribute es una columna de la tabla de atributos
+type attribute struct {
+	name string
+	kind int
+}
+
+// Familias de geometría; un Shapefile solo admite una por archivo
+const (
+	familyNone = iota
+	familyPoint
+	familyLine
+	familyPolygon
+)
+
+// geometry es la geometría de un feature normalizada a su versión Multi
+type geometry struct {
+	family   int
+	points   [][]float64     // familyPoint
+	lines    [][][]float64   // familyLine
+	polygons [][][][]float64 // familyPolygon
+}
+
+// record es un feature listo para exportar
+type record struct {
+	id       string
+	values   []any // en el orden de layer.attributes; nil si falta
+	geometry geometry
+}
+
+// layer es la tabla de atributos y las geometrías de los features
+type layer struct {
+	attributes []attribute
+	records    []record
+	family     int // familia más común; las demás se exportan sin geometría en Shapefile
+	bounds     spatial.BBox
+}
+
+// newLayer arma la tabla de atributos a partir de la unión de las propiedades
+// de los features (ordenadas por nombre). El tipo de cada columna es entero,
+// real o booleano si todos sus valores lo son, y texto en otro caso; los
+// valores que no son escalares se guardan como JSON.
+func newLayer(features []types.GeoFeature) *layer {
+	kinds := make(map[string]int)
+	typed := make(map[string]bool) // columnas con al menos un valor no nulo
+	for _, feature := range features {
+		for key, value := range feature.Properties {
+			if value == nil {
+				if _, seen := kinds[key]; !seen {
+					kinds[key] = attrText
+				}
+				continue
+			}
+			kind := valueKind(value)
+			previous := kinds[key]
+			switch {
+			case !typed[key]:
+				kinds[key] = kind
+				typed[key] = true
+			case previous == kind:
+			case previous == attrInteger && kind == attrReal, previous == attrReal && kind == attrInteger:
+				kinds[key] = attrReal
+			default:
+				kinds[key] = attrText
+			}
+		}
+	}
+
+	l := &layer{bounds: spatial.EmptyBBox()}
+	for _, key := range sortedKeys(kinds) {
+		l.attributes = append(l.attributes, attribute{name: key, kind: kinds[key]})
+	}
+
+	counts := make(map[int]int)
+	l.records = make([]record, len(features))
+	for i, feature := range features {
+		values := make([]any, len(l.attributes))
+		for j, attr := range l.attributes {
+			values[j] = attributeValue(feature.Properties[attr.name], attr.kind)
+		}
+		geom := newGeometry(feature.Geometry)
+		counts[geom.family]++
+		geom.extend(&l.bounds)
+		l.records[i] = record{id: feature.ID, values: values, geometry: geom}
+	}
+
+	// Los features sin geometría no cuentan: la capa solo es nula si ninguno la tiene
+	best := 0
+	for _, family := range []int{familyPolygon, familyLine, familyPoint} {
+		if counts[family] > best {
+			l.family, best = family, counts[family]
+		}
+	}
+	return l
+}
+
+// valueKind retorna el tipo de atributo de un valor de propiedad
+func valueKind(value any) int {
+	switch v := value.(type) {
+	case bool:
+		return attrBool
+	case int, int32, int64:
+		return attrInteger
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+			return attrInteger
+		}
+		return attrReal
+	case float32:
+		return attrReal
+	}
+	return attrText
+}
+
+// attributeValue convierte una propiedad al tipo de su columna: string, int64,
+// float64 o bool (nil si falta)
+func attributeValue(value any, kind int) any {
+	if value == nil {
+		return nil
+	}
+	switch kind {
+	case attrBool:
+		return value
+	case attrInteger:
+		switch v := value.(type) {
+		case int:
+			return int64(v)
+		case int32:
+			return int64(v)
+		case int64:
+			return v
+		case float64:
+			return int64(v)
+		}
+	case attrReal:
+		switch v := value.(type) {
+		case int:
+			return float64(v)
+		case int32:
+			return float64(v)
+		case int64:
+			return float64(v)
+		case float32:
+			return float64(v)
+		case float64:
+			return v
+		}
+	}
+	if text, ok := value.(string); ok {
+		return text
+	}
+	if encoded, err := json.Marshal(value); err == nil {
+		return string(encoded)
+	}
+	return fmt.Sprint(value)
+}
+
+// formatValue representa un valor de atributo como texto (vacío si falta)
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return formatFloat(v)
+	}
+	return fmt.Sprint(value)
+}
+
+// formatFloat formatea una coordenada o número sin notación exponencial
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// newGeometry normaliza una geometría GeoJSON; las vacías o no soportadas
+// quedan sin familia
+func newGeometry(raw any) geometry {
+	if polygons := spatial.Polygons(raw); len(polygons) > 0 {
+		return geometry{family: familyPolygon, polygons: polygons}
+	}
+	if lines := spatial.Lines(raw); len(lines) > 0 {
+		return geometry{family: familyLine, lines: lines}
+	}
+	if points := spatial.Points(raw); len(points) > 0 {
+		return geometry{family: familyPoint, points: points}
+	}
+	return geometry{}
+}
+
+// extend amplía el rectángulo con las posiciones de la geometría
+func (g geometry) extend(bounds *spatial.BBox) {
+	for _, pos := range g.positions() {
+		bounds.Extend(pos[0], pos[1])
+	}
+}
+
+// positions retorna todas las posiciones de la geometría
+func (g geometry) positions() [][]float64 {
+	positions := append([][]float64(nil), g.points...)
+	for _, line := range g.lines {
+		positions = append(positions, line...)
+	}
+	for _, polygon := range g.polygons {
+		for _, ring := range polygon {
+			positions = append(positions, ring...)
+		}
+	}
+	return positions
+}
+
+// bounds retorna el rectángulo envolvente de la geometría
+func (g geometry) bounds() spatial.BBox {
+	bounds := spatial.EmptyBBox()
+	g.extend(&bounds)
+	return bounds
+}
+
+// orientedPolygons retorna los polígonos con los anillos exteriores en sentido
+// horario y los huecos en sentido antihorario, como pide Shapefile
+func (g geometry) orientedPolygons() [][][][]float64 {
+	polygons := make([][][][]float64, len(g.polygons))
+	for i, polygon := range g.polygons {
+		polygons[i] = make([][][]float64, len(polygon))
+		for j, ring := range polygon {
+			exterior := j == 0
+			if spatial.RingIsCounterClockwise(ring) == exterior {
+				ring = reversed(ring)
+			}
+			polygons[i][j] = ring
+		}
+	}
+	return polygons
+}
+
+// reversed retorna una copia de las posiciones en orden inverso
+func reversed(positions [][]float64) [][]float64 {
+	out := make([][]float64, len(positions))
+	for i, pos := range positions {
+		out[len(positions)-1-i] = pos
+	}
+	return out
+}
+
+// placemarkName elige el nombre visible de un feature: NAM, M o D si existen,
+// o su ID
+func placemarkName(l *layer, r record) string {
+	for _, key := range []string{"NAM", "M", "D"} {
+		for i, attr := range l.attributes {
+			if attr.name == key {
+				if name, ok := r.values[i].(string); ok && strings.TrimSpace(name) != "" {
+					return name
+				}
+			}
+		}
+	}
+	return r.id
+}
+
+// layerName limpia el nombre de una capa para usarlo como nombre de archivo o tabla
+func layerName(name string) string {
+	if slug := strings.ReplaceAll(utils.Slugify(name), "-", "_"); slug != "" {
+		return slug
+	}
+	return "features"
+}
+
+// sortedKeys retorna las claves del mapa ordenadas
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
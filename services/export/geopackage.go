@@ -0,0 +1,305 @@
+package export
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"chivomap.com/spatial"
+	_ "github.com/tursodatabase/go-libsql"
+)
+
+// Tipos de geometría de WKB
+const (
+	wkbPoint           = 1
+	wkbMultiPoint      = 4
+	wkbMultiLineString = 5
+	wkbMultiPolygon    = 6
+)
+
+// gpkgTypes son los tipos de columna del GeoPackage para cada tipo de atributo
+var gpkgTypes = map[int]string{
+	attrText:    "TEXT",
+	attrInteger: "INTEGER",
+	attrReal:    "DOUBLE",
+	attrBool:    "BOOLEAN",
+}
+
+// gpkgSchema crea las tablas obligatorias de un GeoPackage 1.3 con los sistemas
+// de referencia que exige la especificación (-1, 0 y EPSG:4326). El driver
+// ejecuta una sentencia por llamada.
+var gpkgSchema = []string{
+	`PRAGMA application_id = 1196444487`,
+	`PRAGMA user_version = 10300`,
+	`CREATE TABLE gpkg_spatial_ref_sys (
+	srs_name TEXT NOT NULL,
+	srs_id INTEGER NOT NULL PRIMARY KEY,
+	organization TEXT NOT NULL,
+	organization_coordsys_id INTEGER NOT NULL,
+	definition TEXT NOT NULL,
+	description TEXT
+)`,
+	`CREATE TABLE gpkg_contents (
+	table_name TEXT NOT NULL PRIMARY KEY,
+	data_type TEXT NOT NULL,
+	identifier TEXT UNIQUE,
+	description TEXT DEFAULT '',
+	last_change DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+	min_x DOUBLE, min_y DOUBLE, max_x DOUBLE, max_y DOUBLE,
+	srs_id INTEGER,
+	CONSTRAINT fk_gc_r_srs_id FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys(srs_id)
+)`,
+	`CREATE TABLE gpkg_geometry_columns (
+	table_name TEXT NOT NULL,
+	column_name TEXT NOT NULL,
+	geometry_type_name TEXT NOT NULL,
+	srs_id INTEGER NOT NULL,
+	z TINYINT NOT NULL,
+	m TINYINT NOT NULL,
+	CONSTRAINT pk_geom_cols PRIMARY KEY (table_name, column_name),
+	CONSTRAINT fk_gc_tn FOREIGN KEY (table_name) REFERENCES gpkg_contents(table_name),
+	CONSTRAINT fk_gc_srs FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys (srs_id)
+)`,
+	`INSERT INTO gpkg_spatial_ref_sys VALUES
+	('Undefined cartesian SRS', -1, 'NONE', -1, 'undefined', 'undefined cartesian coordinate reference system'),
+	('Undefined geographic SRS', 0, 'NONE', 0, 'undefined', 'undefined geographic coordinate reference system'),
+	('WGS 84 geodetic', 4326, 'EPSG', 4326, 'GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",6378137,298.257223563,AUTHORITY["EPSG","7030"]],AUTHORITY["EPSG","6326"]],PRIMEM["Greenwich",0,AUTHORITY["EPSG","8901"]],UNIT["degree",0.0174532925199433,AUTHORITY["EPSG","9122"]],AUTHORITY["EPSG","4326"]]', 'longitude/latitude coordinates in decimal degrees on the WGS 84 spheroid')`,
+}
+
+// writeGeoPackage crea el GeoPackage en un archivo temporal (SQLite necesita un
+// archivo) y lo copia al writer. La capa es una tabla de features con la
+// columna "geom" y una columna por atributo, en el sistema crs.
+func writeGeoPackage(w io.Writer, name string, l *layer, crs *spatial.CRS) error {
+	dir, err := os.MkdirTemp("", "chivomap-gpkg-")
+	if err != nil {
+		return fmt.Errorf("error creando directorio temporal: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "export.gpkg")
+	if err := buildGeoPackage(path, layerName(name), l, crs); err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error abriendo GeoPackage: %w", err)
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// buildGeoPackage escribe el esquema, la tabla de la capa y sus features
+func buildGeoPackage(path, table string, l *layer, crs *spatial.CRS) error {
+	db, err := sql.Open("libsql", "file:"+path)
+	if err != nil {
+		return fmt.Errorf("error creando GeoPackage: %w", err)
+	}
+	defer db.Close()
+
+	for _, statement := range gpkgSchema {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("error creando esquema GeoPackage: %w", err)
+		}
+	}
+
+	// Los sistemas proyectados se registran con su definición ESRI, que QGIS y GDAL aceptan
+	if crs.Code != spatial.WGS84.Code {
+		if _, err := db.Exec(
+			`INSERT INTO gpkg_spatial_ref_sys VALUES (?, ?, 'EPSG', ?, ?, NULL)`, crs.Name, crs.Code, crs.Code, crs.WKT,
+		); err != nil {
+			return fmt.Errorf("error registrando EPSG:%d: %w", crs.Code, err)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error iniciando transacción: %w", err)
+	}
+	defer tx.Rollback()
+
+	// El ID del feature se guarda como atributo; fid es la llave de SQLite
+	columns := []string{`"fid" INTEGER PRIMARY KEY AUTOINCREMENT`, `"geom" ` + l.geometryTypeName(), `"id" TEXT`}
+	names := []string{`"geom"`, `"id"`}
+	for i, column := range gpkgColumns(l) {
+		columns = append(columns, quoteIdentifier(column)+" "+gpkgTypes[l.attributes[i].kind])
+		names = append(names, quoteIdentifier(column))
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf("CREATE TABLE %s (%s)", quoteIdentifier(table), strings.Join(columns, ", "))); err != nil {
+		return fmt.Errorf("error creando tabla %s: %w", table, err)
+	}
+
+	// El rectángulo de la capa queda en NULL si no hay geometrías
+	extent := make([]any, 4)
+	if !l.bounds.IsEmpty() {
+		for i, value := range l.bounds {
+			extent[i] = value
+		}
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO gpkg_contents (table_name, data_type, identifier, min_x, min_y, max_x, max_y, srs_id) VALUES (?, 'features', ?, ?, ?, ?, ?, ?)`,
+		append(append([]any{table, table}, extent...), crs.Code)...,
+	); err != nil {
+		return fmt.Errorf("error registrando tabla %s: %w", table, err)
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO gpkg_geometry_columns VALUES (?, 'geom', ?, ?, 0, 0)`, table, l.geometryTypeName(), crs.Code,
+	); err != nil {
+		return fmt.Errorf("error registrando geometría de %s: %w", table, err)
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+	insert, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdentifier(table), strings.Join(names, ", "), placeholders))
+	if err != nil {
+		return fmt.Errorf("error preparando inserción: %w", err)
+	}
+	defer insert.Close()
+
+	for _, r := range l.records {
+		args := make([]any, 0, len(names))
+		args = append(args, gpkgGeometry(r.geometry, crs.Code), r.id)
+		for _, value := range r.values {
+			args = append(args, gpkgValue(value))
+		}
+		if _, err := insert.Exec(args...); err != nil {
+			return fmt.Errorf("error insertando feature %s: %w", r.id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error guardando GeoPackage: %w", err)
+	}
+	return nil
+}
+
+// geometryTypeName retorna el tipo de la columna de geometría: el que escribe
+// appendWKB si todos los features lo comparten, o GEOMETRY
+func (l *layer) geometryTypeName() string {
+	name := ""
+	for _, r := range l.records {
+		var current string
+		switch {
+		case r.geometry.family == familyNone:
+			continue
+		case r.geometry.family == familyPolygon:
+			current = "MULTIPOLYGON"
+		case r.geometry.family == familyLine:
+			current = "MULTILINESTRING"
+		case len(r.geometry.points) == 1:
+			current = "POINT"
+		default:
+			current = "MULTIPOINT"
+		}
+		if name != "" && name != current {
+			return "GEOMETRY"
+		}
+		name = current
+	}
+	if name == "" {
+		return "GEOMETRY"
+	}
+	return name
+}
+
+// gpkgGeometry codifica la geometría en el formato binario de GeoPackage: un
+// encabezado "GP" con el SRS y el rectángulo envolvente seguido del WKB. Las
+// geometrías vacías se guardan como NULL.
+func gpkgGeometry(g geometry, srsID int) any {
+	if g.family == familyNone {
+		return nil
+	}
+
+	b := []byte{'G', 'P', 0, 0x03} // versión 0; little endian con envolvente xy
+	b = binary.LittleEndian.AppendUint32(b, uint32(srsID))
+	bounds := g.bounds()
+	for _, value := range []float64{bounds[0], bounds[2], bounds[1], bounds[3]} {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(value))
+	}
+	return appendWKB(b, g)
+}
+
+// appendWKB agrega la geometría en WKB little endian: POINT si es un solo
+// punto y la versión Multi en los demás casos
+func appendWKB(b []byte, g geometry) []byte {
+	header := func(b []byte, wkbType uint32) []byte {
+		return binary.LittleEndian.AppendUint32(append(b, 1), wkbType)
+	}
+	position := func(b []byte, pos []float64) []byte {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(pos[0]))
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(pos[1]))
+	}
+	positions := func(b []byte, list [][]float64) []byte {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(list)))
+		for _, pos := range list {
+			b = position(b, pos)
+		}
+		return b
+	}
+
+	switch g.family {
+	case familyPoint:
+		if len(g.points) == 1 {
+			return position(header(b, wkbPoint), g.points[0])
+		}
+		b = binary.LittleEndian.AppendUint32(header(b, wkbMultiPoint), uint32(len(g.points)))
+		for _, pos := range g.points {
+			b = position(header(b, wkbPoint), pos)
+		}
+	case familyLine:
+		b = binary.LittleEndian.AppendUint32(header(b, wkbMultiLineString), uint32(len(g.lines)))
+		for _, line := range g.lines {
+			b = positions(header(b, 2), line)
+		}
+	case familyPolygon:
+		b = binary.LittleEndian.AppendUint32(header(b, wkbMultiPolygon), uint32(len(g.polygons)))
+		for _, polygon := range g.polygons {
+			b = binary.LittleEndian.AppendUint32(header(b, 3), uint32(len(polygon)))
+			for _, ring := range polygon {
+				b = positions(b, ring)
+			}
+		}
+	}
+	return b
+}
+
+// gpkgValue convierte un valor de atributo para SQLite
+func gpkgValue(value any) any {
+	if v, ok := value.(bool); ok {
+		if v {
+			return 1
+		}
+		return 0
+	}
+	return value
+}
+
+// gpkgColumns retorna el nombre de columna de cada atributo. SQLite no
+// distingue mayúsculas en los nombres de columna, así que los que se repiten
+// sin importar mayúsculas, o chocan con las columnas propias de la tabla (fid,
+// geom e id), reciben un sufijo numérico como en dbfFields.
+func gpkgColumns(l *layer) []string {
+	used := map[string]bool{"fid": true, "geom": true, "id": true}
+	columns := make([]string, len(l.attributes))
+	for i, attr := range l.attributes {
+		unique := attr.name
+		for n := 2; used[strings.ToLower(unique)]; n++ {
+			unique = attr.name + strconv.Itoa(n)
+		}
+		used[strings.ToLower(unique)] = true
+		columns[i] = unique
+	}
+	return columns
+}
+
+// quoteIdentifier escapa un nombre de tabla o columna de SQLite
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
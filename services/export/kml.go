@@ -0,0 +1,117 @@
+package export
+
+import (
+	"bufio"
+	"encoding/xml"
+	"io"
+)
+
+// kmlTypes son los tipos de SimpleField de KML para cada tipo de atributo
+var kmlTypes = map[int]string{
+	attrText:    "string",
+	attrInteger: "int",
+	attrReal:    "double",
+	attrBool:    "bool",
+}
+
+// writeKML escribe un documento KML 2.2 con un Placemark por feature. Los
+// atributos se declaran en un Schema y se incluyen como SchemaData, que Google
+// Earth muestra en el globo de cada feature y QGIS lee como columnas.
+func writeKML(w io.Writer, name string, l *layer) error {
+	b := bufio.NewWriter(w)
+	schemaID := layerName(name)
+
+	b.WriteString(xml.Header)
+	b.WriteString(`<kml xmlns="http://www.opengis.net/kml/2.2">` + "\n<Document>\n<name>")
+	xml.EscapeText(b, []byte(name))
+	b.WriteString("</name>\n")
+
+	b.WriteString(`<Schema name="` + schemaID + `" id="` + schemaID + `">` + "\n")
+	for _, attr := range l.attributes {
+		b.WriteString(`<SimpleField type="` + kmlTypes[attr.kind] + `" name="`)
+		xml.EscapeText(b, []byte(attr.name))
+		b.WriteString(`"/>` + "\n")
+	}
+	b.WriteString("</Schema>\n")
+
+	for _, r := range l.records {
+		b.WriteString("<Placemark")
+		if r.id != "" {
+			b.WriteString(` id="`)
+			xml.EscapeText(b, []byte(r.id))
+			b.WriteString(`"`)
+		}
+		b.WriteString(">\n<name>")
+		xml.EscapeText(b, []byte(placemarkName(l, r)))
+		b.WriteString("</name>\n")
+
+		b.WriteString(`<ExtendedData><SchemaData schemaUrl="#` + schemaID + `">`)
+		for i, attr := range l.attributes {
+			if r.values[i] == nil {
+				continue
+			}
+			b.WriteString(`<SimpleData name="`)
+			xml.EscapeText(b, []byte(attr.name))
+			b.WriteString(`">`)
+			xml.EscapeText(b, []byte(formatValue(r.values[i])))
+			b.WriteString("</SimpleData>")
+		}
+		b.WriteString("</SchemaData></ExtendedData>\n")
+
+		writeKMLGeometry(b, r.geometry)
+		b.WriteString("</Placemark>\n")
+	}
+
+	b.WriteString("</Document>\n</kml>\n")
+	return b.Flush()
+}
+
+// writeKMLGeometry escribe la geometría; las que tienen más de una parte van
+// dentro de un MultiGeometry
+func writeKMLGeometry(b *bufio.Writer, g geometry) {
+	parts := len(g.points) + len(g.lines) + len(g.polygons)
+	if parts == 0 {
+		return
+	}
+	if parts > 1 {
+		b.WriteString("<MultiGeometry>\n")
+	}
+	for _, pos := range g.points {
+		b.WriteString("<Point><coordinates>")
+		writeKMLCoordinates(b, [][]float64{pos})
+		b.WriteString("</coordinates></Point>\n")
+	}
+	for _, line := range g.lines {
+		b.WriteString("<LineString><coordinates>")
+		writeKMLCoordinates(b, line)
+		b.WriteString("</coordinates></LineString>\n")
+	}
+	for _, polygon := range g.polygons {
+		b.WriteString("<Polygon>\n")
+		for i, ring := range polygon {
+			boundary := "innerBoundaryIs"
+			if i == 0 {
+				boundary = "outerBoundaryIs"
+			}
+			b.WriteString("<" + boundary + "><LinearRing><coordinates>")
+			writeKMLCoordinates(b, ring)
+			b.WriteString("</coordinates></LinearRing></" + boundary + ">\n")
+		}
+		b.WriteString("</Polygon>\n")
+	}
+	if parts > 1 {
+		b.WriteString("</MultiGeometry>\n")
+	}
+}
+
+// writeKMLCoordinates escribe las posiciones como "lon,lat lon,lat ..."
+func writeKMLCoordinates(b *bufio.Writer, positions [][]float64) {
+	for i, pos := range positions {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(formatFloat(pos[0]))
+		b.WriteByte(',')
+		b.WriteString(formatFloat(pos[1]))
+	}
+}
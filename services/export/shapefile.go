@@ -0,0 +1,356 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"chivomap.com/spatial"
+)
+
+// Tipos de shape del formato ESRI
+const (
+	shapeNull       = 0
+	shapePoint      = 1
+	shapePolyLine   = 3
+	shapePolygon    = 5
+	shapeMultiPoint = 8
+)
+
+// Límites del formato dBASE
+const (
+	dbfNameLength = 10
+	dbfTextWidth  = 254
+)
+
+// dbfField es una columna de la tabla .dbf
+type dbfField struct {
+	name     string
+	kind     byte // C, N o L
+	width    int
+	decimals int
+}
+
+// writeShapefile escribe un zip con los archivos .shp, .shx, .dbf, .prj (con la
+// definición del sistema de coordenadas) y .cpg de la capa. Un Shapefile solo admite un tipo de geometría: los features de
+// otra familia que la más común de la capa se escriben sin geometría.
+func writeShapefile(w io.Writer, name string, l *layer, crs *spatial.CRS) error {
+	base := layerName(name)
+	shp, shx := encodeShapes(l)
+
+	files := []struct {
+		extension string
+		content   []byte
+	}{
+		{".shp", shp},
+		{".shx", shx},
+		{".dbf", encodeDBF(l)},
+		{".prj", []byte(crs.WKT)},
+		{".cpg", []byte("UTF-8")},
+	}
+
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		entry, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     base + file.extension,
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("error creando %s%s: %w", base, file.extension, err)
+		}
+		if _, err := entry.Write(file.content); err != nil {
+			return fmt.Errorf("error escribiendo %s%s: %w", base, file.extension, err)
+		}
+	}
+	return archive.Close()
+}
+
+// shapeType retorna el tipo de shape de la capa según su familia de geometría
+func (l *layer) shapeType() int32 {
+	switch l.family {
+	case familyPolygon:
+		return shapePolygon
+	case familyLine:
+		return shapePolyLine
+	case familyPoint:
+		for _, r := range l.records {
+			if r.geometry.family == familyPoint && len(r.geometry.points) > 1 {
+				return shapeMultiPoint
+			}
+		}
+		return shapePoint
+	}
+	return shapeNull
+}
+
+// encodeShapes codifica los archivos .shp y .shx
+func encodeShapes(l *layer) ([]byte, []byte) {
+	layerType := l.shapeType()
+	var shp, shx bytes.Buffer
+	writeShapeHeader(&shp, layerType, l)
+	writeShapeHeader(&shx, layerType, l)
+
+	for i, r := range l.records {
+		var content []byte
+		if r.geometry.family == l.family {
+			content = encodeShape(layerType, r.geometry)
+		}
+		if content == nil {
+			content = binary.LittleEndian.AppendUint32(nil, shapeNull)
+		}
+
+		offset := shp.Len() / 2 // en palabras de 16 bits
+		binary.Write(&shp, binary.BigEndian, int32(i+1))
+		binary.Write(&shp, binary.BigEndian, int32(len(content)/2))
+		shp.Write(content)
+
+		binary.Write(&shx, binary.BigEndian, int32(offset))
+		binary.Write(&shx, binary.BigEndian, int32(len(content)/2))
+	}
+
+	// El largo total de cada archivo va en el encabezado, en palabras de 16 bits
+	shpBytes, shxBytes := shp.Bytes(), shx.Bytes()
+	binary.BigEndian.PutUint32(shpBytes[24:], uint32(len(shpBytes)/2))
+	binary.BigEndian.PutUint32(shxBytes[24:], uint32(len(shxBytes)/2))
+	return shpBytes, shxBytes
+}
+
+// writeShapeHeader escribe el encabezado de 100 bytes común a .shp y .shx. El
+// largo del archivo queda en 0 y se completa al terminar. El rectángulo es el
+// de las geometrías que se escriben (las de la familia de la capa), o ceros si
+// no hay ninguna.
+func writeShapeHeader(b *bytes.Buffer, shapeType int32, l *layer) {
+	binary.Write(b, binary.BigEndian, int32(9994))
+	b.Write(make([]byte, 20))
+	binary.Write(b, binary.BigEndian, int32(0))
+	binary.Write(b, binary.LittleEndian, int32(1000))
+	binary.Write(b, binary.LittleEndian, shapeType)
+
+	bounds := spatial.EmptyBBox()
+	if shapeType != shapeNull {
+		for _, r := range l.records {
+			if r.geometry.family == l.family {
+				r.geometry.extend(&bounds)
+			}
+		}
+	}
+	if bounds.IsEmpty() {
+		bounds = [4]float64{}
+	}
+	binary.Write(b, binary.LittleEndian, [8]float64{bounds[0], bounds[1], bounds[2], bounds[3]})
+}
+
+// encodeShape codifica el contenido de un registro del .shp. Retorna nil si
+// la geometría no tiene posiciones que escribir; el registro se escribe
+// entonces como shape nulo.
+func encodeShape(shapeType int32, g geometry) []byte {
+	var candidates [][][]float64
+	switch shapeType {
+	case shapePoint:
+		if len(g.points) == 0 {
+			return nil
+		}
+		b := binary.LittleEndian.AppendUint32(nil, uint32(shapeType))
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(g.points[0][0]))
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(g.points[0][1]))
+	case shapePolygon:
+		for _, polygon := range g.orientedPolygons() {
+			candidates = append(candidates, polygon...)
+		}
+	case shapePolyLine:
+		candidates = g.lines
+	case shapeMultiPoint:
+		candidates = [][][]float64{g.points}
+	}
+
+	// Las partes vacías no son válidas en el formato
+	var parts [][][]float64
+	for _, part := range candidates {
+		if len(part) > 0 {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	b := binary.LittleEndian.AppendUint32(nil, uint32(shapeType))
+
+	bounds := g.bounds()
+	for _, value := range bounds {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(value))
+	}
+
+	total := 0
+	for _, part := range parts {
+		total += len(part)
+	}
+	if shapeType != shapeMultiPoint {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(parts)))
+	}
+	b = binary.LittleEndian.AppendUint32(b, uint32(total))
+	if shapeType != shapeMultiPoint {
+		start := 0
+		for _, part := range parts {
+			b = binary.LittleEndian.AppendUint32(b, uint32(start))
+			start += len(part)
+		}
+	}
+	for _, part := range parts {
+		for _, pos := range part {
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(pos[0]))
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(pos[1]))
+		}
+	}
+	return b
+}
+
+// dbfFields define las columnas de la tabla .dbf. Los nombres se recortan a 10
+// caracteres (agregando un sufijo numérico si se repiten) y el ID del feature
+// va en la primera columna.
+func dbfFields(l *layer) []dbfField {
+	fields := make([]dbfField, 0, len(l.attributes)+1)
+	used := make(map[string]bool)
+	add := func(name string, kind byte, width, decimals int) {
+		base := dbfName(name)
+		unique := base
+		for n := 2; used[strings.ToUpper(unique)]; n++ {
+			suffix := strconv.Itoa(n)
+			unique = base[:min(len(base), dbfNameLength-len(suffix))] + suffix
+		}
+		used[strings.ToUpper(unique)] = true
+		fields = append(fields, dbfField{name: unique, kind: kind, width: width, decimals: decimals})
+	}
+
+	idWidth := 1
+	for _, r := range l.records {
+		idWidth = max(idWidth, len(r.id))
+	}
+	add("id", 'C', min(idWidth, dbfTextWidth), 0)
+
+	for i, attr := range l.attributes {
+		switch attr.kind {
+		case attrInteger:
+			add(attr.name, 'N', 18, 0)
+		case attrReal:
+			add(attr.name, 'N', 24, 8)
+		case attrBool:
+			add(attr.name, 'L', 1, 0)
+		default:
+			width := 1
+			for _, r := range l.records {
+				if text, ok := r.values[i].(string); ok {
+					width = max(width, len(text))
+				}
+			}
+			add(attr.name, 'C', min(width, dbfTextWidth), 0)
+		}
+	}
+	return fields
+}
+
+// dbfName convierte un nombre de propiedad en un nombre de columna dBASE: solo
+// letras, números y guiones bajos, con un máximo de 10 caracteres
+func dbfName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if b.Len() == dbfNameLength {
+			break
+		}
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "campo"
+	}
+	return b.String()
+}
+
+// encodeDBF codifica la tabla de atributos en formato dBASE III con textos en
+// UTF-8 (declarado en el .cpg)
+func encodeDBF(l *layer) []byte {
+	fields := dbfFields(l)
+	recordLength := 1
+	for _, field := range fields {
+		recordLength += field.width
+	}
+
+	var b bytes.Buffer
+	now := time.Now()
+	b.Write([]byte{0x03, byte(now.Year() - 1900), byte(now.Month()), byte(now.Day())})
+	binary.Write(&b, binary.LittleEndian, uint32(len(l.records)))
+	binary.Write(&b, binary.LittleEndian, uint16(32+32*len(fields)+1))
+	binary.Write(&b, binary.LittleEndian, uint16(recordLength))
+	b.Write(make([]byte, 20))
+
+	for _, field := range fields {
+		var descriptor [32]byte
+		copy(descriptor[:11], field.name)
+		descriptor[11] = field.kind
+		descriptor[16] = byte(field.width)
+		descriptor[17] = byte(field.decimals)
+		b.Write(descriptor[:])
+	}
+	b.WriteByte(0x0D)
+
+	for _, r := range l.records {
+		b.WriteByte(' ') // registro no borrado
+		values := append([]any{r.id}, r.values...)
+		for i, field := range fields {
+			b.WriteString(dbfValue(field, values[i]))
+		}
+	}
+	b.WriteByte(0x1A)
+	return b.Bytes()
+}
+
+// dbfValue formatea un valor con el ancho fijo de su columna
+func dbfValue(field dbfField, value any) string {
+	switch field.kind {
+	case 'L':
+		switch value {
+		case true:
+			return "T"
+		case false:
+			return "F"
+		}
+		return "?"
+	case 'N':
+		text := ""
+		switch v := value.(type) {
+		case int64:
+			text = strconv.FormatInt(v, 10)
+		case float64:
+			text = strconv.FormatFloat(v, 'f', field.decimals, 64)
+		}
+		if len(text) > field.width {
+			text = ""
+		}
+		return strings.Repeat(" ", field.width-len(text)) + text
+	}
+
+	text := truncateUTF8(formatValue(value), field.width)
+	return text + strings.Repeat(" ", field.width-len(text))
+}
+
+// truncateUTF8 recorta el texto a un máximo de bytes sin partir caracteres
+func truncateUTF8(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	return text[:limit]
+}
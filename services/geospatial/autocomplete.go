@@ -0,0 +1,190 @@
+package geospatial
+
+import (
+	"sort"
+	"strings"
+
+	"chivomap.com/types"
+	"chivomap.com/utils"
+)
+
+// Tipos de coincidencia, de mejor a peor
+const (
+	matchExact = iota
+	matchPrefix
+	matchWord
+	matchContains
+	matchFuzzy
+)
+
+var matchNames = map[int]string{
+	matchExact:    "exacta",
+	matchPrefix:   "prefijo",
+	matchWord:     "palabra",
+	matchContains: "contiene",
+	matchFuzzy:    "aproximada",
+}
+
+var levelRank = map[string]int{
+	NivelDepartamento: 0,
+	NivelMunicipio:    1,
+	NivelDistrito:     2,
+}
+
+// scoredSuggestion acompaña una sugerencia con los criterios de orden
+type scoredSuggestion struct {
+	types.PlaceSuggestion
+	match      int
+	normalized string
+}
+
+// Autocomplete busca q entre los departamentos, municipios y distritos de la
+// jerarquía, sin importar mayúsculas ni tildes. Acepta coincidencias exactas,
+// por prefijo (del nombre o de una de sus palabras), parciales y aproximadas
+// con una distancia de edición acotada según el largo de q. Los resultados se
+// ordenan por tipo de coincidencia, distancia, nivel (departamento primero) y
+// nombre. nivel, si no está vacío, restringe la búsqueda a ese nivel.
+func Autocomplete(hierarchy *types.GeoHierarchy, q, nivel string, limit int) []types.PlaceSuggestion {
+	query := utils.NormalizeText(q)
+	if query == "" || limit <= 0 {
+		return []types.PlaceSuggestion{}
+	}
+	maxDistance := maxEditDistance(query)
+
+	var results []scoredSuggestion
+	var visit func(units []*types.AdminUnit, departamento, municipio string)
+	visit = func(units []*types.AdminUnit, departamento, municipio string) {
+		for _, unit := range units {
+			if nivel == "" || unit.Nivel == nivel {
+				name := utils.NormalizeText(unit.Nombre)
+				if match, distance, ok := matchName(name, query, maxDistance); ok {
+					results = append(results, scoredSuggestion{
+						PlaceSuggestion: types.PlaceSuggestion{
+							ID:           unit.ID,
+							Nombre:       unit.Nombre,
+							Nivel:        unit.Nivel,
+							Departamento: departamento,
+							Municipio:    municipio,
+							Coincidencia: matchNames[match],
+							Distancia:    distance,
+						},
+						match:      match,
+						normalized: name,
+					})
+				}
+			}
+
+			switch unit.Nivel {
+			case NivelDepartamento:
+				visit(unit.Hijos, unit.Nombre, "")
+			case NivelMunicipio:
+				visit(unit.Hijos, departamento, unit.Nombre)
+			}
+		}
+	}
+	visit(hierarchy.Departamentos, "", "")
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.match != b.match {
+			return a.match < b.match
+		}
+		if a.Distancia != b.Distancia {
+			return a.Distancia < b.Distancia
+		}
+		if levelRank[a.Nivel] != levelRank[b.Nivel] {
+			return levelRank[a.Nivel] < levelRank[b.Nivel]
+		}
+		if len(a.normalized) != len(b.normalized) {
+			return len(a.normalized) < len(b.normalized)
+		}
+		return a.normalized < b.normalized
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	suggestions := make([]types.PlaceSuggestion, len(results))
+	for i, r := range results {
+		suggestions[i] = r.PlaceSuggestion
+	}
+	return suggestions
+}
+
+// matchName clasifica la coincidencia de query (ya normalizada) con name
+func matchName(name, query string, maxDistance int) (match, distance int, ok bool) {
+	switch {
+	case name == query:
+		return matchExact, 0, true
+	case strings.HasPrefix(name, query):
+		return matchPrefix, 0, true
+	case strings.Contains(name, " "+query):
+		return matchWord, 0, true
+	case strings.Contains(name, query):
+		return matchContains, 0, true
+	case maxDistance == 0:
+		return 0, 0, false
+	}
+
+	// Comparar contra el nombre completo y contra su inicio del largo de la
+	// consulta, para tolerar errores mientras el usuario sigue escribiendo
+	nameRunes, queryRunes := []rune(name), []rune(query)
+	best, found := boundedLevenshtein(nameRunes, queryRunes, maxDistance)
+	if len(nameRunes) > len(queryRunes) {
+		if d, ok := boundedLevenshtein(nameRunes[:len(queryRunes)], queryRunes, maxDistance); ok && (!found || d < best) {
+			best, found = d, true
+		}
+	}
+	if !found {
+		return 0, 0, false
+	}
+	return matchFuzzy, best, true
+}
+
+// maxEditDistance retorna las ediciones toleradas según el largo de la consulta
+func maxEditDistance(query string) int {
+	switch n := len([]rune(query)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// boundedLevenshtein calcula la distancia de edición entre a y b, abandonando
+// en cuanto supera maxDistance. ok es false si la distancia es mayor.
+func boundedLevenshtein(a, b []rune, maxDistance int) (int, bool) {
+	if diff := len(a) - len(b); diff > maxDistance || -diff > maxDistance {
+		return 0, false
+	}
+
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > maxDistance {
+			return 0, false
+		}
+		prev, curr = curr, prev
+	}
+
+	if prev[len(b)] > maxDistance {
+		return 0, false
+	}
+	return prev[len(b)], true
+}
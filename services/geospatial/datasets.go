@@ -0,0 +1,69 @@
+package geospatial
+
+import (
+	"sort"
+
+	"chivomap.com/interfaces"
+	"chivomap.com/spatial"
+	"chivomap.com/types"
+	"chivomap.com/utils"
+)
+
+// FilterDataset retorna, en el orden del archivo, los features de la capa name
+// cuya propiedad property contiene query (búsqueda parcial, sin importar
+// mayúsculas ni tildes) y cuya geometría toca el rectángulo bbox. Sin property
+// se busca en todas las propiedades de texto; query y bbox son opcionales. Los
+// polígonos se comparan contra bbox a resolución completa y las demás
+// geometrías por su rectángulo envolvente. ok es false si la capa no existe.
+func FilterDataset(registry interfaces.DatasetRegistry, name, property, query string, bbox *spatial.BBox) (*types.GeoFeatureCollection, bool) {
+	geo, index, ok := registry.Dataset(name)
+	if !ok {
+		return nil, false
+	}
+
+	var candidates []int
+	if bbox != nil {
+		shape := spatial.ShapeFromBBox(*bbox)
+		index.Search(*bbox, func(id int) bool {
+			if polys := spatial.Polygons(geo.Features[id].Geometry); len(polys) == 0 || shape.IntersectsPolygons(polys) {
+				candidates = append(candidates, id)
+			}
+			return true
+		})
+		sort.Ints(candidates)
+	} else {
+		candidates = make([]int, len(geo.Features))
+		for i := range candidates {
+			candidates[i] = i
+		}
+	}
+
+	normalizedQuery := utils.NormalizeText(query)
+	features := make([]types.GeoFeature, 0)
+	for _, id := range candidates {
+		feature := geo.Features[id]
+		if normalizedQuery != "" && !matchesAnyProperty(feature.Properties, property, normalizedQuery) {
+			continue
+		}
+		features = append(features, feature)
+	}
+
+	return &types.GeoFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: features,
+	}, true
+}
+
+// matchesAnyProperty es como matchesProperty, pero con property vacía busca
+// en todas las propiedades de texto
+func matchesAnyProperty(properties map[string]interface{}, property, normalizedQuery string) bool {
+	if property != "" {
+		return matchesProperty(properties, property, normalizedQuery)
+	}
+	for key := range properties {
+		if matchesProperty(properties, key, normalizedQuery) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package geospatial
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"chivomap.com/interfaces"
+	"chivomap.com/types"
+	"chivomap.com/utils"
+)
+
+// Niveles de la jerarquía administrativa
+const (
+	NivelDepartamento = types.NivelDepartamento
+	NivelMunicipio    = types.NivelMunicipio
+	NivelDistrito     = types.NivelDistrito
+)
+
+// GetHierarchy construye el árbol departamento → municipio → distrito a partir
+// de las propiedades D, M y NAM de cada feature. Los nombres se ordenan
+// alfabéticamente sin considerar tildes.
+func GetHierarchy(staticCache interfaces.StaticCacheService) (*types.GeoHierarchy, error) {
+	geo, err := staticCache.GetGeoData()
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo datos geoespaciales para la jerarquía: %w", err)
+	}
+
+	units := make(map[string]*types.AdminUnit)
+	var departamentos []*types.AdminUnit
+
+	// child retorna el hijo con el nombre indicado, creándolo si no existe
+	child := func(parent *types.AdminUnit, nivel, nombre string) *types.AdminUnit {
+		id := utils.HierarchicalID(nombre)
+		if parent != nil {
+			id = parent.ID + "." + id
+		}
+		if unit, ok := units[id]; ok {
+			return unit
+		}
+
+		unit := &types.AdminUnit{ID: id, Nombre: nombre, Nivel: nivel}
+		units[id] = unit
+		if parent != nil {
+			parent.Hijos = append(parent.Hijos, unit)
+		} else {
+			departamentos = append(departamentos, unit)
+		}
+		return unit
+	}
+
+	for _, feat := range geo.Features {
+		d, _ := feat.Properties["D"].(string)
+		m, _ := feat.Properties["M"].(string)
+		nam, _ := feat.Properties["NAM"].(string)
+		if strings.TrimSpace(d) == "" || strings.TrimSpace(m) == "" || strings.TrimSpace(nam) == "" {
+			continue
+		}
+
+		departamento := child(nil, NivelDepartamento, d)
+		municipio := child(departamento, NivelMunicipio, m)
+		child(municipio, NivelDistrito, nam)
+	}
+
+	hierarchy := &types.GeoHierarchy{
+		Departamentos:      departamentos,
+		TotalDepartamentos: len(departamentos),
+	}
+	sortUnits(departamentos)
+	for _, departamento := range departamentos {
+		sortUnits(departamento.Hijos)
+		departamento.TotalMunicipios = len(departamento.Hijos)
+		for _, municipio := range departamento.Hijos {
+			sortUnits(municipio.Hijos)
+			municipio.TotalDistritos = len(municipio.Hijos)
+			departamento.TotalDistritos += municipio.TotalDistritos
+		}
+		hierarchy.TotalMunicipios += departamento.TotalMunicipios
+		hierarchy.TotalDistritos += departamento.TotalDistritos
+	}
+
+	return hierarchy, nil
+}
+
+// FindAdminUnit busca entre units la unidad cuyo ID completo, slug o nombre
+// (sin importar mayúsculas ni tildes) coincide con key
+func FindAdminUnit(units []*types.AdminUnit, key string) *types.AdminUnit {
+	slug := utils.Slugify(key)
+	if slug == "" {
+		return nil
+	}
+	for _, unit := range units {
+		if unit.ID == key || unit.ID[strings.LastIndex(unit.ID, ".")+1:] == slug {
+			return unit
+		}
+	}
+	return nil
+}
+
+// sortUnits ordena las unidades por nombre normalizado
+func sortUnits(units []*types.AdminUnit) {
+	sort.SliceStable(units, func(i, j int) bool {
+		return utils.NormalizeText(units[i].Nombre) < utils.NormalizeText(units[j].Nombre)
+	})
+}
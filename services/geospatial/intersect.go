@@ -0,0 +1,75 @@
+package geospatial
+
+import (
+	"fmt"
+
+	"chivomap.com/interfaces"
+	"chivomap.com/spatial"
+	"chivomap.com/types"
+)
+
+// FeaturesIntersecting retorna los features cuyo polígono toca o se superpone con la forma,
+// en el mismo orden de la colección original. La intersección se evalúa a resolución
+// completa; con tolerance > 0 las geometrías retornadas son las simplificadas.
+// El nivel elige entre distritos, municipios y departamentos.
+func FeaturesIntersecting(staticCache interfaces.StaticCacheService, shape spatial.Shape, nivel string, tolerance float64) (*types.GeoFeatureCollection, error) {
+	snapshot, err := staticCache.Snapshot()
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo datos geoespaciales para intersección: %w", err)
+	}
+	index, err := snapshot.LevelIndex(nivel)
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo índice espacial para intersección: %w", err)
+	}
+
+	// Los features simplificados conservan el orden, por lo que comparten los IDs del índice
+	geo, err := snapshot.LevelGeoData(nivel, tolerance)
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo geometrías simplificadas (tolerancia %g): %w", tolerance, err)
+	}
+
+	ids := index.Intersecting(shape)
+	features := make([]types.GeoFeature, 0, len(ids))
+	for _, id := range ids {
+		features = append(features, geo.Features[id])
+	}
+
+	return &types.GeoFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: features,
+	}, nil
+}
+
+// FeatureByID retorna el feature del nivel con el ID de GeoJSON indicado, o nil
+// si no existe. Con tolerance > 0 la geometría retornada es la simplificada.
+func FeatureByID(staticCache interfaces.StaticCacheService, featureID, nivel string, tolerance float64) (*types.GeoFeature, error) {
+	snapshot, err := staticCache.Snapshot()
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo datos geoespaciales para el feature %s: %w", featureID, err)
+	}
+	index, err := snapshot.LevelIndex(nivel)
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo índice espacial para el feature %s: %w", featureID, err)
+	}
+
+	id, ok := index.ByID(featureID)
+	if !ok {
+		return nil, nil
+	}
+
+	geo, err := snapshot.LevelGeoData(nivel, tolerance)
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo geometrías simplificadas (tolerancia %g): %w", tolerance, err)
+	}
+	return &geo.Features[id], nil
+}
+
+// LevelFeatures retorna todos los features del nivel, en el orden de la
+// colección. Con tolerance > 0 las geometrías son las simplificadas.
+func LevelFeatures(staticCache interfaces.StaticCacheService, nivel string, tolerance float64) (*types.GeoFeatureCollection, error) {
+	geo, err := staticCache.GetLevelGeoData(nivel, tolerance)
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo features de nivel %s (tolerancia %g): %w", levelOrDefault(nivel), tolerance, err)
+	}
+	return geo, nil
+}
@@ -0,0 +1,31 @@
+package geospatial
+
+import "slices"
+
+// LevelProperties son las propiedades de los features de cada nivel por las que
+// se puede filtrar. Los departamentos y municipios se construyen uniendo los
+// distritos, por lo que solo conservan las propiedades que los identifican.
+var LevelProperties = map[string][]string{
+	NivelDepartamento: {"D"},
+	NivelMunicipio:    {"D", "M"},
+	NivelDistrito:     {"D", "M", "NAM"},
+}
+
+// ValidLevel indica si el nivel es departamento, municipio, distrito o "" (distrito)
+func ValidLevel(nivel string) bool {
+	_, ok := LevelProperties[levelOrDefault(nivel)]
+	return ok
+}
+
+// LevelHasProperty indica si los features del nivel tienen la propiedad
+func LevelHasProperty(nivel, property string) bool {
+	return slices.Contains(LevelProperties[levelOrDefault(nivel)], property)
+}
+
+// levelOrDefault retorna el nivel, o distrito si no se indicó
+func levelOrDefault(nivel string) string {
+	if nivel == "" {
+		return NivelDistrito
+	}
+	return nivel
+}
@@ -0,0 +1,82 @@
+package geospatial
+
+import (
+	"fmt"
+	"math"
+
+	"chivomap.com/interfaces"
+	"chivomap.com/types"
+)
+
+// TerritorialWatersKm es el ancho del mar territorial (12 millas náuticas), la
+// distancia máxima por defecto a la que la geocodificación inversa atribuye un
+// punto offshore al distrito más cercano
+const TerritorialWatersKm = 22.224
+
+// FeatureAt retorna el feature cuyo polígono contiene el punto, o nil si el punto
+// no cae dentro de ninguno (por ejemplo, un epicentro en el mar).
+func FeatureAt(staticCache interfaces.StaticCacheService, lon, lat float64) (*types.GeoFeature, error) {
+	index, err := staticCache.GetSpatialIndex()
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo índice espacial para ubicar (%f, %f): %w", lon, lat, err)
+	}
+
+	if ids := index.Containing(lon, lat); len(ids) > 0 {
+		return index.Feature(ids[0]), nil
+	}
+	return nil, nil
+}
+
+// Locate retorna la unidad administrativa (D, M, NAM) que contiene el punto. Si
+// ningún polígono lo contiene, se marca como offshore con la distancia al borde
+// del feature más cercano, cuyos nombres se asignan solo si está a menos de
+// maxDistanceKm; más lejos los nombres quedan vacíos.
+func Locate(staticCache interfaces.StaticCacheService, lon, lat, maxDistanceKm float64) (*types.Ubicacion, error) {
+	ubicacion, _, err := ReverseGeocode(staticCache, lon, lat, maxDistanceKm)
+	return ubicacion, err
+}
+
+// ReverseGeocode es como Locate pero también retorna el feature (contenedor o
+// más cercano) del que se tomó la ubicación. El feature es nil si el punto está
+// a más de maxDistanceKm de todos; ambos son nil si no hay features.
+func ReverseGeocode(staticCache interfaces.StaticCacheService, lon, lat, maxDistanceKm float64) (*types.Ubicacion, *types.GeoFeature, error) {
+	feature, err := FeatureAt(staticCache, lon, lat)
+	if err != nil {
+		return nil, nil, err
+	}
+	if feature != nil {
+		return ubicacionFromFeature(feature), feature, nil
+	}
+
+	index, err := staticCache.GetSpatialIndex()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error obteniendo índice espacial para ubicar (%f, %f): %w", lon, lat, err)
+	}
+
+	nearest, nearestDistance := index.Nearest(lon, lat)
+	if nearest < 0 {
+		return nil, nil, nil
+	}
+	distancia := math.Round(nearestDistance*100) / 100
+	if nearestDistance > maxDistanceKm {
+		return &types.Ubicacion{Offshore: true, DistanciaKm: distancia}, nil, nil
+	}
+	feature = index.Feature(nearest)
+
+	ubicacion := ubicacionFromFeature(feature)
+	ubicacion.Offshore = true
+	ubicacion.DistanciaKm = distancia
+	return ubicacion, feature, nil
+}
+
+// ubicacionFromFeature extrae las propiedades administrativas de un feature
+func ubicacionFromFeature(feature *types.GeoFeature) *types.Ubicacion {
+	departamento, _ := feature.Properties["D"].(string)
+	municipio, _ := feature.Properties["M"].(string)
+	distrito, _ := feature.Properties["NAM"].(string)
+	return &types.Ubicacion{
+		Departamento: departamento,
+		Municipio:    municipio,
+		Distrito:     distrito,
+	}
+}
@@ -0,0 +1,127 @@
+package geospatial
+
+import (
+	"fmt"
+
+	"chivomap.com/interfaces"
+	"chivomap.com/types"
+)
+
+// MetricsByID retorna las métricas del feature del nivel con el ID indicado y
+// el feature. Ambos son nil si el ID no existe.
+func MetricsByID(staticCache interfaces.StaticCacheService, featureID, nivel string) (*types.FeatureMetrics, *types.GeoFeature, error) {
+	snapshot, err := staticCache.Snapshot()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error obteniendo datos geoespaciales para métricas de %s: %w", featureID, err)
+	}
+	index, err := snapshot.LevelIndex(nivel)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error obteniendo índice espacial para métricas de %s: %w", featureID, err)
+	}
+	id, ok := index.ByID(featureID)
+	if !ok {
+		return nil, nil, nil
+	}
+
+	metrics, err := snapshot.LevelMetrics(nivel)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error calculando métricas de %s: %w", featureID, err)
+	}
+	return &metrics[id], index.Feature(id), nil
+}
+
+// AllMetrics retorna todos los features del nivel junto con sus métricas, en el mismo orden
+func AllMetrics(staticCache interfaces.StaticCacheService, nivel string) (*types.GeoFeatureCollection, []types.FeatureMetrics, error) {
+	snapshot, err := staticCache.Snapshot()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error obteniendo datos geoespaciales para métricas: %w", err)
+	}
+	geo, err := snapshot.LevelGeoData(nivel, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error obteniendo datos geoespaciales para métricas: %w", err)
+	}
+	metrics, err := snapshot.LevelMetrics(nivel)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error calculando métricas: %w", err)
+	}
+	if len(metrics) != len(geo.Features) {
+		return nil, nil, fmt.Errorf("métricas desincronizadas: %d features y %d métricas", len(geo.Features), len(metrics))
+	}
+	return geo, metrics, nil
+}
+
+// WithMetrics retorna copias de los features con sus métricas agregadas como
+// propiedades (area_km2, perimeter_km, centroid y label_point). Los features
+// del cache no se modifican. Los features deben ser del nivel indicado.
+func WithMetrics(staticCache interfaces.StaticCacheService, features []types.GeoFeature, nivel string) ([]types.GeoFeature, error) {
+	lookup, err := metricsLookup(staticCache, nivel)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.GeoFeature, len(features))
+	for i, feature := range features {
+		result[i] = feature
+		result[i].Properties = addMetrics(feature.Properties, lookup(feature.ID))
+	}
+	return result, nil
+}
+
+// TopoJSONWithMetrics es como WithMetrics pero para las geometrías de una topología
+func TopoJSONWithMetrics(staticCache interfaces.StaticCacheService, topo *types.TopoJSON, nivel string) (*types.TopoJSON, error) {
+	lookup, err := metricsLookup(staticCache, nivel)
+	if err != nil {
+		return nil, err
+	}
+
+	result := *topo
+	result.Objects = make(map[string]types.TopoObject, len(topo.Objects))
+	for name, object := range topo.Objects {
+		geometries := make([]types.Geometry, len(object.Geometries))
+		for i, geom := range object.Geometries {
+			geometries[i] = geom
+			geometries[i].Properties = addMetrics(geom.Properties, lookup(geom.ID))
+		}
+		object.Geometries = geometries
+		result.Objects[name] = object
+	}
+	return &result, nil
+}
+
+// metricsLookup retorna una función que busca las métricas de un feature del nivel por su ID
+func metricsLookup(staticCache interfaces.StaticCacheService, nivel string) (func(featureID string) *types.FeatureMetrics, error) {
+	snapshot, err := staticCache.Snapshot()
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo datos geoespaciales para métricas: %w", err)
+	}
+	index, err := snapshot.LevelIndex(nivel)
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo índice espacial para métricas: %w", err)
+	}
+	metrics, err := snapshot.LevelMetrics(nivel)
+	if err != nil {
+		return nil, fmt.Errorf("error calculando métricas: %w", err)
+	}
+
+	return func(featureID string) *types.FeatureMetrics {
+		if id, ok := index.ByID(featureID); ok && id < len(metrics) {
+			return &metrics[id]
+		}
+		return nil
+	}, nil
+}
+
+// addMetrics copia las propiedades agregando las métricas, si existen
+func addMetrics(properties map[string]interface{}, metrics *types.FeatureMetrics) map[string]interface{} {
+	result := make(map[string]interface{}, len(properties)+4)
+	for k, v := range properties {
+		result[k] = v
+	}
+	if metrics != nil {
+		result["area_km2"] = metrics.AreaKm2
+		result["perimeter_km"] = metrics.PerimeterKm
+		result["centroid"] = metrics.Centroid
+		result["label_point"] = metrics.LabelPoint
+	}
+	return result
+}
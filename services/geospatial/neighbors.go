@@ -0,0 +1,97 @@
+package geospatial
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"chivomap.com/interfaces"
+	"chivomap.com/types"
+	"chivomap.com/utils"
+)
+
+// Neighbors retorna la unidad administrativa con el ID indicado (distrito,
+// municipio o departamento) y las unidades del mismo nivel con las que comparte
+// borde, de mayor a menor longitud de borde. La adyacencia se deriva de los arcs
+// compartidos en el TopoJSON; los municipios y departamentos agregan la de sus
+// distritos. Retorna nil si el ID no existe.
+func Neighbors(staticCache interfaces.StaticCacheService, id string) (*types.AdminUnit, []types.Vecino, error) {
+	// Los features y las adyacencias deben ser de la misma versión del dataset
+	snapshot, err := staticCache.Snapshot()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error obteniendo datos geoespaciales para vecinos de %s: %w", id, err)
+	}
+	geo, adjacency := snapshot.GeoData(), snapshot.Adjacency()
+
+	// Buscar el nivel del ID y los distritos que lo componen
+	var (
+		nivel   string
+		unit    types.Vecino
+		members []int
+	)
+	for _, candidate := range []string{NivelDistrito, NivelMunicipio, NivelDepartamento} {
+		for i := range geo.Features {
+			if u := unitOfFeature(&geo.Features[i], candidate); u.ID == id {
+				unit = u
+				members = append(members, i)
+			}
+		}
+		if len(members) > 0 {
+			nivel = candidate
+			break
+		}
+	}
+	if len(members) == 0 {
+		return nil, nil, nil
+	}
+
+	vecinos := make(map[string]*types.Vecino)
+	for _, member := range members {
+		if member >= len(adjacency) {
+			continue
+		}
+		for _, neighbor := range adjacency[member] {
+			if neighbor.ID >= len(geo.Features) {
+				continue
+			}
+			u := unitOfFeature(&geo.Features[neighbor.ID], nivel)
+			if u.ID == id {
+				continue // borde interno entre distritos de la misma unidad
+			}
+			if vecinos[u.ID] == nil {
+				vecinos[u.ID] = &u
+			}
+			vecinos[u.ID].BordeKm += neighbor.BorderKm
+		}
+	}
+
+	result := make([]types.Vecino, 0, len(vecinos))
+	for _, v := range vecinos {
+		v.BordeKm = math.Round(v.BordeKm*100) / 100
+		result = append(result, *v)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].BordeKm != result[j].BordeKm {
+			return result[i].BordeKm > result[j].BordeKm
+		}
+		return result[i].ID < result[j].ID
+	})
+
+	return &types.AdminUnit{ID: unit.ID, Nombre: unit.Nombre, Nivel: nivel}, result, nil
+}
+
+// unitOfFeature retorna la unidad del nivel indicado a la que pertenece el feature
+func unitOfFeature(feature *types.GeoFeature, nivel string) types.Vecino {
+	d, _ := feature.Properties["D"].(string)
+	m, _ := feature.Properties["M"].(string)
+	nam, _ := feature.Properties["NAM"].(string)
+
+	switch nivel {
+	case NivelDepartamento:
+		return types.Vecino{ID: utils.HierarchicalID(d), Nombre: d, Nivel: nivel}
+	case NivelMunicipio:
+		return types.Vecino{ID: utils.HierarchicalID(d, m), Nombre: m, Nivel: nivel, Departamento: d}
+	default:
+		return types.Vecino{ID: feature.ID, Nombre: nam, Nivel: NivelDistrito, Departamento: d, Municipio: m}
+	}
+}
@@ -0,0 +1,171 @@
+package geospatial
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"chivomap.com/spatial"
+	"chivomap.com/types"
+)
+
+// positionProperties son las propiedades con posiciones lon/lat que agrega
+// include=metrics; se proyectan junto con la geometría
+var positionProperties = []string{"centroid", "label_point"}
+
+// ProjectFeatures retorna copias de los features con la geometría (y las
+// propiedades de posición) proyectadas al sistema indicado. Con WGS84 retorna
+// los mismos features. Los features del cache no se modifican.
+func ProjectFeatures(features []types.GeoFeature, crs *spatial.CRS) []types.GeoFeature {
+	if crs.Geographic() {
+		return features
+	}
+
+	result := make([]types.GeoFeature, len(features))
+	for i, feature := range features {
+		result[i] = feature
+		result[i].Geometry = crs.ProjectGeometry(feature.Geometry)
+		result[i].Properties = projectProperties(feature.Properties, crs)
+	}
+	return result
+}
+
+// ProjectCollection es como ProjectFeatures para una colección; la colección
+// proyectada declara su sistema en el miembro "crs"
+func ProjectCollection(collection *types.GeoFeatureCollection, crs *spatial.CRS) *types.GeoFeatureCollection {
+	if crs.Geographic() {
+		return collection
+	}
+	return &types.GeoFeatureCollection{
+		Type:     collection.Type,
+		CRS:      types.NewNamedCRS(fmt.Sprintf("urn:ogc:def:crs:EPSG::%d", crs.Code)),
+		Features: ProjectFeatures(collection.Features, crs),
+	}
+}
+
+// projectProperties copia las propiedades proyectando las posiciones de las métricas
+func projectProperties(properties map[string]interface{}, crs *spatial.CRS) map[string]interface{} {
+	projected := false
+	for _, key := range positionProperties {
+		if _, ok := properties[key].([2]float64); ok {
+			projected = true
+		}
+	}
+	if !projected {
+		return properties
+	}
+
+	result := make(map[string]interface{}, len(properties))
+	for k, v := range properties {
+		result[k] = v
+	}
+	for _, key := range positionProperties {
+		if pos, ok := properties[key].([2]float64); ok {
+			x, y := crs.Project(pos[0], pos[1])
+			result[key] = [2]float64{x, y}
+		}
+	}
+	return result
+}
+
+// ProjectMetrics retorna las métricas con el centroide y el punto de etiqueta
+// proyectados al sistema indicado. El área y el perímetro son geodésicos y no
+// cambian.
+func ProjectMetrics(metrics types.FeatureMetrics, crs *spatial.CRS) types.FeatureMetrics {
+	for _, pos := range []*[2]float64{&metrics.Centroid, &metrics.LabelPoint} {
+		pos[0], pos[1] = crs.Project(pos[0], pos[1])
+	}
+	return metrics
+}
+
+// ProjectTopoJSON retorna una copia de la topología con los arcs proyectados
+// al sistema indicado. Las coordenadas proyectadas se escriben absolutas y sin
+// Transform: la cuantización del original está en grados. Con WGS84 retorna la
+// misma topología.
+func ProjectTopoJSON(topo *types.TopoJSON, crs *spatial.CRS) (*types.TopoJSON, error) {
+	if crs.Geographic() {
+		return topo, nil
+	}
+
+	quantized := len(topo.Transform.Scale) >= 2 && len(topo.Transform.Translate) >= 2
+	decode := func(x, y float64) (float64, float64) {
+		if !quantized {
+			return x, y
+		}
+		return x*topo.Transform.Scale[0] + topo.Transform.Translate[0], y*topo.Transform.Scale[1] + topo.Transform.Translate[1]
+	}
+
+	arcs := make([][][]float64, len(topo.Arcs))
+	for i, arc := range topo.Arcs {
+		arcs[i] = make([][]float64, 0, len(arc))
+		var x, y float64
+		for _, pos := range arc {
+			if len(pos) < 2 {
+				continue
+			}
+			if quantized {
+				x += pos[0]
+				y += pos[1]
+			} else {
+				x, y = pos[0], pos[1]
+			}
+			px, py := crs.Project(decode(x, y))
+			arcs[i] = append(arcs[i], []float64{px, py})
+		}
+	}
+
+	result := &types.TopoJSON{
+		Type:    topo.Type,
+		Objects: make(map[string]types.TopoObject, len(topo.Objects)),
+		Arcs:    arcs,
+	}
+	for name, object := range topo.Objects {
+		geometries := make([]types.Geometry, len(object.Geometries))
+		for i, geom := range object.Geometries {
+			geometries[i] = geom
+			geometries[i].Properties = projectProperties(geom.Properties, crs)
+
+			// Los puntos guardan sus posiciones en coordinates (cuantizadas, sin deltas)
+			if len(geom.Coordinates) > 0 && string(geom.Coordinates) != "null" {
+				projected, err := projectTopoCoordinates(geom.Coordinates, decode, crs)
+				if err != nil {
+					return nil, fmt.Errorf("error proyectando coordenadas de la geometría %s: %w", geom.ID, err)
+				}
+				geometries[i].Coordinates = projected
+			}
+		}
+		object.Geometries = geometries
+		result.Objects[name] = object
+	}
+	return result, nil
+}
+
+// projectTopoCoordinates decodifica y proyecta las posiciones de una geometría
+// de puntos del TopoJSON
+func projectTopoCoordinates(raw json.RawMessage, decode func(x, y float64) (float64, float64), crs *spatial.CRS) (json.RawMessage, error) {
+	var coords any
+	if err := json.Unmarshal(raw, &coords); err != nil {
+		return nil, err
+	}
+
+	var walk func(v any) any
+	walk = func(v any) any {
+		list, ok := v.([]interface{})
+		if !ok {
+			return v
+		}
+		if len(list) >= 2 {
+			x, okX := list[0].(float64)
+			y, okY := list[1].(float64)
+			if okX && okY {
+				px, py := crs.Project(decode(x, y))
+				return []float64{px, py}
+			}
+		}
+		out := make([]interface{}, len(list))
+		for i, child := range list {
+			out[i] = walk(child)
+		}
+		return out
+	}
+	return json.Marshal(walk(coords))
+}
@@ -0,0 +1,254 @@
+package geospatial
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"chivomap.com/types"
+	"chivomap.com/utils"
+)
+
+// Operadores de las condiciones de 'where'
+const (
+	opEq       = "eq"
+	opNe       = "ne"
+	opIn       = "in"
+	opContains = "contains"
+	opGt       = "gt"
+	opGte      = "gte"
+	opLt       = "lt"
+	opLte      = "lte"
+)
+
+// numericOps son los operadores que solo comparan números
+var numericOps = map[string]bool{opGt: true, opGte: true, opLt: true, opLte: true}
+
+// Condition es una condición sobre una propiedad: key:op:value. Con el
+// operador in los valores se separan con "|".
+type Condition struct {
+	Key    string
+	Op     string
+	Values []string
+}
+
+// FeatureQuery filtra y recorta los features de una consulta: solo se
+// retornan los que cumplen todas las condiciones de Where, con las propiedades
+// de Fields (todas si está vacío) y sin geometría si Geometry es false.
+type FeatureQuery struct {
+	Where    []Condition
+	Fields   []string
+	Geometry bool
+}
+
+// ParseWhere interpreta condiciones "key:op:value" separadas por comas (por
+// ejemplo "D:eq:SAN SALVADOR,M:in:San Salvador Centro|San Salvador Norte").
+// Una coma o una barra que forma parte de un valor, o dos puntos que forman
+// parte de una clave, se escapan con "\" ("\,", "\|", "\:"; "\\" es una barra
+// invertida); en el valor los dos puntos no necesitan escaparse. Cada elemento
+// de raw es un parámetro 'where'; todas las condiciones se combinan con AND.
+func ParseWhere(raw []string) ([]Condition, error) {
+	var conditions []Condition
+	for _, param := range raw {
+		for _, part := range splitUnescaped(param, ',', -1) {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			pieces := splitUnescaped(part, ':', 3)
+			if len(pieces) != 3 {
+				return nil, fmt.Errorf("condición %q inválida: use clave:operador:valor", part)
+			}
+
+			condition := Condition{
+				Key: unescape(strings.TrimSpace(pieces[0])),
+				Op:  strings.ToLower(unescape(strings.TrimSpace(pieces[1]))),
+			}
+			if condition.Key == "" {
+				return nil, fmt.Errorf("condición %q inválida: falta la propiedad", part)
+			}
+
+			value := strings.TrimSpace(pieces[2])
+			switch condition.Op {
+			case opIn:
+				for _, v := range splitUnescaped(value, '|', -1) {
+					condition.Values = append(condition.Values, unescape(strings.TrimSpace(v)))
+				}
+			case opEq, opNe, opContains, opGt, opGte, opLt, opLte:
+				value = unescape(value)
+				condition.Values = []string{value}
+			default:
+				return nil, fmt.Errorf("operador %q inválido en %q: use eq, ne, in, contains, gt, gte, lt o lte", condition.Op, part)
+			}
+			if numericOps[condition.Op] {
+				if _, ok := parseNumber(value); !ok {
+					return nil, fmt.Errorf("valor %q inválido en %q: %s compara números", value, part, condition.Op)
+				}
+			}
+			conditions = append(conditions, condition)
+		}
+	}
+	return conditions, nil
+}
+
+// splitUnescaped separa s en cada sep que no va precedido de "\", en a lo sumo
+// n partes si n > 0 (como strings.SplitN). Las secuencias de escape se
+// conservan; unescape las resuelve al final.
+func splitUnescaped(s string, sep byte, n int) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s) && (n <= 0 || len(parts) < n-1); i++ {
+		switch s[i] {
+		case '\\':
+			i++ // el carácter siguiente es literal
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unescape quita las barras invertidas de escape ("\," → ",", "\\" → "\")
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// ParseFields interpreta una lista de propiedades separadas por comas
+func ParseFields(raw string) []string {
+	var fields []string
+	for _, field := range strings.Split(raw, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Apply retorna copias de los features que cumplen las condiciones, recortados
+// a los campos pedidos. Los features del cache no se modifican.
+func (q *FeatureQuery) Apply(features []types.GeoFeature) []types.GeoFeature {
+	result := make([]types.GeoFeature, 0, len(features))
+	for _, feature := range features {
+		if !q.Matches(feature.Properties) {
+			continue
+		}
+		if len(q.Fields) > 0 {
+			properties := make(map[string]interface{}, len(q.Fields))
+			for _, field := range q.Fields {
+				if value, ok := feature.Properties[field]; ok {
+					properties[field] = value
+				}
+			}
+			feature.Properties = properties
+		}
+		if !q.Geometry {
+			feature.Geometry = nil
+		}
+		result = append(result, feature)
+	}
+	return result
+}
+
+// Matches indica si las propiedades cumplen todas las condiciones. Una
+// propiedad que no existe (o es null) no cumple ninguna condición.
+func (q *FeatureQuery) Matches(properties map[string]interface{}) bool {
+	for _, condition := range q.Where {
+		value, ok := properties[condition.Key]
+		if !ok || value == nil || !condition.matches(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches evalúa la condición sobre un valor. Los números se comparan como
+// números y los textos sin importar mayúsculas ni tildes.
+func (c Condition) matches(value any) bool {
+	switch c.Op {
+	case opEq:
+		return equalValue(value, c.Values[0])
+	case opNe:
+		return !equalValue(value, c.Values[0])
+	case opIn:
+		for _, v := range c.Values {
+			if equalValue(value, v) {
+				return true
+			}
+		}
+		return false
+	case opContains:
+		return strings.Contains(utils.NormalizeText(textValue(value)), utils.NormalizeText(c.Values[0]))
+	}
+
+	number, ok := numericValue(value)
+	if !ok {
+		return false
+	}
+	target, _ := parseNumber(c.Values[0])
+	switch c.Op {
+	case opGt:
+		return number > target
+	case opGte:
+		return number >= target
+	case opLt:
+		return number < target
+	case opLte:
+		return number <= target
+	}
+	return false
+}
+
+// equalValue compara un valor de propiedad con el valor de una condición
+func equalValue(value any, expected string) bool {
+	if number, ok := numericValue(value); ok {
+		target, ok := parseNumber(expected)
+		return ok && number == target
+	}
+	if b, ok := value.(bool); ok {
+		return strings.EqualFold(expected, strconv.FormatBool(b))
+	}
+	return utils.NormalizeText(textValue(value)) == utils.NormalizeText(expected)
+}
+
+// numericValue retorna el valor de una propiedad numérica
+func numericValue(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// textValue representa una propiedad como texto
+func textValue(value any) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	return fmt.Sprint(value)
+}
+
+// parseNumber interpreta el valor de una condición como número finito
+func parseNumber(value string) (float64, bool) {
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, false
+	}
+	return number, true
+}
@@ -0,0 +1,94 @@
+package geospatial
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWhere(t *testing.T) {
+	for _, tc := range []struct {
+		raw  string
+		want []Condition
+	}{
+		{
+			`D:eq:SAN SALVADOR,M:in:San Salvador Centro|San Salvador Norte`,
+			[]Condition{
+				{Key: "D", Op: "eq", Values: []string{"SAN SALVADOR"}},
+				{Key: "M", Op: "in", Values: []string{"San Salvador Centro", "San Salvador Norte"}},
+			},
+		},
+		{
+			`NAM:eq:Uno\, Dos,M:in:A\|B|C`,
+			[]Condition{
+				{Key: "NAM", Op: "eq", Values: []string{"Uno, Dos"}},
+				{Key: "M", Op: "in", Values: []string{"A|B", "C"}},
+			},
+		},
+		{
+			// Los dos puntos de una clave se escapan; los del valor no
+			`codigo\:ine:eq:01:01,ruta:contains:C:\\datos`,
+			[]Condition{
+				{Key: "codigo:ine", Op: "eq", Values: []string{"01:01"}},
+				{Key: "ruta", Op: "contains", Values: []string{`C:\datos`}},
+			},
+		},
+		{
+			`area_km2:gte:100, ,distritos:lt:3`,
+			[]Condition{
+				{Key: "area_km2", Op: "gte", Values: []string{"100"}},
+				{Key: "distritos", Op: "lt", Values: []string{"3"}},
+			},
+		},
+	} {
+		got, err := ParseWhere([]string{tc.raw})
+		if err != nil {
+			t.Errorf("ParseWhere(%q): %v", tc.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParseWhere(%q) = %+v, want %+v", tc.raw, got, tc.want)
+		}
+	}
+}
+
+func TestParseWhereInvalid(t *testing.T) {
+	for _, raw := range []string{
+		`D:eq`,
+		`D\:eq:x`,
+		`:eq:x`,
+		`D:like:x`,
+		`area_km2:gt:mucho`,
+	} {
+		if _, err := ParseWhere([]string{raw}); err == nil {
+			t.Errorf("ParseWhere(%q) accepted an invalid condition", raw)
+		}
+	}
+}
+
+func TestFeatureQueryMatches(t *testing.T) {
+	properties := map[string]interface{}{
+		"codigo:ine": "0101",
+		"NAM":        "Ahuachapán",
+		"distritos":  float64(3),
+	}
+	for raw, want := range map[string]bool{
+		`codigo\:ine:eq:0101`:   true,
+		`NAM:eq:ahuachapan`:     true,
+		`NAM:contains:CHAP`:     true,
+		`distritos:eq:3.0`:      true,
+		`distritos:gt:3`:        false,
+		`NAM:ne:Ahuachapan`:     false,
+		`falta:ne:cualquiera`:   false,
+		`distritos:in:1|2|3`:    true,
+		`NAM:in:Apaneca|Tacuba`: false,
+	} {
+		conditions, err := ParseWhere([]string{raw})
+		if err != nil {
+			t.Fatalf("ParseWhere(%q): %v", raw, err)
+		}
+		query := FeatureQuery{Where: conditions}
+		if got := query.Matches(properties); got != want {
+			t.Errorf("%s: Matches = %v, want %v", raw, got, want)
+		}
+	}
+}
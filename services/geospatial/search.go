@@ -6,6 +6,7 @@ import (
 
 	"chivomap.com/interfaces"
 	"chivomap.com/types"
+	"chivomap.com/utils"
 )
 
 
@@ -19,33 +20,27 @@ func mapToSlice(m map[string]struct{}) []string {
 	return slice
 }
 
-// GetMunicipios filtra las features por el valor exacto en la propiedad especificada ("D", "M" o "NAM").
-func GetMunicipios(staticCache interfaces.StaticCacheService, query, whatIs string) (*types.GeoFeatureCollection, error) {
-	if whatIs != "D" && whatIs != "M" && whatIs != "NAM" {
-		return nil, fmt.Errorf("parámetro whatIs inválido '%s': debe ser 'M', 'D' o 'NAM'", whatIs)
+// GetMunicipios filtra las features del nivel indicado (ver LevelProperties)
+// por el valor exacto en la propiedad especificada ("D", "M" o "NAM").
+// Con tolerance > 0 las geometrías se toman de la versión simplificada del cache.
+func GetMunicipios(staticCache interfaces.StaticCacheService, query, whatIs, nivel string, tolerance float64) (*types.GeoFeatureCollection, error) {
+	if !LevelHasProperty(nivel, whatIs) {
+		return nil, fmt.Errorf("parámetro whatIs inválido '%s' para el nivel %s", whatIs, levelOrDefault(nivel))
 	}
 	
 	// Usar cache estático en lugar de leer desde disco
-	geo, err := staticCache.GetGeoData()
+	geo, err := staticCache.GetLevelGeoData(nivel, tolerance)
 	if err != nil {
 		return nil, fmt.Errorf("error obteniendo datos geoespaciales para filtro %s=%s: %w", whatIs, query, err)
 	}
 	
-	// Convertir query a mayúsculas para búsqueda case-insensitive
-	queryUpper := strings.ToUpper(query)
+	// Normalizar query para búsqueda sin mayúsculas ni tildes
+	normalizedQuery := utils.NormalizeText(query)
 	
 	// Preallocar slice para mejor performance
 	filteredFeatures := make([]types.GeoFeature, 0, len(geo.Features)/10) // Estimación conservadora
 	for _, feat := range geo.Features {
-		if feat.Properties == nil {
-			continue
-		}
-		propVal, ok := feat.Properties[whatIs].(string)
-		if !ok {
-			continue
-		}
-		// Búsqueda case-insensitive y parcial
-		if strings.Contains(strings.ToUpper(propVal), queryUpper) {
+		if matchesProperty(feat.Properties, whatIs, normalizedQuery) {
 			filteredFeatures = append(filteredFeatures, feat)
 		}
 	}
@@ -55,6 +50,20 @@ func GetMunicipios(staticCache interfaces.StaticCacheService, query, whatIs stri
 	}, nil
 }
 
+// matchesProperty indica si la propiedad whatIs contiene la consulta ya
+// normalizada con utils.NormalizeText (búsqueda parcial, sin importar
+// mayúsculas ni tildes). Se comparte entre la salida GeoJSON y TopoJSON.
+func matchesProperty(properties map[string]interface{}, whatIs, normalizedQuery string) bool {
+	if properties == nil {
+		return false
+	}
+	propVal, ok := properties[whatIs].(string)
+	if !ok {
+		return false
+	}
+	return strings.Contains(utils.NormalizeText(propVal), normalizedQuery)
+}
+
 // GetGeoData extrae nombres únicos de departamentos, municipios y distritos a partir del TopoJSON.
 func GetGeoData(staticCache interfaces.StaticCacheService) (*types.GeoData, error) {
 	// Usar cache estático en lugar de leer desde disco
@@ -0,0 +1,321 @@
+package geospatial
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"chivomap.com/interfaces"
+	"chivomap.com/spatial"
+	"chivomap.com/types"
+	"chivomap.com/utils"
+)
+
+// FilterTopoJSON filtra las geometrías del TopoJSON igual que GetMunicipios, pero
+// retorna una topología con solo los arcs que usan las geometrías encontradas,
+// re-indexados y cuantizados con el Transform original. Con tolerance > 0 (en
+// grados) los arcs se simplifican antes de incluirse. Los departamentos y
+// municipios usan los mismos arcs que sus distritos, sin los interiores.
+func FilterTopoJSON(staticCache interfaces.StaticCacheService, query, whatIs, nivel string, tolerance float64) (*types.TopoJSON, error) {
+	if !LevelHasProperty(nivel, whatIs) {
+		return nil, fmt.Errorf("parámetro whatIs inválido '%s' para el nivel %s", whatIs, levelOrDefault(nivel))
+	}
+
+	// Los arcs, las geometrías y los IDs deben ser de la misma versión del dataset
+	snapshot, err := staticCache.Snapshot()
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo TopoJSON para filtro %s=%s: %w", whatIs, query, err)
+	}
+	topo := snapshot.TopoJSON()
+	collection, err := snapshot.LevelObject(nivel)
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo geometrías de nivel %s: %w", levelOrDefault(nivel), err)
+	}
+
+	// Los features de LevelGeoData siguen el orden de las geometrías y traen sus IDs
+	geo, err := snapshot.LevelGeoData(nivel, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo IDs de features para filtro %s=%s: %w", whatIs, query, err)
+	}
+
+	normalizedQuery := utils.NormalizeText(query)
+
+	var (
+		arcs       [][][]float64
+		simplified = make(map[int][][]float64) // arcs simplificados por índice original
+		newIndex   = make(map[int]int)         // índice original -> índice en la salida
+		fullIndex  = make(map[int]int)         // igual, para los arcs sin simplificar
+		arcAt      = func(index int) [][]float64 {
+			arc, ok := simplified[index]
+			if !ok {
+				arc = simplifyQuantizedArc(topo.Arcs[index], topo.Transform, tolerance)
+				simplified[index] = arc
+			}
+			return arc
+		}
+		remapWith = func(full bool) func(int) (int, error) {
+			return func(arc int) (int, error) {
+				index := arc
+				if arc < 0 {
+					index = ^arc
+				}
+				if index >= len(topo.Arcs) {
+					return 0, fmt.Errorf("arc %d fuera de rango (%d arcs)", index, len(topo.Arcs))
+				}
+
+				indexes := newIndex
+				if full {
+					indexes = fullIndex
+				}
+				mapped, ok := indexes[index]
+				if !ok {
+					mapped = len(arcs)
+					indexes[index] = mapped
+					if full {
+						arcs = append(arcs, topo.Arcs[index])
+					} else {
+						arcs = append(arcs, arcAt(index))
+					}
+				}
+				if arc < 0 {
+					return ^mapped, nil
+				}
+				return mapped, nil
+			}
+		}
+		remapping = remapWith(false)
+		// Un anillo que colapsa al simplificar sus arcs por separado usa los
+		// arcs originales, como en la simplificación de GeoJSON
+		collapsed = func(ring []int) bool {
+			if tolerance <= 0 {
+				return false
+			}
+			return ringCollapsed(ring, len(topo.Arcs), arcAt, len(topo.Transform.Scale) >= 2)
+		}
+	)
+
+	geometries := make([]types.Geometry, 0)
+	for i, geom := range collection.Geometries {
+		if !matchesProperty(geom.Properties, whatIs, normalizedQuery) {
+			continue
+		}
+		if i < len(geo.Features) {
+			// Las geometrías inválidas (ver /geo/validation) se excluyen
+			if geo.Features[i].Geometry == nil {
+				continue
+			}
+			geom.ID = geo.Features[i].ID
+		}
+
+		if len(geom.Arcs) > 0 && string(geom.Arcs) != "null" {
+			var remapped json.RawMessage
+			switch geom.Type {
+			case "Polygon", "MultiPolygon":
+				remapped, err = remapPolygonArcs(geom, remapping, remapWith(true), collapsed)
+			default:
+				remapped, err = remapArcs(geom.Arcs, remapping)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error re-indexando arcs de la geometría %v: %w", geom.Properties[whatIs], err)
+			}
+			geom.Arcs = remapped
+		}
+		geometries = append(geometries, geom)
+	}
+
+	return &types.TopoJSON{
+		Type: "Topology",
+		Objects: map[string]types.TopoObject{
+			"collection": {Type: collection.Type, Geometries: geometries},
+		},
+		Arcs:      arcs,
+		Transform: topo.Transform,
+	}, nil
+}
+
+// remapArcs reemplaza cada índice de arc del campo "arcs" de una geometría,
+// sin importar su anidamiento (LineString, Polygon, MultiPolygon, etc.)
+func remapArcs(raw json.RawMessage, remap func(int) (int, error)) (json.RawMessage, error) {
+	var arcs any
+	if err := json.Unmarshal(raw, &arcs); err != nil {
+		return nil, err
+	}
+
+	var walk func(v any) (any, error)
+	walk = func(v any) (any, error) {
+		switch t := v.(type) {
+		case float64:
+			mapped, err := remap(int(t))
+			return mapped, err
+		case []interface{}:
+			out := make([]interface{}, len(t))
+			for i, child := range t {
+				mapped, err := walk(child)
+				if err != nil {
+					return nil, err
+				}
+				out[i] = mapped
+			}
+			return out, nil
+		}
+		return nil, fmt.Errorf("valor inesperado en arcs: %v", v)
+	}
+
+	remapped, err := walk(arcs)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(remapped)
+}
+
+// remapPolygonArcs re-indexa los anillos de un Polygon o MultiPolygon. Los
+// anillos para los que collapsed es true se re-indexan con fullRemap.
+func remapPolygonArcs(geom types.Geometry, remap, fullRemap func(int) (int, error), collapsed func([]int) bool) (json.RawMessage, error) {
+	remapRing := func(ring []int) ([]int, error) {
+		fn := remap
+		if collapsed(ring) {
+			fn = fullRemap
+		}
+		out := make([]int, len(ring))
+		for i, arc := range ring {
+			mapped, err := fn(arc)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = mapped
+		}
+		return out, nil
+	}
+	remapPolygon := func(rings [][]int) ([][]int, error) {
+		out := make([][]int, len(rings))
+		for i, ring := range rings {
+			mapped, err := remapRing(ring)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = mapped
+		}
+		return out, nil
+	}
+
+	if geom.Type == "Polygon" {
+		var rings [][]int
+		if err := json.Unmarshal(geom.Arcs, &rings); err != nil {
+			return nil, err
+		}
+		mapped, err := remapPolygon(rings)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(mapped)
+	}
+
+	var polygons [][][]int
+	if err := json.Unmarshal(geom.Arcs, &polygons); err != nil {
+		return nil, err
+	}
+	mapped := make([][][]int, len(polygons))
+	for i, rings := range polygons {
+		var err error
+		if mapped[i], err = remapPolygon(rings); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(mapped)
+}
+
+// ringCollapsed indica si el anillo formado por los arcs (obtenidos con arcAt)
+// tiene menos de 4 posiciones o área nula. Las posiciones se comparan en la
+// cuadrícula de los arcs cuantizados, sin aplicar el Transform.
+func ringCollapsed(ring []int, arcCount int, arcAt func(int) [][]float64, quantized bool) bool {
+	var coords [][]float64
+	for _, arc := range ring {
+		index := arc
+		if arc < 0 {
+			index = ^arc
+		}
+		if index >= arcCount {
+			return false // remapArcs reporta el error
+		}
+
+		encoded := arcAt(index)
+		positions := make([][]float64, 0, len(encoded))
+		var x, y float64
+		for _, pos := range encoded {
+			if len(pos) < 2 {
+				continue
+			}
+			if quantized {
+				x, y = x+pos[0], y+pos[1]
+			} else {
+				x, y = pos[0], pos[1]
+			}
+			positions = append(positions, []float64{x, y})
+		}
+
+		for i := range positions {
+			point := positions[i]
+			if arc < 0 {
+				point = positions[len(positions)-1-i]
+			}
+			// Los arcs consecutivos comparten el punto de unión
+			if n := len(coords); n > 0 && coords[n-1][0] == point[0] && coords[n-1][1] == point[1] {
+				continue
+			}
+			coords = append(coords, point)
+		}
+	}
+	return len(coords) < 4 || spatial.RingSignedArea(coords) == 0
+}
+
+// simplifyQuantizedArc simplifica un arc del TopoJSON conservando su codificación:
+// con Transform, posiciones enteras codificadas como deltas; sin él, coordenadas
+// absolutas. La tolerancia se evalúa en grados.
+func simplifyQuantizedArc(arc [][]float64, transform types.Transform, tolerance float64) [][]float64 {
+	if tolerance <= 0 || len(arc) < 3 {
+		return arc
+	}
+
+	quantized := len(transform.Scale) >= 2 && len(transform.Translate) >= 2
+
+	// Posiciones en grados (para medir) seguidas de las originales (para re-codificar)
+	points := make([][]float64, 0, len(arc))
+	var x, y float64
+	for _, pos := range arc {
+		if len(pos) < 2 {
+			continue
+		}
+		if !quantized {
+			points = append(points, []float64{pos[0], pos[1], pos[0], pos[1]})
+			continue
+		}
+		x += pos[0]
+		y += pos[1]
+		points = append(points, []float64{x * transform.Scale[0], y * transform.Scale[1], x, y})
+	}
+	if len(points) < 3 {
+		return arc
+	}
+
+	var simplified [][]float64
+	first, last := points[0], points[len(points)-1]
+	if first[2] == last[2] && first[3] == last[3] {
+		if simplified = spatial.SimplifyRing(points, tolerance); simplified == nil {
+			return arc
+		}
+	} else {
+		simplified = spatial.SimplifyPath(points, tolerance)
+	}
+
+	out := make([][]float64, 0, len(simplified))
+	var prevX, prevY float64
+	for _, p := range simplified {
+		if !quantized {
+			out = append(out, []float64{p[2], p[3]})
+			continue
+		}
+		out = append(out, []float64{math.Round(p[2] - prevX), math.Round(p[3] - prevY)})
+		prevX, prevY = p[2], p[3]
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+package geospatial
+
+import (
+	"encoding/json"
+	"testing"
+
+	"chivomap.com/types"
+)
+
+// lensTopology es un anillo de dos arcs casi rectos: al simplificar cada arc
+// por separado queda un anillo de ida y vuelta sin área
+func lensTopology() ([][][]float64, types.Transform) {
+	return [][][]float64{
+		{{0, 0}, {5, 1}, {10, 0}},
+		{{10, 0}, {5, -1}, {0, 0}},
+	}, types.Transform{}
+}
+
+func TestRingCollapsedAfterSimplification(t *testing.T) {
+	arcs, transform := lensTopology()
+	ring := []int{0, 1}
+
+	for _, tc := range []struct {
+		tolerance float64
+		want      bool
+	}{
+		{0.5, false},
+		{2, true},
+	} {
+		arcAt := func(index int) [][]float64 {
+			return simplifyQuantizedArc(arcs[index], transform, tc.tolerance)
+		}
+		if got := ringCollapsed(ring, len(arcs), arcAt, false); got != tc.want {
+			t.Errorf("tolerance %g: ringCollapsed = %v, want %v", tc.tolerance, got, tc.want)
+		}
+	}
+}
+
+func TestRingCollapsedQuantized(t *testing.T) {
+	// Los mismos arcs codificados como deltas enteros
+	arcs := [][][]float64{
+		{{0, 0}, {50, 10}, {50, -10}},
+		{{100, 0}, {-50, -10}, {-50, 10}},
+	}
+	transform := types.Transform{Scale: []float64{0.1, 0.1}, Translate: []float64{0, 0}}
+
+	arcAt := func(index int) [][]float64 { return arcs[index] }
+	if ringCollapsed([]int{0, 1}, len(arcs), arcAt, true) {
+		t.Error("unsimplified ring reported as collapsed")
+	}
+
+	simplifiedAt := func(index int) [][]float64 {
+		return simplifyQuantizedArc(arcs[index], transform, 2)
+	}
+	if !ringCollapsed([]int{0, 1}, len(arcs), simplifiedAt, true) {
+		t.Error("simplified ring not reported as collapsed")
+	}
+}
+
+func TestRemapPolygonArcsFallsBackForCollapsedRings(t *testing.T) {
+	geom := types.Geometry{Type: "MultiPolygon", Arcs: json.RawMessage(`[[[0,-2]],[[1,3]]]`)}
+
+	remap := func(arc int) (int, error) { return arc, nil }
+	fullRemap := func(arc int) (int, error) {
+		if arc < 0 {
+			return ^(^arc + 100), nil
+		}
+		return arc + 100, nil
+	}
+	collapsed := func(ring []int) bool { return ring[0] == 1 }
+
+	raw, err := remapPolygonArcs(geom, remap, fullRemap, collapsed)
+	if err != nil {
+		t.Fatalf("remapPolygonArcs: %v", err)
+	}
+	if got, want := string(raw), `[[[0,-2]],[[101,103]]]`; got != want {
+		t.Errorf("arcs = %s, want %s", got, want)
+	}
+}
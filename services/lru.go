@@ -0,0 +1,79 @@
+package services
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRUCache es un caché de capacidad fija que, al llenarse, descarta la entrada
+// usada hace más tiempo. Es seguro para uso concurrente.
+type LRUCache[K comparable, V any] struct {
+	capacity int
+	entries  map[K]*list.Element
+	order    *list.List // del más reciente al más antiguo
+	mu       sync.Mutex
+}
+
+// lruEntry es un elemento de la lista de uso
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// NewLRUCache crea un caché que guarda hasta capacity entradas
+func NewLRUCache[K comparable, V any](capacity int) *LRUCache[K, V] {
+	return &LRUCache[K, V]{
+		capacity: capacity,
+		entries:  make(map[K]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get retorna el valor de una clave y la marca como la más reciente
+func (c *LRUCache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		var empty V
+		return empty, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*lruEntry[K, V]).value, true
+}
+
+// Add guarda o reemplaza el valor de una clave, descartando la entrada menos
+// reciente si se supera la capacidad
+func (c *LRUCache[K, V]) Add(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*lruEntry[K, V]).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
+
+// Len retorna la cantidad de entradas guardadas
+func (c *LRUCache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Clear descarta todas las entradas, por ejemplo cuando cambian los datos de origen
+func (c *LRUCache[K, V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[K]*list.Element)
+	c.order.Init()
+}
@@ -3,25 +3,96 @@ package scraping
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"strings"
 	"time"
+
+	"chivomap.com/spatial"
+	"chivomap.com/types"
 )
 
 // Estructura para almacenar los datos del sismo
 type Sismo struct {
-	Fecha        string  `json:"fecha"`
-	Fases        int     `json:"fases"`
-	Latitud      float64 `json:"latitud"`
-	Longitud     float64 `json:"longitud"`
-	Profundidad  float64 `json:"profundidad"`
-	Magnitud     float64 `json:"magnitud"`
-	Localizacion string  `json:"localizacion"`
-	RMS          float64 `json:"rms"`
-	Estado       string  `json:"estado"`
+	ID           string    `json:"id"`
+	FechaUTC     time.Time `json:"fechaUTC"`
+	Fecha        string    `json:"fecha"`
+	Fases        int       `json:"fases"`
+	Latitud      float64   `json:"latitud"`
+	Longitud     float64   `json:"longitud"`
+	Profundidad  float64   `json:"profundidad"`
+	Magnitud     float64   `json:"magnitud"`
+	Localizacion string    `json:"localizacion"`
+	RMS          float64   `json:"rms"`
+	Estado       string    `json:"estado"`
+	// Ubicacion es nil si los datos geoespaciales no están disponibles
+	Ubicacion *types.Ubicacion `json:"ubicacion"`
+}
+
+// Tolerancias para reconocer la revisión de un evento ya publicado. El SNET
+// corrige el epicentro, la magnitud y a veces la hora de origen de un evento
+// después de publicarlo, sin exponer un identificador propio. La distancia
+// entre epicentros evita fusionar dos eventos reales de un enjambre que
+// ocurren con segundos de diferencia en zonas distintas de la costa.
+const (
+	SameEventWindow     = 10 * time.Second
+	SameEventMagnitude  = 0.5
+	SameEventDistanceKm = 30.0
+)
+
+// EventID deriva el identificador de un evento a partir de su hora de origen
+// (UTC, al segundo) y su epicentro redondeado a dos decimales (~1 km). Solo se
+// usa la primera vez que se ve el evento: las revisiones posteriores se
+// reconocen con SameEvent y conservan el ID original aunque cambien la hora,
+// el epicentro o la magnitud.
+func EventID(origen time.Time, latitud, longitud float64) string {
+	return fmt.Sprintf("%s_%.2f_%.2f",
+		origen.UTC().Format("20060102T150405Z"),
+		math.Round(latitud*100)/100,
+		math.Round(longitud*100)/100)
+}
+
+// SameEvent indica si dos sismos son el mismo evento, posiblemente revisado:
+// horas de origen a menos de SameEventWindow, magnitudes a menos de
+// SameEventMagnitude y epicentros a menos de SameEventDistanceKm
+func SameEvent(a, b Sismo) bool {
+	if a.FechaUTC.IsZero() || b.FechaUTC.IsZero() {
+		return false
+	}
+	delta := a.FechaUTC.Sub(b.FechaUTC)
+	if delta < 0 {
+		delta = -delta
+	}
+	return delta <= SameEventWindow &&
+		math.Abs(a.Magnitud-b.Magnitud) <= SameEventMagnitude &&
+		spatial.HaversineKm(a.Longitud, a.Latitud, b.Longitud, b.Latitud) <= SameEventDistanceKm
+}
+
+// toSismo convierte un evento del hub SignalR al formato expuesto por la API
+func toSismo(evt eventoSignalR) Sismo {
+	sismo := Sismo{
+		Fases:        evt.Fases,
+		Latitud:      evt.Latitud,
+		Longitud:     evt.Longitud,
+		Profundidad:  evt.Profundidad,
+		Magnitud:     evt.M,
+		Localizacion: "Localizado " + evt.Region,
+		RMS:          evt.RMS,
+		Estado:       evt.Estado,
+	}
+
+	t, err := time.Parse(time.RFC3339, evt.GMTOT+"Z")
+	sismo.Fecha = t.Format("2/1/2006, 3:04:05 p. m.")
+	// Sin hora de origen no es posible derivar un ID estable
+	if err == nil {
+		sismo.ID = EventID(t, evt.Latitud, evt.Longitud)
+		sismo.FechaUTC = t.UTC()
+	}
+	return sismo
 }
 
 type eventoSignalR struct {
@@ -44,51 +115,156 @@ type negotiateResponse struct {
 	} `json:"availableTransports"`
 }
 
+// Mensajes SignalR enviados al hub
+const (
+	sendEventoMessage = `{"arguments":[],"target":"SendEvento","type":1}`
+	pingMessage       = `{"type":6}`
+)
+
 type signalRMessage struct {
 	Type      int               `json:"type"`
 	Target    string            `json:"target"`
 	Arguments []json.RawMessage `json:"arguments"`
 }
 
-func ScrapeSismos() ([]Sismo, error) {
-	client := &http.Client{Timeout: 30 * time.Second}
+// hubURL es el endpoint del hub SignalR de sismos del SNET
+const hubURL = "https://srt.snet.gob.sv/rtsismos/seiscomphub"
 
-	// 1. Negociar conexión
-	negResp, err := client.Post("https://srt.snet.gob.sv/rtsismos/seiscomphub/negotiate", "application/json", nil)
+// recordSeparator delimita los mensajes del protocolo JSON de SignalR
+const recordSeparator = "\x1E"
+
+// negotiateConnection negocia una conexión con el hub y retorna su ID
+func negotiateConnection(ctx context.Context, client *http.Client) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hubURL+"/negotiate", nil)
 	if err != nil {
-		return nil, fmt.Errorf("error negociando: %w", err)
+		return "", fmt.Errorf("error creando negociación: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	negResp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error negociando: %w", err)
 	}
 	defer negResp.Body.Close()
 
 	var negotiate negotiateResponse
 	if err := json.NewDecoder(negResp.Body).Decode(&negotiate); err != nil {
-		return nil, fmt.Errorf("error decodificando negociación: %w", err)
+		return "", fmt.Errorf("error decodificando negociación: %w", err)
+	}
+	if negotiate.ConnectionID == "" {
+		return "", fmt.Errorf("negociación sin connectionId")
 	}
 
-	connID := negotiate.ConnectionID
+	return negotiate.ConnectionID, nil
+}
 
-	// 2. Conectar a SSE
-	sseURL := fmt.Sprintf("https://srt.snet.gob.sv/rtsismos/seiscomphub?id=%s", connID)
-	req, _ := http.NewRequest("GET", sseURL, nil)
+// openEventStream abre el transporte SSE de una conexión negociada
+func openEventStream(ctx context.Context, client *http.Client, connID string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hubURL+"?id="+connID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creando conexión SSE: %w", err)
+	}
 	req.Header.Set("Accept", "text/event-stream")
 	req.Header.Set("Cache-Control", "no-cache")
 
-	sseResp, err := client.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error conectando SSE: %w", err)
 	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("conexión SSE rechazada: %s", resp.Status)
+	}
+
+	return resp, nil
+}
+
+// sendHubMessage envía un mensaje SignalR (invocación o ping) al hub
+func sendHubMessage(ctx context.Context, client *http.Client, connID, message string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hubURL+"?id="+connID,
+		bytes.NewBufferString(message+recordSeparator))
+	if err != nil {
+		return fmt.Errorf("error creando mensaje: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain;charset=UTF-8")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error enviando mensaje: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("mensaje rechazado por el hub: %s", resp.Status)
+	}
+	return nil
+}
+
+// parseSSELine extrae los mensajes SignalR de una línea "data:" del stream
+func parseSSELine(line string) []signalRMessage {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "data:") {
+		return nil
+	}
+
+	var messages []signalRMessage
+	data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+	for _, frame := range strings.Split(data, recordSeparator) {
+		if frame == "" || frame == "{}" {
+			continue
+		}
+		var msg signalRMessage
+		if err := json.Unmarshal([]byte(frame), &msg); err != nil {
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// decodeEventSignal convierte un mensaje EventSignal en sismos
+func decodeEventSignal(msg signalRMessage) ([]Sismo, bool) {
+	if msg.Target != "EventSignal" || len(msg.Arguments) == 0 {
+		return nil, false
+	}
+
+	var eventos []eventoSignalR
+	if err := json.Unmarshal(msg.Arguments[0], &eventos); err != nil {
+		return nil, false
+	}
+
+	result := make([]Sismo, 0, len(eventos))
+	for _, evt := range eventos {
+		result = append(result, toSismo(evt))
+	}
+	return result, true
+}
+
+// ScrapeSismos abre una conexión temporal al hub, solicita los eventos
+// recientes y retorna el primer EventSignal recibido
+func ScrapeSismos() ([]Sismo, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	ctx := context.Background()
+
+	// 1. Negociar conexión
+	connID, err := negotiateConnection(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Conectar a SSE
+	sseResp, err := openEventStream(ctx, client, connID)
+	if err != nil {
+		return nil, err
+	}
 	defer sseResp.Body.Close()
 
 	// 3. Enviar invoke SendEvento en goroutine
 	go func() {
 		time.Sleep(500 * time.Millisecond)
-		invokeURL := fmt.Sprintf("https://srt.snet.gob.sv/rtsismos/seiscomphub?id=%s", connID)
-		payload := `{"arguments":[],"target":"SendEvento","type":1}` + "\x1E"
-		resp, err := http.Post(invokeURL, "text/plain;charset=UTF-8", bytes.NewBufferString(payload))
-		if err != nil {
+		if err := sendHubMessage(ctx, client, connID, sendEventoMessage); err != nil {
 			fmt.Printf("Error invocando: %v\n", err)
 		} else {
-			resp.Body.Close()
 			fmt.Printf("Invoke enviado correctamente\n")
 		}
 	}()
@@ -112,56 +288,13 @@ func ScrapeSismos() ([]Sismo, error) {
 			}
 
 			lineCount++
-			line = strings.TrimSpace(line)
-			
-			if line == "" || line == ":" {
-				continue
-			}
-
-			fmt.Printf("SSE[%d]: %s\n", lineCount, line)
-
-			if !strings.HasPrefix(line, "data: ") {
-				continue
-			}
-
-			data := strings.TrimPrefix(line, "data: ")
-			if data == "" || data == "{}" {
-				continue
-			}
-
-			// Parsear mensaje SignalR
-			var msg signalRMessage
-			if err := json.Unmarshal([]byte(data), &msg); err != nil {
-				fmt.Printf("Error parseando mensaje: %v\n", err)
-				continue
-			}
-
-			fmt.Printf("Mensaje tipo %d, target: %s\n", msg.Type, msg.Target)
-
-			// Buscar EventSignal
-			if msg.Target == "EventSignal" && len(msg.Arguments) > 0 {
-				var eventos []eventoSignalR
-				if err := json.Unmarshal(msg.Arguments[0], &eventos); err != nil {
-					continue
-				}
+			for _, msg := range parseSSELine(line) {
+				fmt.Printf("Mensaje tipo %d, target: %s\n", msg.Type, msg.Target)
 
-				// Convertir a Sismo
-				result := make([]Sismo, 0, len(eventos))
-				for _, evt := range eventos {
-					t, _ := time.Parse(time.RFC3339, evt.GMTOT+"Z")
-					result = append(result, Sismo{
-						Fecha:        t.Format("2/1/2006, 3:04:05 p. m."),
-						Fases:        evt.Fases,
-						Latitud:      evt.Latitud,
-						Longitud:     evt.Longitud,
-						Profundidad:  evt.Profundidad,
-						Magnitud:     evt.M,
-						Localizacion: "Localizado " + evt.Region,
-						RMS:          evt.RMS,
-						Estado:       evt.Estado,
-					})
+				// Buscar EventSignal
+				if result, ok := decodeEventSignal(msg); ok {
+					return result, nil
 				}
-				return result, nil
 			}
 		}
 	}
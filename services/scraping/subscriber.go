@@ -0,0 +1,224 @@
+package scraping
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"chivomap.com/utils"
+)
+
+const (
+	// pingInterval mantiene viva la conexión; SignalR desconecta clientes
+	// silenciosos después de 30 segundos
+	pingInterval = 15 * time.Second
+	// refreshInterval vuelve a solicitar el listado para que la caché de
+	// sismos (TTL de 3 minutos) nunca expire mientras la conexión está activa
+	refreshInterval = 2 * time.Minute
+	// idleTimeout fuerza la reconexión si el hub deja de enviar datos
+	idleTimeout = 90 * time.Second
+	// Límites del backoff exponencial entre reconexiones
+	minBackoff = 1 * time.Second
+	maxBackoff = 2 * time.Minute
+)
+
+// Subscriber mantiene una suscripción persistente al hub seiscomphub del SNET
+// y entrega cada EventSignal a los handlers registrados
+type Subscriber struct {
+	client   *http.Client
+	mu       sync.RWMutex
+	handlers []func([]Sismo)
+
+	connected   bool
+	connectedAt time.Time
+	lastEventAt time.Time
+	reconnects  int
+	lastError   string
+}
+
+// NewSubscriber crea un nuevo suscriptor del hub de sismos
+func NewSubscriber() *Subscriber {
+	return &Subscriber{
+		// Sin timeout global: el stream SSE es de larga duración
+		client: &http.Client{},
+	}
+}
+
+// OnEvents registra una función que recibe cada listado de sismos publicado
+func (s *Subscriber) OnEvents(fn func([]Sismo)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append(s.handlers, fn)
+}
+
+// Start inicia la suscripción en segundo plano hasta que se cancele ctx
+func (s *Subscriber) Start(ctx context.Context) {
+	go s.run(ctx)
+}
+
+// Status retorna el estado actual de la conexión
+func (s *Subscriber) Status() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := map[string]interface{}{
+		"connected":  s.connected,
+		"reconnects": s.reconnects,
+	}
+	if !s.connectedAt.IsZero() {
+		status["connectedAt"] = s.connectedAt
+	}
+	if !s.lastEventAt.IsZero() {
+		status["lastEventAt"] = s.lastEventAt
+	}
+	if s.lastError != "" {
+		status["lastError"] = s.lastError
+	}
+	return status
+}
+
+// run reconecta con backoff exponencial mientras el contexto siga activo
+func (s *Subscriber) run(ctx context.Context) {
+	backoff := minBackoff
+
+	for {
+		start := time.Now()
+		err := s.listen(ctx)
+		s.setDisconnected(err)
+
+		if ctx.Err() != nil {
+			utils.Info("Suscripción a sismos finalizada")
+			return
+		}
+
+		// Una sesión estable reinicia el backoff
+		if time.Since(start) > maxBackoff {
+			backoff = minBackoff
+		}
+
+		wait := backoff + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		utils.Error("Suscripción a sismos interrumpida: %v (reintento en %s)", err, wait.Round(time.Second))
+
+		select {
+		case <-ctx.Done():
+			utils.Info("Suscripción a sismos finalizada")
+			return
+		case <-time.After(wait):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// listen mantiene una sesión con el hub hasta que falle o se cancele ctx
+func (s *Subscriber) listen(parent context.Context) error {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	connID, err := negotiateConnection(ctx, s.client)
+	if err != nil {
+		return err
+	}
+
+	resp, err := openEventStream(ctx, s.client, connID)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	s.setConnected()
+	utils.Info("Suscripción al hub de sismos establecida")
+
+	// Cancelar la sesión si el hub deja de enviar datos
+	idle := time.AfterFunc(idleTimeout, cancel)
+	defer idle.Stop()
+
+	go s.keepAlive(ctx, connID)
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if ctx.Err() != nil && parent.Err() == nil {
+				return fmt.Errorf("sin datos del hub durante %s", idleTimeout)
+			}
+			return fmt.Errorf("error leyendo stream: %w", err)
+		}
+		idle.Reset(idleTimeout)
+
+		for _, msg := range parseSSELine(line) {
+			if sismos, ok := decodeEventSignal(msg); ok {
+				s.dispatch(sismos)
+			}
+		}
+	}
+}
+
+// keepAlive solicita los eventos, envía pings y refresca el listado periódicamente
+func (s *Subscriber) keepAlive(ctx context.Context, connID string) {
+	if err := sendHubMessage(ctx, s.client, connID, sendEventoMessage); err != nil {
+		utils.Error("Error solicitando sismos al hub: %v", err)
+	}
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+	refresh := time.NewTicker(refreshInterval)
+	defer refresh.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ping.C:
+			if err := sendHubMessage(ctx, s.client, connID, pingMessage); err != nil && ctx.Err() == nil {
+				utils.Error("Error enviando ping al hub: %v", err)
+			}
+		case <-refresh.C:
+			if err := sendHubMessage(ctx, s.client, connID, sendEventoMessage); err != nil && ctx.Err() == nil {
+				utils.Error("Error solicitando sismos al hub: %v", err)
+			}
+		}
+	}
+}
+
+// dispatch entrega los sismos a todos los handlers registrados
+func (s *Subscriber) dispatch(sismos []Sismo) {
+	s.mu.Lock()
+	s.lastEventAt = time.Now()
+	handlers := make([]func([]Sismo), len(s.handlers))
+	copy(handlers, s.handlers)
+	s.mu.Unlock()
+
+	for _, fn := range handlers {
+		fn(sismos)
+	}
+}
+
+// setConnected registra el inicio de una sesión
+func (s *Subscriber) setConnected() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connected = true
+	s.connectedAt = time.Now()
+	s.lastError = ""
+}
+
+// setDisconnected registra el fin de una sesión
+func (s *Subscriber) setDisconnected(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.connected {
+		s.reconnects++
+	}
+	s.connected = false
+	if err != nil {
+		s.lastError = err.Error()
+	}
+}
@@ -0,0 +1,397 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"chivomap.com/interfaces"
+	"chivomap.com/services/scraping"
+	"chivomap.com/spatial"
+	"chivomap.com/types"
+	"chivomap.com/utils"
+)
+
+// upsertSismoSQL inserts an event or refreshes every SNET-provided column of an
+// existing one, keeping its original creation timestamp
+const upsertSismoSQL = `
+INSERT INTO sismos (
+    event_id, fecha_utc, origen_unix, fecha, fases, latitud, longitud,
+    profundidad, magnitud, localizacion, rms, estado, departamento,
+    departamento_norm, municipio, distrito, offshore, distancia_km, creado_en,
+    actualizado_en
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (event_id) DO UPDATE SET
+    fecha_utc = excluded.fecha_utc,
+    origen_unix = excluded.origen_unix,
+    fecha = excluded.fecha,
+    fases = excluded.fases,
+    latitud = excluded.latitud,
+    longitud = excluded.longitud,
+    profundidad = excluded.profundidad,
+    magnitud = excluded.magnitud,
+    localizacion = excluded.localizacion,
+    rms = excluded.rms,
+    estado = excluded.estado,
+    departamento = COALESCE(excluded.departamento, sismos.departamento),
+    departamento_norm = COALESCE(excluded.departamento_norm, sismos.departamento_norm),
+    municipio = COALESCE(excluded.municipio, sismos.municipio),
+    distrito = COALESCE(excluded.distrito, sismos.distrito),
+    offshore = COALESCE(excluded.offshore, sismos.offshore),
+    distancia_km = COALESCE(excluded.distancia_km, sismos.distancia_km),
+    actualizado_en = excluded.actualizado_en;`
+
+// sismoCandidatesSQL lists the stored events whose origin time falls in a
+// range, to be matched against revisions with scraping.SameEvent
+const sismoCandidatesSQL = `
+SELECT event_id, origen_unix, latitud, longitud, magnitud FROM sismos
+WHERE origen_unix BETWEEN ? AND ?;`
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SismoStore persists scraped earthquakes into the sismos table
+type SismoStore struct {
+	db interfaces.DatabaseService
+}
+
+// NewSismoStore creates a new earthquake store
+func NewSismoStore(db interfaces.DatabaseService) *SismoStore {
+	return &SismoStore{db: db}
+}
+
+// ResolveIDs replaces the ID of every event that revises a stored one with the
+// stored event's ID, so a revised origin time, epicentre or magnitude updates
+// the existing row instead of creating a new one. The batch is matched as a
+// whole: each stored event goes to the closest revision in origin time, and
+// events left unmatched get a suffix if their derived ID is already taken, so
+// the IDs of a batch are always unique.
+func (s *SismoStore) ResolveIDs(ctx context.Context, sismos []scraping.Sismo) error {
+	// A single query covers the whole batch: the origin times of a listing
+	// span a few days at most
+	var from, to int64
+	found := false
+	for _, sismo := range sismos {
+		if sismo.ID == "" {
+			continue
+		}
+		origen := sismo.FechaUTC.Unix()
+		if !found || origen < from {
+			from = origen
+		}
+		if !found || origen > to {
+			to = origen
+		}
+		found = true
+	}
+	if !found {
+		return nil
+	}
+	window := int64(scraping.SameEventWindow / time.Second)
+	stored, err := s.candidates(ctx, from-window, to+window)
+	if err != nil {
+		return fmt.Errorf("error matching earthquakes: %w", err)
+	}
+
+	// Every (event, stored event) pair that may be the same event, closest
+	// origin times first
+	type match struct {
+		event int
+		id    string
+		delta time.Duration
+		km    float64
+	}
+	var matches []match
+	for i, sismo := range sismos {
+		if sismo.ID == "" {
+			continue
+		}
+		for id, candidate := range stored {
+			if !scraping.SameEvent(sismo, candidate) {
+				continue
+			}
+			delta := sismo.FechaUTC.Sub(candidate.FechaUTC)
+			if delta < 0 {
+				delta = -delta
+			}
+			km := spatial.HaversineKm(sismo.Longitud, sismo.Latitud, candidate.Longitud, candidate.Latitud)
+			matches = append(matches, match{event: i, id: id, delta: delta, km: km})
+		}
+	}
+	sort.Slice(matches, func(a, b int) bool {
+		if matches[a].delta != matches[b].delta {
+			return matches[a].delta < matches[b].delta
+		}
+		if matches[a].km != matches[b].km {
+			return matches[a].km < matches[b].km
+		}
+		if matches[a].event != matches[b].event {
+			return matches[a].event < matches[b].event
+		}
+		return matches[a].id < matches[b].id
+	})
+
+	resolved := make(map[int]bool, len(sismos))
+	used := make(map[string]bool, len(sismos))
+	for _, m := range matches {
+		if resolved[m.event] || used[m.id] {
+			continue
+		}
+		sismos[m.event].ID = m.id
+		resolved[m.event] = true
+		used[m.id] = true
+	}
+
+	// A derived ID may collide with a stored event matched to another
+	// revision, or with another new event of the batch
+	for i := range sismos {
+		if sismos[i].ID == "" || resolved[i] {
+			continue
+		}
+		base, id := sismos[i].ID, sismos[i].ID
+		for n := 2; ; n++ {
+			if _, taken := stored[id]; !taken && !used[id] {
+				break
+			}
+			id = fmt.Sprintf("%s_%d", base, n)
+		}
+		sismos[i].ID = id
+		used[id] = true
+	}
+	return nil
+}
+
+// candidates returns the stored events with an origin time in [from, to],
+// keyed by ID
+func (s *SismoStore) candidates(ctx context.Context, from, to int64) (map[string]scraping.Sismo, error) {
+	rows, err := s.db.QueryContext(ctx, sismoCandidatesSQL, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := make(map[string]scraping.Sismo)
+	for rows.Next() {
+		var (
+			sismo  scraping.Sismo
+			origen int64
+		)
+		if err := rows.Scan(&sismo.ID, &origen, &sismo.Latitud, &sismo.Longitud, &sismo.Magnitud); err != nil {
+			return nil, err
+		}
+		sismo.FechaUTC = time.Unix(origen, 0).UTC()
+		stored[sismo.ID] = sismo
+	}
+	return stored, rows.Err()
+}
+
+// Upsert stores every event under its ID, returning how many were written.
+// IDs should be resolved with ResolveIDs first so revisions update their row.
+func (s *SismoStore) Upsert(ctx context.Context, sismos []scraping.Sismo) (int, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	written := 0
+
+	for _, sismo := range sismos {
+		// Events without a parseable origin time cannot be identified
+		if sismo.ID == "" {
+			continue
+		}
+
+		// Location columns stay NULL when geospatial data was unavailable
+		var departamento, departamentoNorm, municipio, distrito, offshore, distancia any
+		if u := sismo.Ubicacion; u != nil {
+			departamento, municipio, distrito = u.Departamento, u.Municipio, u.Distrito
+			departamentoNorm = utils.NormalizeText(u.Departamento)
+			offshore, distancia = u.Offshore, u.DistanciaKm
+		}
+
+		_, err := s.db.ExecContext(ctx, upsertSismoSQL,
+			sismo.ID,
+			sismo.FechaUTC.Format(time.RFC3339),
+			sismo.FechaUTC.Unix(),
+			sismo.Fecha,
+			sismo.Fases,
+			sismo.Latitud,
+			sismo.Longitud,
+			sismo.Profundidad,
+			sismo.Magnitud,
+			sismo.Localizacion,
+			sismo.RMS,
+			sismo.Estado,
+			departamento,
+			departamentoNorm,
+			municipio,
+			distrito,
+			offshore,
+			distancia,
+			now,
+			now,
+		)
+		if err != nil {
+			return written, fmt.Errorf("error storing earthquake %s: %w", sismo.ID, err)
+		}
+		written++
+	}
+
+	return written, nil
+}
+
+// SismoQuery holds the optional filters for historical earthquake queries.
+// Nil fields are not applied.
+type SismoQuery struct {
+	From     *time.Time
+	To       *time.Time
+	MinMag   *float64
+	MaxMag   *float64
+	MinDepth *float64
+	MaxDepth *float64
+	// BBox is minLon, minLat, maxLon, maxLat
+	BBox *[4]float64
+	// Departamento matches on-land events by departamento name, ignoring case
+	// and accents
+	Departamento string
+	Cursor       string
+	Limit        int
+}
+
+// SismoPage is one page of historical results, newest first
+type SismoPage struct {
+	Sismos     []scraping.Sismo
+	NextCursor string
+}
+
+// Query returns stored earthquakes matching the filters using keyset pagination
+// on (origen_unix, event_id)
+func (s *SismoStore) Query(ctx context.Context, q SismoQuery) (*SismoPage, error) {
+	conditions := make([]string, 0, 8)
+	args := make([]any, 0, 12)
+
+	if q.From != nil {
+		conditions = append(conditions, "origen_unix >= ?")
+		args = append(args, q.From.Unix())
+	}
+	if q.To != nil {
+		conditions = append(conditions, "origen_unix < ?")
+		args = append(args, q.To.Unix())
+	}
+	if q.MinMag != nil {
+		conditions = append(conditions, "magnitud >= ?")
+		args = append(args, *q.MinMag)
+	}
+	if q.MaxMag != nil {
+		conditions = append(conditions, "magnitud <= ?")
+		args = append(args, *q.MaxMag)
+	}
+	if q.MinDepth != nil {
+		conditions = append(conditions, "profundidad >= ?")
+		args = append(args, *q.MinDepth)
+	}
+	if q.MaxDepth != nil {
+		conditions = append(conditions, "profundidad <= ?")
+		args = append(args, *q.MaxDepth)
+	}
+	if q.BBox != nil {
+		conditions = append(conditions, "longitud BETWEEN ? AND ?", "latitud BETWEEN ? AND ?")
+		args = append(args, q.BBox[0], q.BBox[2], q.BBox[1], q.BBox[3])
+	}
+	if q.Departamento != "" {
+		conditions = append(conditions, "departamento_norm = ? AND offshore = 0")
+		args = append(args, utils.NormalizeText(q.Departamento))
+	}
+	if q.Cursor != "" {
+		origen, eventID, err := decodeSismoCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, "(origen_unix < ? OR (origen_unix = ? AND event_id < ?))")
+		args = append(args, origen, origen, eventID)
+	}
+
+	query := `SELECT event_id, fecha_utc, fecha, fases, latitud, longitud, profundidad,
+        magnitud, localizacion, rms, estado, departamento, municipio, distrito,
+        offshore, distancia_km FROM sismos`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	// Fetch one extra row to know whether there is a next page
+	query += " ORDER BY origen_unix DESC, event_id DESC LIMIT ?"
+	args = append(args, q.Limit+1)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying earthquakes: %w", err)
+	}
+	defer rows.Close()
+
+	sismos := make([]scraping.Sismo, 0, q.Limit+1)
+	for rows.Next() {
+		var (
+			sismo                             scraping.Sismo
+			fechaUTC                          string
+			departamento, municipio, distrito sql.NullString
+			offshore                          sql.NullBool
+			distancia                         sql.NullFloat64
+		)
+		if err := rows.Scan(&sismo.ID, &fechaUTC, &sismo.Fecha, &sismo.Fases, &sismo.Latitud,
+			&sismo.Longitud, &sismo.Profundidad, &sismo.Magnitud, &sismo.Localizacion,
+			&sismo.RMS, &sismo.Estado, &departamento, &municipio, &distrito,
+			&offshore, &distancia); err != nil {
+			return nil, fmt.Errorf("error reading earthquake row: %w", err)
+		}
+		sismo.FechaUTC, _ = time.Parse(time.RFC3339, fechaUTC)
+		if departamento.Valid {
+			sismo.Ubicacion = &types.Ubicacion{
+				Departamento: departamento.String,
+				Municipio:    municipio.String,
+				Distrito:     distrito.String,
+				Offshore:     offshore.Bool,
+				DistanciaKm:  distancia.Float64,
+			}
+		}
+		sismos = append(sismos, sismo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating earthquake rows: %w", err)
+	}
+
+	page := &SismoPage{Sismos: sismos}
+	if len(sismos) > q.Limit {
+		page.Sismos = sismos[:q.Limit]
+		last := page.Sismos[len(page.Sismos)-1]
+		page.NextCursor = encodeSismoCursor(last.FechaUTC.Unix(), last.ID)
+	}
+
+	return page, nil
+}
+
+// encodeSismoCursor builds an opaque pagination cursor
+func encodeSismoCursor(origen int64, eventID string) string {
+	raw := strconv.FormatInt(origen, 10) + "|" + eventID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeSismoCursor parses a cursor produced by encodeSismoCursor
+func decodeSismoCursor(cursor string) (int64, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, "", ErrInvalidCursor
+	}
+
+	origen, eventID, ok := strings.Cut(string(raw), "|")
+	if !ok || eventID == "" {
+		return 0, "", ErrInvalidCursor
+	}
+
+	unix, err := strconv.ParseInt(origen, 10, 64)
+	if err != nil {
+		return 0, "", ErrInvalidCursor
+	}
+
+	return unix, eventID, nil
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"chivomap.com/config"
+	"chivomap.com/services/scraping"
+
+	_ "github.com/tursodatabase/go-libsql"
+)
+
+// newTestSismoStore creates a store backed by a fresh database file
+func newTestSismoStore(t *testing.T) (*SismoStore, *sql.DB) {
+	t.Helper()
+
+	db, err := sql.Open("libsql", "file:"+filepath.Join(t.TempDir(), "sismos.db"))
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := config.MigrateSismos(db); err != nil {
+		t.Fatalf("creating schema: %v", err)
+	}
+	return NewSismoStore(db), db
+}
+
+// testSismo builds an event with its derived ID
+func testSismo(origen time.Time, lat, lon, mag float64) scraping.Sismo {
+	return scraping.Sismo{
+		ID:       scraping.EventID(origen, lat, lon),
+		FechaUTC: origen,
+		Fecha:    origen.Format(time.RFC3339),
+		Latitud:  lat,
+		Longitud: lon,
+		Magnitud: mag,
+	}
+}
+
+// resolveAndStore runs ResolveIDs and Upsert on a batch, as the handler does
+func resolveAndStore(t *testing.T, store *SismoStore, batch []scraping.Sismo) {
+	t.Helper()
+
+	ctx := context.Background()
+	if err := store.ResolveIDs(ctx, batch); err != nil {
+		t.Fatalf("ResolveIDs: %v", err)
+	}
+	if _, err := store.Upsert(ctx, batch); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+}
+
+func countSismos(t *testing.T, db *sql.DB) int {
+	t.Helper()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sismos").Scan(&count); err != nil {
+		t.Fatalf("counting rows: %v", err)
+	}
+	return count
+}
+
+func TestResolveIDsSwarm(t *testing.T) {
+	store, db := newTestSismoStore(t)
+	origen := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	a := testSismo(origen, 13.20, -89.40, 3.1)
+	resolveAndStore(t, store, []scraping.Sismo{a})
+
+	// B is a different nearby event that falls within the revision tolerances
+	// of A and comes first in the listing; A must keep its own row
+	b := testSismo(origen.Add(8*time.Second), 13.25, -89.38, 3.4)
+	batch := []scraping.Sismo{b, a}
+	resolveAndStore(t, store, batch)
+
+	if batch[1].ID != a.ID {
+		t.Errorf("stored event got ID %q, want %q", batch[1].ID, a.ID)
+	}
+	if batch[0].ID == batch[1].ID {
+		t.Errorf("both events resolved to ID %q", batch[0].ID)
+	}
+	if got := countSismos(t, db); got != 2 {
+		t.Errorf("stored %d events, want 2", got)
+	}
+}
+
+func TestResolveIDsRevision(t *testing.T) {
+	store, db := newTestSismoStore(t)
+	origen := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	original := testSismo(origen, 13.20, -89.40, 3.1)
+	resolveAndStore(t, store, []scraping.Sismo{original})
+
+	revised := testSismo(origen.Add(3*time.Second), 13.27, -89.31, 3.3)
+	batch := []scraping.Sismo{revised}
+	resolveAndStore(t, store, batch)
+
+	if batch[0].ID != original.ID {
+		t.Errorf("revision got ID %q, want %q", batch[0].ID, original.ID)
+	}
+	if got := countSismos(t, db); got != 1 {
+		t.Errorf("stored %d events, want 1", got)
+	}
+}
+
+func TestResolveIDsDistantEpicentre(t *testing.T) {
+	store, db := newTestSismoStore(t)
+	origen := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	resolveAndStore(t, store, []scraping.Sismo{testSismo(origen, 13.20, -89.40, 3.1)})
+
+	// Same time window and magnitude but ~80 km away: a different event
+	other := testSismo(origen.Add(4*time.Second), 13.10, -88.67, 3.2)
+	batch := []scraping.Sismo{other}
+	resolveAndStore(t, store, batch)
+
+	if batch[0].ID != other.ID {
+		t.Errorf("distant event got ID %q, want %q", batch[0].ID, other.ID)
+	}
+	if got := countSismos(t, db); got != 2 {
+		t.Errorf("stored %d events, want 2", got)
+	}
+}
+
+func TestResolveIDsUniqueDerivedIDs(t *testing.T) {
+	store, db := newTestSismoStore(t)
+	origen := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	// Same second and rounded epicentre but magnitudes too far apart to be
+	// revisions of each other
+	batch := []scraping.Sismo{
+		testSismo(origen, 13.201, -89.401, 2.5),
+		testSismo(origen, 13.199, -89.399, 4.0),
+	}
+	resolveAndStore(t, store, batch)
+
+	if batch[0].ID == batch[1].ID {
+		t.Fatalf("both events resolved to ID %q", batch[0].ID)
+	}
+	if got := countSismos(t, db); got != 2 {
+		t.Errorf("stored %d events, want 2", got)
+	}
+
+	// A later listing with the same events keeps their IDs
+	again := []scraping.Sismo{
+		testSismo(origen, 13.201, -89.401, 2.5),
+		testSismo(origen, 13.199, -89.399, 4.0),
+	}
+	resolveAndStore(t, store, again)
+	for i := range again {
+		if again[i].ID != batch[i].ID {
+			t.Errorf("event %d got ID %q, want %q", i, again[i].ID, batch[i].ID)
+		}
+	}
+	if got := countSismos(t, db); got != 2 {
+		t.Errorf("stored %d events, want 2", got)
+	}
+}
@@ -0,0 +1,188 @@
+package tiles
+
+// Codificación mínima de Mapbox Vector Tiles (especificación 2.1) sobre
+// protobuf, limitada a lo que necesitamos: capas con polígonos y atributos
+// de texto.
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+
+	// Campos del mensaje Tile
+	tileLayers = 3
+
+	// Campos del mensaje Layer
+	layerName     = 1
+	layerFeatures = 2
+	layerKeys     = 3
+	layerValues   = 4
+	layerExtent   = 5
+	layerVersion  = 15
+
+	// Campos del mensaje Feature
+	featureID       = 1
+	featureTags     = 2
+	featureType     = 3
+	featureGeometry = 4
+
+	// Campos del mensaje Value
+	valueString = 1
+
+	geomTypePolygon = 3
+
+	cmdMoveTo    = 1
+	cmdLineTo    = 2
+	cmdClosePath = 7
+)
+
+// pbuf acumula un mensaje protobuf
+type pbuf []byte
+
+func (b *pbuf) varint(v uint64) {
+	for v >= 0x80 {
+		*b = append(*b, byte(v)|0x80)
+		v >>= 7
+	}
+	*b = append(*b, byte(v))
+}
+
+func (b *pbuf) tag(field, wire int) {
+	b.varint(uint64(field<<3 | wire))
+}
+
+func (b *pbuf) uintField(field int, v uint64) {
+	b.tag(field, wireVarint)
+	b.varint(v)
+}
+
+func (b *pbuf) bytesField(field int, data []byte) {
+	b.tag(field, wireBytes)
+	b.varint(uint64(len(data)))
+	*b = append(*b, data...)
+}
+
+func (b *pbuf) packedField(field int, values []uint32) {
+	var packed pbuf
+	for _, v := range values {
+		packed.varint(uint64(v))
+	}
+	b.bytesField(field, packed)
+}
+
+// layer construye una capa, deduplicando claves y valores de los atributos
+type layer struct {
+	name     string
+	extent   uint32
+	features []pbuf
+	keys     []string
+	keyIndex map[string]uint32
+	values   []string
+	valIndex map[string]uint32
+}
+
+func newLayer(name string, extent uint32) *layer {
+	return &layer{
+		name:     name,
+		extent:   extent,
+		keyIndex: make(map[string]uint32),
+		valIndex: make(map[string]uint32),
+	}
+}
+
+// addPolygon agrega un feature de tipo polígono. geometry son los comandos ya
+// codificados por encodePolygons.
+func (l *layer) addPolygon(id uint64, attrs [][2]string, geometry []uint32) {
+	tags := make([]uint32, 0, len(attrs)*2)
+	for _, kv := range attrs {
+		tags = append(tags, l.key(kv[0]), l.value(kv[1]))
+	}
+
+	var f pbuf
+	f.uintField(featureID, id)
+	f.packedField(featureTags, tags)
+	f.uintField(featureType, geomTypePolygon)
+	f.packedField(featureGeometry, geometry)
+	l.features = append(l.features, f)
+}
+
+func (l *layer) key(k string) uint32 {
+	if i, ok := l.keyIndex[k]; ok {
+		return i
+	}
+	l.keyIndex[k] = uint32(len(l.keys))
+	l.keys = append(l.keys, k)
+	return l.keyIndex[k]
+}
+
+func (l *layer) value(v string) uint32 {
+	if i, ok := l.valIndex[v]; ok {
+		return i
+	}
+	l.valIndex[v] = uint32(len(l.values))
+	l.values = append(l.values, v)
+	return l.valIndex[v]
+}
+
+// encode serializa la capa como mensaje Layer
+func (l *layer) encode() []byte {
+	var b pbuf
+	b.uintField(layerVersion, 2)
+	b.bytesField(layerName, []byte(l.name))
+	for _, f := range l.features {
+		b.bytesField(layerFeatures, f)
+	}
+	for _, k := range l.keys {
+		b.bytesField(layerKeys, []byte(k))
+	}
+	for _, v := range l.values {
+		var value pbuf
+		value.bytesField(valueString, []byte(v))
+		b.bytesField(layerValues, value)
+	}
+	b.uintField(layerExtent, uint64(l.extent))
+	return b
+}
+
+// encodeTile serializa las capas no vacías como mensaje Tile
+func encodeTile(layers ...*layer) []byte {
+	var b pbuf
+	for _, l := range layers {
+		if len(l.features) > 0 {
+			b.bytesField(tileLayers, l.encode())
+		}
+	}
+	return b
+}
+
+// encodePolygons codifica polígonos con coordenadas enteras de tile como
+// comandos MoveTo/LineTo/ClosePath. Cada anillo llega sin la posición de cierre
+// y con la orientación requerida por la especificación.
+func encodePolygons(polygons [][][][2]int32) []uint32 {
+	var (
+		cmds   []uint32
+		cx, cy int32 // el cursor persiste entre anillos
+	)
+	for _, polygon := range polygons {
+		for _, ring := range polygon {
+			cmds = append(cmds, command(cmdMoveTo, 1),
+				zigzag(ring[0][0]-cx), zigzag(ring[0][1]-cy))
+			cx, cy = ring[0][0], ring[0][1]
+
+			cmds = append(cmds, command(cmdLineTo, len(ring)-1))
+			for _, p := range ring[1:] {
+				cmds = append(cmds, zigzag(p[0]-cx), zigzag(p[1]-cy))
+				cx, cy = p[0], p[1]
+			}
+			cmds = append(cmds, command(cmdClosePath, 1))
+		}
+	}
+	return cmds
+}
+
+func command(id, count int) uint32 {
+	return uint32(id&0x7) | uint32(count)<<3
+}
+
+func zigzag(v int32) uint32 {
+	return uint32((v << 1) ^ (v >> 31))
+}
@@ -0,0 +1,205 @@
+// Package tiles genera Mapbox Vector Tiles (MVT) a partir de los límites
+// administrativos cargados por el cache estático.
+package tiles
+
+import (
+	"fmt"
+	"math"
+
+	"chivomap.com/interfaces"
+	"chivomap.com/spatial"
+	"chivomap.com/types"
+)
+
+const (
+	// Extent es la resolución de las coordenadas dentro de cada tile
+	Extent = 4096
+	// MaxZoom es el zoom máximo aceptado
+	MaxZoom = 22
+	// LayerName es el nombre de la capa con los distritos
+	LayerName = "distritos"
+
+	// buffer agrega margen alrededor del tile (en unidades de Extent) para
+	// que los bordes no se noten al unir tiles vecinos
+	buffer = 64
+	// simplifyZoomOffset fija la tolerancia de simplificación: la de
+	// spatial.ToleranceForZoom dos zooms más arriba, 1/4 de pixel en un tile
+	// de 256 px (4 unidades de Extent)
+	simplifyZoomOffset = 2
+
+	maxMercatorLat = 85.0511287798066
+)
+
+// Attributes son las propiedades de cada feature que se copian al tile
+var Attributes = []string{"D", "M", "NAM"}
+
+// Valid indica si z/x/y corresponde a un tile existente
+func Valid(z, x, y int) bool {
+	if z < 0 || z > MaxZoom {
+		return false
+	}
+	n := 1 << z
+	return x >= 0 && x < n && y >= 0 && y < n
+}
+
+// Bounds retorna el rectángulo lon/lat cubierto por el tile
+func Bounds(z, x, y int) spatial.BBox {
+	n := float64(int(1) << z)
+	lon := func(tx float64) float64 { return tx/n*360 - 180 }
+	lat := func(ty float64) float64 { return math.Atan(math.Sinh(math.Pi*(1-2*ty/n))) * 180 / math.Pi }
+	return spatial.BBox{lon(float64(x)), lat(float64(y + 1)), lon(float64(x + 1)), lat(float64(y))}
+}
+
+// Generate construye el tile z/x/y codificado como MVT. Un tile sin features
+// retorna un slice vacío.
+func Generate(staticCache interfaces.StaticCacheService, z, x, y int) ([]byte, error) {
+	if !Valid(z, x, y) {
+		return nil, fmt.Errorf("tile inválido %d/%d/%d", z, x, y)
+	}
+
+	snapshot, err := staticCache.Snapshot()
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo datos geoespaciales para tile %d/%d/%d: %w", z, x, y, err)
+	}
+	index, err := snapshot.LevelIndex(types.NivelDistrito)
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo índice espacial para tile %d/%d/%d: %w", z, x, y, err)
+	}
+	// Los arcs compartidos se simplifican una sola vez para todo el nivel, antes
+	// de recortar, así los distritos vecinos conservan el mismo borde
+	simplified, err := snapshot.LevelGeoData(types.NivelDistrito, Tolerance(z))
+	if err != nil {
+		return nil, fmt.Errorf("error simplificando geometrías para tile %d/%d/%d: %w", z, x, y, err)
+	}
+
+	// Buscar con el mismo margen que se usa al recortar
+	query := Bounds(z, x, y)
+	marginLon := (query[2] - query[0]) * buffer / Extent
+	marginLat := (query[3] - query[1]) * buffer / Extent
+	query = spatial.BBox{query[0] - marginLon, query[1] - marginLat, query[2] + marginLon, query[3] + marginLat}
+
+	proj := newProjection(z, x, y)
+	l := newLayer(LayerName, Extent)
+	for _, id := range index.SearchBBox(query) {
+		if id >= len(simplified.Features) {
+			continue
+		}
+		feature := &simplified.Features[id]
+		polygons := proj.polygons(spatial.Polygons(feature.Geometry))
+		if len(polygons) == 0 {
+			continue
+		}
+
+		attrs := make([][2]string, 0, len(Attributes))
+		for _, key := range Attributes {
+			if value, ok := feature.Properties[key].(string); ok {
+				attrs = append(attrs, [2]string{key, value})
+			}
+		}
+		// Los IDs de MVT son opcionales pero deben ser distintos de cero
+		l.addPolygon(uint64(id)+1, attrs, encodePolygons(polygons))
+	}
+
+	return encodeTile(l), nil
+}
+
+// Tolerance retorna la tolerancia, en grados, con que se simplifican los arcs
+// de los tiles de un zoom
+func Tolerance(z int) float64 {
+	return spatial.ToleranceForZoom(min(z+simplifyZoomOffset, MaxZoom))
+}
+
+// projection convierte lon/lat a coordenadas de un tile en Web Mercator
+type projection struct {
+	scale  float64
+	x, y   float64
+	bounds spatial.BBox
+}
+
+func newProjection(z, x, y int) projection {
+	return projection{
+		scale:  float64(int(1) << z),
+		x:      float64(x),
+		y:      float64(y),
+		bounds: spatial.BBox{-buffer, -buffer, Extent + buffer, Extent + buffer},
+	}
+}
+
+func (p projection) point(lon, lat float64) []float64 {
+	lat = math.Max(-maxMercatorLat, math.Min(maxMercatorLat, lat)) * math.Pi / 180
+	mx := (lon + 180) / 360 * p.scale
+	my := (1 - math.Asinh(math.Tan(lat))/math.Pi) / 2 * p.scale
+	return []float64{(mx - p.x) * Extent, (my - p.y) * Extent}
+}
+
+// polygons proyecta, recorta y redondea un multipolígono ya simplificado. Los
+// anillos resultantes no repiten la posición de cierre y están orientados
+// según la especificación: exteriores con área positiva y huecos con área
+// negativa (en coordenadas de tile, con y hacia abajo).
+func (p projection) polygons(polys [][][][]float64) [][][][2]int32 {
+	var result [][][][2]int32
+	for _, polygon := range polys {
+		var rings [][][2]int32
+		for i, ring := range polygon {
+			projected := make([][]float64, 0, len(ring))
+			for _, pos := range ring {
+				if len(pos) >= 2 {
+					projected = append(projected, p.point(pos[0], pos[1]))
+				}
+			}
+
+			tileRing := roundRing(spatial.ClipRing(projected, p.bounds))
+			area := ringArea(tileRing)
+			if area == 0 {
+				if i == 0 {
+					break // sin exterior el polígono no es visible
+				}
+				continue
+			}
+			if (i == 0) != (area > 0) {
+				reverse(tileRing)
+			}
+			rings = append(rings, tileRing)
+		}
+		if len(rings) > 0 {
+			result = append(result, rings)
+		}
+	}
+	return result
+}
+
+// roundRing redondea un anillo cerrado a enteros, elimina posiciones repetidas
+// y la posición de cierre
+func roundRing(ring [][]float64) [][2]int32 {
+	out := make([][2]int32, 0, len(ring))
+	for _, pos := range ring {
+		q := [2]int32{int32(math.Round(pos[0])), int32(math.Round(pos[1]))}
+		if len(out) > 0 && out[len(out)-1] == q {
+			continue
+		}
+		out = append(out, q)
+	}
+	if len(out) > 1 && out[0] == out[len(out)-1] {
+		out = out[:len(out)-1]
+	}
+	if len(out) < 3 {
+		return nil
+	}
+	return out
+}
+
+// ringArea retorna el doble del área con signo (fórmula del agrimensor)
+func ringArea(ring [][2]int32) int64 {
+	var sum int64
+	for i := range ring {
+		a, b := ring[i], ring[(i+1)%len(ring)]
+		sum += int64(a[0])*int64(b[1]) - int64(b[0])*int64(a[1])
+	}
+	return sum
+}
+
+func reverse(ring [][2]int32) {
+	for i, j := 0, len(ring)-1; i < j; i, j = i+1, j-1 {
+		ring[i], ring[j] = ring[j], ring[i]
+	}
+}
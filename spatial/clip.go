@@ -0,0 +1,59 @@
+package spatial
+
+// ClipRing recorta un anillo cerrado al rectángulo con Sutherland-Hodgman. El
+// resultado es un anillo cerrado que puede incluir tramos sobre los bordes del
+// rectángulo; retorna nil si el anillo queda completamente fuera.
+func ClipRing(ring [][]float64, b BBox) [][]float64 {
+	if len(ring) < 4 {
+		return nil
+	}
+
+	// Trabajar sin la posición de cierre
+	points := ring[:len(ring)-1]
+	edges := []struct {
+		inside func(p []float64) bool
+		cross  func(a, c []float64) []float64
+	}{
+		{func(p []float64) bool { return p[0] >= b[0] }, func(a, c []float64) []float64 { return intersectX(a, c, b[0]) }},
+		{func(p []float64) bool { return p[0] <= b[2] }, func(a, c []float64) []float64 { return intersectX(a, c, b[2]) }},
+		{func(p []float64) bool { return p[1] >= b[1] }, func(a, c []float64) []float64 { return intersectY(a, c, b[1]) }},
+		{func(p []float64) bool { return p[1] <= b[3] }, func(a, c []float64) []float64 { return intersectY(a, c, b[3]) }},
+	}
+
+	for _, edge := range edges {
+		if len(points) == 0 {
+			return nil
+		}
+		clipped := make([][]float64, 0, len(points)+4)
+		prev := points[len(points)-1]
+		for _, p := range points {
+			switch {
+			case edge.inside(p) && !edge.inside(prev):
+				clipped = append(clipped, edge.cross(prev, p), p)
+			case edge.inside(p):
+				clipped = append(clipped, p)
+			case edge.inside(prev):
+				clipped = append(clipped, edge.cross(prev, p))
+			}
+			prev = p
+		}
+		points = clipped
+	}
+
+	if len(points) < 3 {
+		return nil
+	}
+	return append(points, points[0])
+}
+
+// intersectX retorna el punto del segmento a-c con coordenada x
+func intersectX(a, c []float64, x float64) []float64 {
+	t := (x - a[0]) / (c[0] - a[0])
+	return []float64{x, a[1] + t*(c[1]-a[1])}
+}
+
+// intersectY retorna el punto del segmento a-c con coordenada y
+func intersectY(a, c []float64, y float64) []float64 {
+	t := (y - a[1]) / (c[1] - a[1])
+	return []float64{a[0] + t*(c[0]-a[0]), y}
+}
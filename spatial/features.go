@@ -0,0 +1,120 @@
+package spatial
+
+import (
+	"math"
+	"sort"
+
+	"chivomap.com/types"
+)
+
+// FeatureIndex indexa los polígonos de una colección de features para
+// consultas por punto, rectángulo y cercanía. Los IDs que retorna son las
+// posiciones de los features en la colección indexada.
+type FeatureIndex struct {
+	features []types.GeoFeature
+	polygons [][][][][]float64
+	bounds   []BBox
+	byID     map[string]int
+	tree     *Index
+}
+
+// NewFeatureIndex construye el índice de una colección de features. Los
+// features sin geometría poligonal no se indexan.
+func NewFeatureIndex(collection *types.GeoFeatureCollection) *FeatureIndex {
+	fi := &FeatureIndex{
+		features: collection.Features,
+		polygons: make([][][][][]float64, len(collection.Features)),
+		bounds:   make([]BBox, len(collection.Features)),
+		byID:     make(map[string]int, len(collection.Features)),
+	}
+
+	items := make([]Item, 0, len(collection.Features))
+	for i, feature := range collection.Features {
+		if feature.ID != "" {
+			fi.byID[feature.ID] = i
+		}
+
+		polys := Polygons(feature.Geometry)
+		fi.polygons[i] = polys
+		fi.bounds[i] = PolygonsBounds(polys)
+		if !fi.bounds[i].IsEmpty() {
+			items = append(items, Item{BBox: fi.bounds[i], ID: i})
+		}
+	}
+	fi.tree = NewIndex(items)
+	return fi
+}
+
+// Len retorna la cantidad de features de la colección
+func (fi *FeatureIndex) Len() int {
+	return len(fi.features)
+}
+
+// Feature retorna el feature con el ID indicado
+func (fi *FeatureIndex) Feature(id int) *types.GeoFeature {
+	return &fi.features[id]
+}
+
+// ByID retorna el ID en el índice del feature con el ID de GeoJSON indicado
+func (fi *FeatureIndex) ByID(featureID string) (int, bool) {
+	id, ok := fi.byID[featureID]
+	return id, ok
+}
+
+// Polygons retorna los polígonos ya extraídos del feature con el ID indicado
+func (fi *FeatureIndex) Polygons(id int) [][][][]float64 {
+	return fi.polygons[id]
+}
+
+// Bounds retorna el rectángulo envolvente del feature con el ID indicado
+func (fi *FeatureIndex) Bounds(id int) BBox {
+	return fi.bounds[id]
+}
+
+// Containing retorna los IDs de los features cuyo polígono contiene el punto
+func (fi *FeatureIndex) Containing(lon, lat float64) []int {
+	var ids []int
+	fi.tree.Search(BBox{lon, lat, lon, lat}, func(id int) bool {
+		if PolygonsContain(fi.polygons[id], lon, lat) {
+			ids = append(ids, id)
+		}
+		return true
+	})
+	return ids
+}
+
+// SearchBBox retorna los IDs de los features cuyo rectángulo envolvente
+// intersecta el rectángulo indicado
+func (fi *FeatureIndex) SearchBBox(query BBox) []int {
+	var ids []int
+	fi.tree.Search(query, func(id int) bool {
+		ids = append(ids, id)
+		return true
+	})
+	return ids
+}
+
+// Intersecting retorna, en el orden de la colección, los IDs de los features
+// cuyo polígono toca o se superpone con la forma
+func (fi *FeatureIndex) Intersecting(shape Shape) []int {
+	var ids []int
+	fi.tree.Search(shape.Bounds(), func(id int) bool {
+		if shape.IntersectsPolygons(fi.polygons[id]) {
+			ids = append(ids, id)
+		}
+		return true
+	})
+	sort.Ints(ids)
+	return ids
+}
+
+// Nearest retorna el ID del feature cuyo borde está más cerca del punto y la
+// distancia en km. Retorna -1 si no hay features indexados.
+func (fi *FeatureIndex) Nearest(lon, lat float64) (int, float64) {
+	if fi.tree.Len() == 0 {
+		return -1, math.Inf(1)
+	}
+	return fi.tree.Nearest(lon, lat, func(id int) float64 {
+		return DistanceToPolygonsKm(fi.polygons[id], lon, lat)
+	})
+}
@@ -0,0 +1,344 @@
+// Package spatial contiene primitivas geométricas sobre coordenadas lon/lat
+// (WGS84) usadas por el cache estático y los servicios geoespaciales.
+package spatial
+
+import "math"
+
+// BBox es un rectángulo envolvente: minLon, minLat, maxLon, maxLat
+type BBox [4]float64
+
+// EmptyBBox retorna un rectángulo vacío, listo para expandirse
+func EmptyBBox() BBox {
+	return BBox{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+}
+
+// IsEmpty indica si el rectángulo no contiene ningún punto
+func (b BBox) IsEmpty() bool {
+	return b[0] > b[2] || b[1] > b[3]
+}
+
+// Extend amplía el rectángulo para incluir el punto
+func (b *BBox) Extend(lon, lat float64) {
+	b[0] = math.Min(b[0], lon)
+	b[1] = math.Min(b[1], lat)
+	b[2] = math.Max(b[2], lon)
+	b[3] = math.Max(b[3], lat)
+}
+
+// Union amplía el rectángulo para incluir otro
+func (b *BBox) Union(o BBox) {
+	if o.IsEmpty() {
+		return
+	}
+	b.Extend(o[0], o[1])
+	b.Extend(o[2], o[3])
+}
+
+// ContainsPoint indica si el punto cae dentro del rectángulo (bordes incluidos)
+func (b BBox) ContainsPoint(lon, lat float64) bool {
+	return lon >= b[0] && lon <= b[2] && lat >= b[1] && lat <= b[3]
+}
+
+// Intersects indica si dos rectángulos se tocan o se superponen
+func (b BBox) Intersects(o BBox) bool {
+	return b[0] <= o[2] && o[0] <= b[2] && b[1] <= o[3] && o[1] <= b[3]
+}
+
+// Polygons extrae los polígonos de una geometría GeoJSON Polygon o MultiPolygon
+// como MultiPolygon. Acepta tanto las geometrías generadas por el cache
+// (map con slices tipados) como las decodificadas desde JSON ([]interface{}).
+// Otros tipos de geometría retornan nil.
+func Polygons(geometry any) [][][][]float64 {
+	geomMap, ok := geometry.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	geomType, _ := geomMap["type"].(string)
+	coords := geomMap["coordinates"]
+
+	switch geomType {
+	case "Polygon":
+		if polygon := toRings(coords); len(polygon) > 0 {
+			return [][][][]float64{polygon}
+		}
+	case "MultiPolygon":
+		switch c := coords.(type) {
+		case [][][][]float64:
+			return c
+		case []interface{}:
+			polys := make([][][][]float64, 0, len(c))
+			for _, raw := range c {
+				if polygon := toRings(raw); len(polygon) > 0 {
+					polys = append(polys, polygon)
+				}
+			}
+			return polys
+		}
+	}
+	return nil
+}
+
+// Lines extrae las líneas de una geometría GeoJSON LineString o
+// MultiLineString como MultiLineString. Otros tipos retornan nil.
+func Lines(geometry any) [][][]float64 {
+	geomMap, ok := geometry.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	geomType, _ := geomMap["type"].(string)
+
+	switch geomType {
+	case "LineString":
+		if line := toPositions(geomMap["coordinates"]); len(line) > 0 {
+			return [][][]float64{line}
+		}
+	case "MultiLineString":
+		return toRings(geomMap["coordinates"])
+	}
+	return nil
+}
+
+// Points extrae las posiciones de una geometría GeoJSON Point o MultiPoint.
+// Otros tipos retornan nil.
+func Points(geometry any) [][]float64 {
+	geomMap, ok := geometry.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	geomType, _ := geomMap["type"].(string)
+	coords := geomMap["coordinates"]
+
+	switch geomType {
+	case "Point":
+		if pos := toPosition(coords); pos != nil {
+			return [][]float64{pos}
+		}
+	case "MultiPoint":
+		return toPositions(coords)
+	}
+	return nil
+}
+
+// toPosition convierte una posición tipada o decodificada desde JSON
+func toPosition(coords any) []float64 {
+	switch c := coords.(type) {
+	case []float64:
+		if len(c) >= 2 {
+			return c
+		}
+	case []interface{}:
+		if len(c) >= 2 {
+			lon, okLon := c[0].(float64)
+			lat, okLat := c[1].(float64)
+			if okLon && okLat {
+				return []float64{lon, lat}
+			}
+		}
+	}
+	return nil
+}
+
+// toRings convierte las coordenadas de un polígono a anillos tipados
+func toRings(coords any) [][][]float64 {
+	switch c := coords.(type) {
+	case [][][]float64:
+		return c
+	case []interface{}:
+		rings := make([][][]float64, 0, len(c))
+		for _, raw := range c {
+			if ring := toPositions(raw); len(ring) > 0 {
+				rings = append(rings, ring)
+			}
+		}
+		return rings
+	}
+	return nil
+}
+
+// toPositions convierte una lista de posiciones decodificada desde JSON
+func toPositions(coords any) [][]float64 {
+	switch c := coords.(type) {
+	case [][]float64:
+		return c
+	case []interface{}:
+		positions := make([][]float64, 0, len(c))
+		for _, raw := range c {
+			pair, ok := raw.([]interface{})
+			if !ok || len(pair) < 2 {
+				return nil
+			}
+			lon, okLon := pair[0].(float64)
+			lat, okLat := pair[1].(float64)
+			if !okLon || !okLat {
+				return nil
+			}
+			positions = append(positions, []float64{lon, lat})
+		}
+		return positions
+	}
+	return nil
+}
+
+// PolygonsBounds calcula el rectángulo envolvente de un multipolígono
+func PolygonsBounds(polys [][][][]float64) BBox {
+	bounds := EmptyBBox()
+	for _, polygon := range polys {
+		for _, ring := range polygon {
+			for _, pos := range ring {
+				if len(pos) >= 2 {
+					bounds.Extend(pos[0], pos[1])
+				}
+			}
+		}
+	}
+	return bounds
+}
+
+// GeometryBounds calcula el rectángulo envolvente de cualquier geometría
+// GeoJSON (incluidas GeometryCollection), tanto con slices tipados como
+// decodificada desde JSON. Retorna un rectángulo vacío si no tiene posiciones.
+func GeometryBounds(geometry any) BBox {
+	bounds := EmptyBBox()
+	geomMap, ok := geometry.(map[string]interface{})
+	if !ok {
+		return bounds
+	}
+	if geometries, ok := geomMap["geometries"].([]interface{}); ok {
+		for _, child := range geometries {
+			bounds.Union(GeometryBounds(child))
+		}
+		return bounds
+	}
+	extendPositions(&bounds, geomMap["coordinates"])
+	return bounds
+}
+
+// extendPositions amplía bounds con todas las posiciones de coordenadas con
+// cualquier anidamiento
+func extendPositions(bounds *BBox, coords any) {
+	switch t := coords.(type) {
+	case []float64:
+		if len(t) >= 2 {
+			bounds.Extend(t[0], t[1])
+		}
+	case [][]float64:
+		for _, pos := range t {
+			extendPositions(bounds, pos)
+		}
+	case [][][]float64:
+		for _, ring := range t {
+			extendPositions(bounds, ring)
+		}
+	case [][][][]float64:
+		bounds.Union(PolygonsBounds(t))
+	case []interface{}:
+		if len(t) >= 2 {
+			lon, okLon := t[0].(float64)
+			lat, okLat := t[1].(float64)
+			if okLon && okLat {
+				bounds.Extend(lon, lat)
+				return
+			}
+		}
+		for _, child := range t {
+			extendPositions(bounds, child)
+		}
+	}
+}
+
+// PolygonsContain indica si el punto cae dentro del multipolígono. Los anillos
+// interiores (huecos) se respetan con la regla par-impar.
+func PolygonsContain(polys [][][][]float64, lon, lat float64) bool {
+	for _, polygon := range polys {
+		inside := false
+		for _, ring := range polygon {
+			if ringContains(ring, lon, lat) {
+				inside = !inside
+			}
+		}
+		if inside {
+			return true
+		}
+	}
+	return false
+}
+
+// ringContains aplica ray casting sobre un anillo
+func ringContains(ring [][]float64, lon, lat float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		if len(ring[i]) < 2 || len(ring[j]) < 2 {
+			continue
+		}
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// earthRadiusKm es el radio medio de la Tierra
+const earthRadiusKm = 6371.0088
+
+// DistanceToPolygonsKm retorna la distancia en km desde el punto al borde más
+// cercano del multipolígono. Usa una proyección equirectangular local centrada
+// en el punto, cuyo error es despreciable a la escala de El Salvador.
+func DistanceToPolygonsKm(polys [][][][]float64, lon, lat float64) float64 {
+	kx := earthRadiusKm * math.Pi / 180 * math.Cos(lat*math.Pi/180)
+	ky := earthRadiusKm * math.Pi / 180
+
+	best := math.Inf(1)
+	for _, polygon := range polys {
+		for _, ring := range polygon {
+			for i := 1; i < len(ring); i++ {
+				a, b := ring[i-1], ring[i]
+				if len(a) < 2 || len(b) < 2 {
+					continue
+				}
+				d := segmentDistance(
+					(a[0]-lon)*kx, (a[1]-lat)*ky,
+					(b[0]-lon)*kx, (b[1]-lat)*ky,
+				)
+				best = math.Min(best, d)
+			}
+		}
+	}
+	return best
+}
+
+// segmentDistance retorna la distancia del origen al segmento (ax,ay)-(bx,by)
+func segmentDistance(ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if lengthSq := dx*dx + dy*dy; lengthSq > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/lengthSq))
+	}
+	return math.Hypot(ax+t*dx, ay+t*dy)
+}
+
+// PathLengthKm retorna la longitud geodésica (haversine) de una línea en km
+func PathLengthKm(path [][]float64) float64 {
+	total := 0.0
+	for i := 1; i < len(path); i++ {
+		total += HaversineKm(path[i-1][0], path[i-1][1], path[i][0], path[i][1])
+	}
+	return total
+}
+
+// HaversineKm retorna la distancia de círculo máximo entre dos puntos en km
+func HaversineKm(lon1, lat1, lon2, lat2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLon := (lon2 - lon1) * toRad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Neighbor es un feature adyacente: comparte al menos un arc de borde
+type Neighbor struct {
+	ID       int     // posición del feature vecino en la colección
+	BorderKm float64 // longitud del borde compartido
+}
@@ -0,0 +1,242 @@
+package spatial
+
+import (
+	"container/heap"
+	"math"
+)
+
+// PolygonsAreaKm2 retorna el área geodésica de un multipolígono en km²,
+// restando los huecos. Usa el área de anillos sobre la esfera (fórmula de
+// Chamberlain y Duquette), con error despreciable a la escala de un país.
+func PolygonsAreaKm2(polys [][][][]float64) float64 {
+	total := 0.0
+	for _, polygon := range polys {
+		for i, ring := range polygon {
+			area := math.Abs(ringAreaKm2(ring))
+			if i == 0 {
+				total += area
+			} else {
+				total -= area
+			}
+		}
+	}
+	return math.Max(0, total)
+}
+
+// ringAreaKm2 retorna el área con signo de un anillo sobre la esfera
+func ringAreaKm2(ring [][]float64) float64 {
+	if len(ring) < 3 {
+		return 0
+	}
+	toRad := math.Pi / 180
+	sum := 0.0
+	for i := 0; i < len(ring); i++ {
+		a, b := ring[i], ring[(i+1)%len(ring)]
+		sum += (b[0] - a[0]) * toRad * (2 + math.Sin(a[1]*toRad) + math.Sin(b[1]*toRad))
+	}
+	return sum * earthRadiusKm * earthRadiusKm / 2
+}
+
+// PolygonsPerimeterKm retorna la longitud geodésica de todos los anillos
+// (exteriores y huecos) en km
+func PolygonsPerimeterKm(polys [][][][]float64) float64 {
+	total := 0.0
+	for _, polygon := range polys {
+		for _, ring := range polygon {
+			total += PathLengthKm(ring)
+		}
+	}
+	return total
+}
+
+// localProjection es una proyección equirectangular en km centrada en un
+// punto de referencia, suficiente para geometrías del tamaño de un país
+type localProjection struct {
+	lon0, lat0, kx, ky float64
+}
+
+func newLocalProjection(bounds BBox) localProjection {
+	lat0 := (bounds[1] + bounds[3]) / 2
+	return localProjection{
+		lon0: (bounds[0] + bounds[2]) / 2,
+		lat0: lat0,
+		kx:   earthRadiusKm * math.Pi / 180 * math.Cos(lat0*math.Pi/180),
+		ky:   earthRadiusKm * math.Pi / 180,
+	}
+}
+
+func (p localProjection) forward(lon, lat float64) (float64, float64) {
+	return (lon - p.lon0) * p.kx, (lat - p.lat0) * p.ky
+}
+
+func (p localProjection) inverse(x, y float64) (float64, float64) {
+	return p.lon0 + x/p.kx, p.lat0 + y/p.ky
+}
+
+func (p localProjection) rings(polygon [][][]float64) [][][2]float64 {
+	rings := make([][][2]float64, 0, len(polygon))
+	for _, ring := range polygon {
+		projected := make([][2]float64, 0, len(ring))
+		for _, pos := range ring {
+			if len(pos) >= 2 {
+				x, y := p.forward(pos[0], pos[1])
+				projected = append(projected, [2]float64{x, y})
+			}
+		}
+		rings = append(rings, projected)
+	}
+	return rings
+}
+
+// planarArea retorna el área con signo (fórmula del agrimensor) y el centroide de un anillo
+func planarArea(ring [][2]float64) (area, cx, cy float64) {
+	for i := 0; i < len(ring); i++ {
+		a, b := ring[i], ring[(i+1)%len(ring)]
+		cross := a[0]*b[1] - b[0]*a[1]
+		area += cross
+		cx += (a[0] + b[0]) * cross
+		cy += (a[1] + b[1]) * cross
+	}
+	area /= 2
+	if area == 0 {
+		return 0, 0, 0
+	}
+	return area, cx / (6 * area), cy / (6 * area)
+}
+
+// PolygonsCentroid retorna el centroide (ponderado por área) del multipolígono.
+// Puede caer fuera del polígono; para etiquetas use PoleOfInaccessibility.
+func PolygonsCentroid(polys [][][][]float64) (lon, lat float64) {
+	bounds := PolygonsBounds(polys)
+	if bounds.IsEmpty() {
+		return 0, 0
+	}
+	proj := newLocalProjection(bounds)
+
+	var totalArea, sumX, sumY float64
+	for _, polygon := range polys {
+		for i, ring := range proj.rings(polygon) {
+			area, cx, cy := planarArea(ring)
+			area = math.Abs(area)
+			if i > 0 {
+				area = -area // los huecos restan
+			}
+			totalArea += area
+			sumX += cx * area
+			sumY += cy * area
+		}
+	}
+	if totalArea == 0 {
+		return (bounds[0] + bounds[2]) / 2, (bounds[1] + bounds[3]) / 2
+	}
+	return proj.inverse(sumX/totalArea, sumY/totalArea)
+}
+
+// PoleOfInaccessibility retorna el punto interior más alejado del borde del
+// polígono más grande del multipolígono (algoritmo polylabel). Es el mejor
+// lugar para una etiqueta: siempre cae dentro del polígono.
+func PoleOfInaccessibility(polys [][][][]float64) (lon, lat float64) {
+	largest, largestArea := -1, -1.0
+	for i, polygon := range polys {
+		if area := PolygonsAreaKm2([][][][]float64{polygon}); area > largestArea {
+			largest, largestArea = i, area
+		}
+	}
+	if largest < 0 || len(polys[largest]) == 0 || len(polys[largest][0]) == 0 {
+		return 0, 0
+	}
+
+	bounds := PolygonsBounds(polys[largest : largest+1])
+	proj := newLocalProjection(bounds)
+	rings := proj.rings(polys[largest])
+
+	minX, minY := proj.forward(bounds[0], bounds[1])
+	maxX, maxY := proj.forward(bounds[2], bounds[3])
+	width, height := maxX-minX, maxY-minY
+	cellSize := math.Min(width, height)
+	if cellSize == 0 {
+		return polys[largest][0][0][0], polys[largest][0][0][1]
+	}
+	precision := math.Max(width, height) / 1000
+
+	queue := &cellQueue{}
+	for x := minX; x < maxX; x += cellSize {
+		for y := minY; y < maxY; y += cellSize {
+			heap.Push(queue, newCell(x+cellSize/2, y+cellSize/2, cellSize/2, rings))
+		}
+	}
+
+	// Empezar con el centroide, que suele estar cerca de la solución
+	cx, cy := 0.0, 0.0
+	if area, x, y := planarArea(rings[0]); area != 0 {
+		cx, cy = x, y
+	}
+	best := newCell(cx, cy, 0, rings)
+	if center := newCell(minX+width/2, minY+height/2, 0, rings); center.distance > best.distance {
+		best = center
+	}
+
+	for queue.Len() > 0 {
+		cell := heap.Pop(queue).(polyCell)
+		if cell.distance > best.distance {
+			best = cell
+		}
+		if cell.max-best.distance <= precision {
+			continue
+		}
+		h := cell.half / 2
+		heap.Push(queue, newCell(cell.x-h, cell.y-h, h, rings))
+		heap.Push(queue, newCell(cell.x+h, cell.y-h, h, rings))
+		heap.Push(queue, newCell(cell.x-h, cell.y+h, h, rings))
+		heap.Push(queue, newCell(cell.x+h, cell.y+h, h, rings))
+	}
+
+	return proj.inverse(best.x, best.y)
+}
+
+// polyCell es una celda cuadrada de la búsqueda de polylabel
+type polyCell struct {
+	x, y     float64 // centro
+	half     float64 // mitad del lado
+	distance float64 // distancia con signo del centro al borde
+	max      float64 // distancia máxima posible dentro de la celda
+}
+
+func newCell(x, y, half float64, rings [][][2]float64) polyCell {
+	d := signedDistance(x, y, rings)
+	return polyCell{x: x, y: y, half: half, distance: d, max: d + half*math.Sqrt2}
+}
+
+// signedDistance retorna la distancia del punto al borde del polígono,
+// negativa si el punto está fuera
+func signedDistance(x, y float64, rings [][][2]float64) float64 {
+	inside := false
+	best := math.Inf(1)
+	for _, ring := range rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			a, b := ring[i], ring[j]
+			if (a[1] > y) != (b[1] > y) && x < (b[0]-a[0])*(y-a[1])/(b[1]-a[1])+a[0] {
+				inside = !inside
+			}
+			best = math.Min(best, segmentDistance(a[0]-x, a[1]-y, b[0]-x, b[1]-y))
+		}
+	}
+	if !inside {
+		return -best
+	}
+	return best
+}
+
+// cellQueue es una cola de prioridad por distancia máxima posible
+type cellQueue []polyCell
+
+func (q cellQueue) Len() int            { return len(q) }
+func (q cellQueue) Less(i, j int) bool  { return q[i].max > q[j].max }
+func (q cellQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *cellQueue) Push(x interface{}) { *q = append(*q, x.(polyCell)) }
+func (q *cellQueue) Pop() interface{} {
+	old := *q
+	cell := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return cell
+}
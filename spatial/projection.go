@@ -0,0 +1,260 @@
+package spatial
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CRS es un sistema de referencia de coordenadas de salida. Las geometrías se
+// guardan en WGS84 lon/lat y se proyectan solo al responder.
+type CRS struct {
+	Code    int    // código EPSG
+	Name    string // nombre oficial del sistema
+	WKT     string // definición ESRI, la que usan los archivos .prj
+	project func(lon, lat float64) (x, y float64)
+}
+
+// Geographic indica si el sistema es WGS84 lon/lat (sin proyección)
+func (c *CRS) Geographic() bool {
+	return c.project == nil
+}
+
+// Project convierte una posición lon/lat WGS84 a las coordenadas del sistema
+func (c *CRS) Project(lon, lat float64) (float64, float64) {
+	if c.project == nil {
+		return lon, lat
+	}
+	return c.project(lon, lat)
+}
+
+// CRS84URI es el identificador OGC de WGS84 con orden lon/lat. EPSG:4326
+// declara el orden lat/lon, así que las respuestas en WGS84 usan este.
+const CRS84URI = "http://www.opengis.net/def/crs/OGC/1.3/CRS84"
+
+// URI retorna el identificador OGC del sistema
+// ("http://www.opengis.net/def/crs/EPSG/0/5460", o CRS84URI para WGS84)
+func (c *CRS) URI() string {
+	if c.Geographic() {
+		return CRS84URI
+	}
+	return "http://www.opengis.net/def/crs/EPSG/0/" + strconv.Itoa(c.Code)
+}
+
+// Elipsoides de los sistemas soportados
+const (
+	wgs84InverseFlattening = 298.257223563
+	grs80InverseFlattening = 298.257222101
+	semiMajorAxis          = 6378137.0 // igual en WGS84 y GRS80
+)
+
+// WGS84 es el sistema de los datos: lon/lat en grados (EPSG:4326)
+var WGS84 = &CRS{
+	Code: 4326,
+	Name: "WGS 84",
+	WKT:  `GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137.0,298.257223563]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]]`,
+}
+
+// ElSalvadorLambert es la proyección cónica conforme de Lambert del CNR sobre
+// SIRGAS-ES2007.8 (EPSG:5460). SIRGAS-ES2007.8 coincide con WGS84 a nivel
+// submétrico, por lo que no se aplica transformación de datum.
+var ElSalvadorLambert = &CRS{
+	Code:    5460,
+	Name:    "SIRGAS-ES2007.8 / El Salvador Lambert",
+	WKT:     `PROJCS["SIRGAS-ES2007.8_El_Salvador_Lambert",GEOGCS["GCS_SIRGAS-ES2007.8",DATUM["D_SIRGAS-ES2007.8",SPHEROID["GRS_1980",6378137.0,298.257222101]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]],PROJECTION["Lambert_Conformal_Conic"],PARAMETER["False_Easting",500000.0],PARAMETER["False_Northing",295809.184],PARAMETER["Central_Meridian",-89.0],PARAMETER["Standard_Parallel_1",13.78333333333333],PARAMETER["Scale_Factor",0.99996704],PARAMETER["Latitude_Of_Origin",13.78333333333333],UNIT["Meter",1.0]]`,
+	project: lambertConformalConic(grs80InverseFlattening, 13+47.0/60, -89, 0.99996704, 500000, 295809.184),
+}
+
+// UTM16N es la proyección UTM zona 16 norte sobre WGS84 (EPSG:32616), la zona
+// que cubre todo El Salvador
+var UTM16N = &CRS{
+	Code:    32616,
+	Name:    "WGS 84 / UTM zone 16N",
+	WKT:     `PROJCS["WGS_1984_UTM_Zone_16N",GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137.0,298.257223563]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]],PROJECTION["Transverse_Mercator"],PARAMETER["False_Easting",500000.0],PARAMETER["False_Northing",0.0],PARAMETER["Central_Meridian",-87.0],PARAMETER["Scale_Factor",0.9996],PARAMETER["Latitude_Of_Origin",0.0],UNIT["Meter",1.0]]`,
+	project: transverseMercator(wgs84InverseFlattening, -87, 0.9996, 500000, 0),
+}
+
+// crsByCode son los sistemas soportados por código EPSG
+var crsByCode = map[int]*CRS{
+	WGS84.Code:             WGS84,
+	ElSalvadorLambert.Code: ElSalvadorLambert,
+	UTM16N.Code:            UTM16N,
+}
+
+// ParseCRS interpreta un sistema de referencia como "EPSG:5460", "5460",
+// "urn:ogc:def:crs:EPSG::5460" o su URI OGC. CRS84 (URI, URN u "OGC:CRS84")
+// equivale a WGS84. Retorna false si no es uno de los soportados.
+func ParseCRS(value string) (*CRS, bool) {
+	value = strings.TrimSpace(value)
+	for _, crs84 := range []string{CRS84URI, "urn:ogc:def:crs:OGC:1.3:CRS84", "OGC:CRS84", "CRS84"} {
+		if strings.EqualFold(value, crs84) {
+			return WGS84, true
+		}
+	}
+	for _, prefix := range []string{"http://www.opengis.net/def/crs/EPSG/0/", "urn:ogc:def:crs:EPSG::", "EPSG:"} {
+		if len(value) >= len(prefix) && strings.EqualFold(value[:len(prefix)], prefix) {
+			value = value[len(prefix):]
+			break
+		}
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, false
+	}
+	crs, ok := crsByCode[code]
+	return crs, ok
+}
+
+// lambertConformalConic retorna la proyección cónica conforme de Lambert con un
+// paralelo estándar (EPSG 9801) sobre el elipsoide indicado. Los ángulos están
+// en grados y los falsos este y norte en metros.
+func lambertConformalConic(inverseFlattening, lat0, lon0, k0, falseEasting, falseNorthing float64) func(lon, lat float64) (float64, float64) {
+	e := eccentricity(inverseFlattening)
+	phi0 := lat0 * math.Pi / 180
+
+	// t es la latitud isométrica en la forma de Snyder (15-9)
+	t := func(phi float64) float64 {
+		sin := e * math.Sin(phi)
+		return math.Tan(math.Pi/4-phi/2) / math.Pow((1-sin)/(1+sin), e/2)
+	}
+	m0 := math.Cos(phi0) / math.Sqrt(1-e*e*math.Sin(phi0)*math.Sin(phi0))
+	n := math.Sin(phi0)
+	f := m0 / (n * math.Pow(t(phi0), n))
+	r0 := semiMajorAxis * f * math.Pow(t(phi0), n) * k0
+
+	return func(lon, lat float64) (float64, float64) {
+		r := semiMajorAxis * f * math.Pow(t(lat*math.Pi/180), n) * k0
+		theta := n * (lon - lon0) * math.Pi / 180
+		return falseEasting + r*math.Sin(theta), falseNorthing + r0 - r*math.Cos(theta)
+	}
+}
+
+// transverseMercator retorna la proyección transversa de Mercator con la serie
+// de Krüger hasta cuarto orden, cuyo error es submilimétrico dentro de una
+// zona UTM. Los ángulos están en grados y los falsos este y norte en metros.
+func transverseMercator(inverseFlattening, lon0, k0, falseEasting, falseNorthing float64) func(lon, lat float64) (float64, float64) {
+	e := eccentricity(inverseFlattening)
+	n := 1 / (2*inverseFlattening - 1) // tercer aplanamiento f/(2-f)
+	n2, n3, n4 := n*n, n*n*n, n*n*n*n
+	radius := semiMajorAxis / (1 + n) * (1 + n2/4 + n4/64) // radio rectificante
+	alpha := [4]float64{
+		n/2 - 2*n2/3 + 5*n3/16 + 41*n4/180,
+		13*n2/48 - 3*n3/5 + 557*n4/1440,
+		61*n3/240 - 103*n4/140,
+		49561 * n4 / 161280,
+	}
+
+	return func(lon, lat float64) (float64, float64) {
+		phi := lat * math.Pi / 180
+		lambda := (lon - lon0) * math.Pi / 180
+
+		// Latitud conforme y coordenadas en la esfera transversa
+		t := math.Sinh(math.Atanh(math.Sin(phi)) - e*math.Atanh(e*math.Sin(phi)))
+		xi := math.Atan2(t, math.Cos(lambda))
+		eta := math.Atanh(math.Sin(lambda) / math.Sqrt(1+t*t))
+
+		x, y := eta, xi
+		for j, a := range alpha {
+			k := float64(2 * (j + 1))
+			x += a * math.Cos(k*xi) * math.Sinh(k*eta)
+			y += a * math.Sin(k*xi) * math.Cosh(k*eta)
+		}
+		return falseEasting + k0*radius*x, falseNorthing + k0*radius*y
+	}
+}
+
+// eccentricity retorna la excentricidad de un elipsoide a partir de su inverso del aplanamiento
+func eccentricity(inverseFlattening float64) float64 {
+	f := 1 / inverseFlattening
+	return math.Sqrt(2*f - f*f)
+}
+
+// ProjectGeometry retorna una copia de la geometría GeoJSON (incluidas
+// GeometryCollection) con las posiciones proyectadas al sistema. Acepta tanto
+// slices tipados como geometrías decodificadas desde JSON; las coordenadas
+// resultantes siempre son tipadas.
+func (c *CRS) ProjectGeometry(geometry any) any {
+	geomMap, ok := geometry.(map[string]interface{})
+	if !ok || c.Geographic() {
+		return geometry
+	}
+
+	result := make(map[string]interface{}, len(geomMap))
+	for key, value := range geomMap {
+		result[key] = value
+	}
+	if geometries, ok := geomMap["geometries"].([]interface{}); ok {
+		projected := make([]interface{}, len(geometries))
+		for i, child := range geometries {
+			projected[i] = c.ProjectGeometry(child)
+		}
+		result["geometries"] = projected
+	}
+	if coords, ok := geomMap["coordinates"]; ok {
+		result["coordinates"] = c.projectCoordinates(coords)
+	}
+	return result
+}
+
+// ProjectPositions retorna una copia de las posiciones proyectadas al sistema
+func (c *CRS) ProjectPositions(positions [][]float64) [][]float64 {
+	out := make([][]float64, len(positions))
+	for i, pos := range positions {
+		out[i] = c.projectPosition(pos)
+	}
+	return out
+}
+
+// projectPosition proyecta una posición conservando sus coordenadas extra (altura)
+func (c *CRS) projectPosition(pos []float64) []float64 {
+	if len(pos) < 2 {
+		return pos
+	}
+	out := append([]float64(nil), pos...)
+	out[0], out[1] = c.Project(pos[0], pos[1])
+	return out
+}
+
+// projectCoordinates proyecta coordenadas GeoJSON con cualquier anidamiento
+func (c *CRS) projectCoordinates(coords any) any {
+	switch t := coords.(type) {
+	case []float64:
+		return c.projectPosition(t)
+	case [][]float64:
+		return c.ProjectPositions(t)
+	case [][][]float64:
+		out := make([][][]float64, len(t))
+		for i, ring := range t {
+			out[i] = c.ProjectPositions(ring)
+		}
+		return out
+	case [][][][]float64:
+		out := make([][][][]float64, len(t))
+		for i, polygon := range t {
+			out[i] = c.projectCoordinates(polygon).([][][]float64)
+		}
+		return out
+	case []interface{}:
+		if pos := toPosition(t); pos != nil {
+			return c.projectPosition(append(pos, extraCoordinates(t)...))
+		}
+		out := make([]interface{}, len(t))
+		for i, child := range t {
+			out[i] = c.projectCoordinates(child)
+		}
+		return out
+	}
+	return coords
+}
+
+// extraCoordinates retorna las coordenadas después de lon y lat (altura) de una
+// posición decodificada desde JSON
+func extraCoordinates(pos []interface{}) []float64 {
+	var extra []float64
+	for _, value := range pos[2:] {
+		if v, ok := value.(float64); ok {
+			extra = append(extra, v)
+		}
+	}
+	return extra
+}
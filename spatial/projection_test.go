@@ -0,0 +1,105 @@
+package spatial
+
+import (
+	"math"
+	"testing"
+)
+
+// meridianArc integra numéricamente (Simpson) la distancia sobre el meridiano
+// desde el ecuador hasta lat en el elipsoide WGS84, independiente de la serie
+// de Krüger de transverseMercator
+func meridianArc(lat float64) float64 {
+	e2 := math.Pow(eccentricity(wgs84InverseFlattening), 2)
+	radius := func(phi float64) float64 {
+		s := math.Sin(phi)
+		return semiMajorAxis * (1 - e2) / math.Pow(1-e2*s*s, 1.5)
+	}
+
+	const steps = 10000
+	phi := lat * math.Pi / 180
+	h := phi / steps
+	sum := radius(0) + radius(phi)
+	for i := 1; i < steps; i++ {
+		weight := 2.0
+		if i%2 == 1 {
+			weight = 4
+		}
+		sum += weight * radius(float64(i)*h)
+	}
+	return sum * h / 3
+}
+
+func assertProjected(t *testing.T, crs *CRS, lon, lat, wantX, wantY, tolerance float64) {
+	t.Helper()
+
+	x, y := crs.Project(lon, lat)
+	if math.Abs(x-wantX) > tolerance || math.Abs(y-wantY) > tolerance {
+		t.Errorf("EPSG:%d (%g, %g) = (%.4f, %.4f), want (%.4f, %.4f)", crs.Code, lon, lat, x, y, wantX, wantY)
+	}
+}
+
+func TestElSalvadorLambertOrigin(t *testing.T) {
+	// Origen de la proyección del CNR: falsos este y norte
+	assertProjected(t, ElSalvadorLambert, -89, 13+47.0/60, 500000, 295809.184, 1e-6)
+}
+
+func TestElSalvadorLambertScale(t *testing.T) {
+	// Sobre el paralelo estándar la escala es k0: un arco corto del paralelo
+	// mide k0 · ν · cos φ0 · Δλ
+	lat0 := (13 + 47.0/60) * math.Pi / 180
+	e2 := math.Pow(eccentricity(grs80InverseFlattening), 2)
+	nu := semiMajorAxis / math.Sqrt(1-e2*math.Sin(lat0)*math.Sin(lat0))
+	dLon := 0.001
+	want := 0.99996704 * nu * math.Cos(lat0) * dLon * math.Pi / 180
+
+	east, _ := ElSalvadorLambert.Project(-89+dLon, 13+47.0/60)
+	west, _ := ElSalvadorLambert.Project(-89-dLon, 13+47.0/60)
+	if math.Abs(east-500000-want) > 1e-6 {
+		t.Errorf("easting offset = %.6f, want %.6f", east-500000, want)
+	}
+	if math.Abs((east-500000)+(west-500000)) > 1e-6 {
+		t.Errorf("projection not symmetric about the central meridian: %.6f, %.6f", east, west)
+	}
+}
+
+func TestUTM16NCentralMeridian(t *testing.T) {
+	// Sobre el meridiano central el norte es k0 por el arco de meridiano
+	for _, lat := range []float64{0, 13.2, 13.7833, 14.45} {
+		assertProjected(t, UTM16N, -87, lat, 500000, 0.9996*meridianArc(lat), 1e-3)
+	}
+}
+
+func TestUTM16NZoneEdge(t *testing.T) {
+	// Valores de referencia en el ecuador, en los bordes de la zona
+	assertProjected(t, UTM16N, -84, 0, 833978.557, 0, 1e-3)
+	assertProjected(t, UTM16N, -90, 0, 166021.443, 0, 1e-3)
+}
+
+func TestParseCRS(t *testing.T) {
+	for value, want := range map[string]*CRS{
+		"EPSG:5460":                   ElSalvadorLambert,
+		"epsg:32616":                  UTM16N,
+		"5460":                        ElSalvadorLambert,
+		"urn:ogc:def:crs:EPSG::32616": UTM16N,
+		"http://www.opengis.net/def/crs/EPSG/0/4326": WGS84,
+		CRS84URI:                        WGS84,
+		"urn:ogc:def:crs:OGC:1.3:CRS84": WGS84,
+		"OGC:CRS84":                     WGS84,
+	} {
+		if got, ok := ParseCRS(value); !ok || got != want {
+			t.Errorf("ParseCRS(%q) = %v, %v", value, got, ok)
+		}
+	}
+	for _, value := range []string{"", "EPSG:3857", "EPSG:", "lambert"} {
+		if _, ok := ParseCRS(value); ok {
+			t.Errorf("ParseCRS(%q) accepted an unsupported CRS", value)
+		}
+	}
+
+	if got := WGS84.URI(); got != CRS84URI {
+		t.Errorf("WGS84.URI() = %q, want %q", got, CRS84URI)
+	}
+	if got, want := ElSalvadorLambert.URI(), "http://www.opengis.net/def/crs/EPSG/0/5460"; got != want {
+		t.Errorf("ElSalvadorLambert.URI() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,190 @@
+package spatial
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// nodeCapacity es la cantidad máxima de hijos por nodo del R-tree
+const nodeCapacity = 16
+
+// Item es una entrada del índice: un rectángulo y el ID que lo identifica
+type Item struct {
+	BBox BBox
+	ID   int
+}
+
+// rtreeNode es un nodo del árbol; en las hojas children son IDs de items
+type rtreeNode struct {
+	bbox     BBox
+	children []int
+	leaf     bool
+}
+
+// Index es un R-tree empaquetado con Sort-Tile-Recursive (STR). Se construye una
+// sola vez a partir de todos los items y es de solo lectura, por lo que puede
+// consultarse de forma concurrente sin bloqueos.
+type Index struct {
+	nodes []rtreeNode
+	items []Item
+	root  int
+}
+
+// NewIndex construye el índice a partir de los items
+func NewIndex(items []Item) *Index {
+	idx := &Index{items: items, root: -1}
+	if len(items) == 0 {
+		return idx
+	}
+
+	// Nivel de hojas: agrupar items
+	entries := make([]int, len(items))
+	for i := range entries {
+		entries[i] = i
+	}
+	level := idx.pack(entries, func(i int) BBox { return items[i].BBox }, true)
+
+	// Niveles superiores hasta llegar a la raíz
+	for len(level) > 1 {
+		level = idx.pack(level, func(n int) BBox { return idx.nodes[n].bbox }, false)
+	}
+	idx.root = level[0]
+	return idx
+}
+
+// pack agrupa las entradas en nodos usando STR y retorna los índices de los nodos creados
+func (idx *Index) pack(entries []int, bboxOf func(int) BBox, leaf bool) []int {
+	center := func(i int, axis int) float64 {
+		b := bboxOf(i)
+		return (b[axis] + b[axis+2]) / 2
+	}
+
+	nodeCount := int(math.Ceil(float64(len(entries)) / nodeCapacity))
+	sliceCount := int(math.Ceil(math.Sqrt(float64(nodeCount))))
+	sliceSize := sliceCount * nodeCapacity
+
+	sort.Slice(entries, func(a, b int) bool { return center(entries[a], 0) < center(entries[b], 0) })
+
+	created := make([]int, 0, nodeCount)
+	for start := 0; start < len(entries); start += sliceSize {
+		slice := entries[start:min(start+sliceSize, len(entries))]
+		sort.Slice(slice, func(a, b int) bool { return center(slice[a], 1) < center(slice[b], 1) })
+
+		for nodeStart := 0; nodeStart < len(slice); nodeStart += nodeCapacity {
+			children := append([]int(nil), slice[nodeStart:min(nodeStart+nodeCapacity, len(slice))]...)
+			bbox := EmptyBBox()
+			for _, child := range children {
+				bbox.Union(bboxOf(child))
+			}
+			idx.nodes = append(idx.nodes, rtreeNode{bbox: bbox, children: children, leaf: leaf})
+			created = append(created, len(idx.nodes)-1)
+		}
+	}
+	return created
+}
+
+// Len retorna la cantidad de items indexados
+func (idx *Index) Len() int {
+	return len(idx.items)
+}
+
+// Search invoca fn con el ID de cada item cuyo rectángulo intersecta query.
+// La búsqueda se detiene si fn retorna false.
+func (idx *Index) Search(query BBox, fn func(id int) bool) {
+	if idx.root < 0 {
+		return
+	}
+
+	stack := []int{idx.root}
+	for len(stack) > 0 {
+		node := &idx.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+
+		if !node.bbox.Intersects(query) {
+			continue
+		}
+		for _, child := range node.children {
+			if node.leaf {
+				item := idx.items[child]
+				if item.BBox.Intersects(query) && !fn(item.ID) {
+					return
+				}
+			} else {
+				stack = append(stack, child)
+			}
+		}
+	}
+}
+
+// Nearest retorna el ID del item más cercano al punto según distance, que debe
+// retornar la distancia exacta (en km) al item con ese ID. Los rectángulos se
+// usan como cota inferior, por lo que solo se evalúan los items candidatos.
+// Retorna -1 si el índice está vacío.
+func (idx *Index) Nearest(lon, lat float64, distance func(id int) float64) (int, float64) {
+	if idx.root < 0 {
+		return -1, math.Inf(1)
+	}
+
+	queue := &nearestQueue{{node: idx.root, dist: BBoxDistanceKm(idx.nodes[idx.root].bbox, lon, lat)}}
+	for queue.Len() > 0 {
+		entry := heap.Pop(queue).(nearestEntry)
+
+		switch {
+		case entry.exact:
+			// Ninguna entrada restante puede estar más cerca
+			return idx.items[entry.item].ID, entry.dist
+		case entry.node < 0:
+			item := idx.items[entry.item]
+			heap.Push(queue, nearestEntry{node: -1, item: entry.item, dist: distance(item.ID), exact: true})
+		default:
+			node := &idx.nodes[entry.node]
+			for _, child := range node.children {
+				if node.leaf {
+					bbox := idx.items[child].BBox
+					heap.Push(queue, nearestEntry{node: -1, item: child, dist: BBoxDistanceKm(bbox, lon, lat)})
+				} else {
+					bbox := idx.nodes[child].bbox
+					heap.Push(queue, nearestEntry{node: child, dist: BBoxDistanceKm(bbox, lon, lat)})
+				}
+			}
+		}
+	}
+	return -1, math.Inf(1)
+}
+
+// BBoxDistanceKm retorna la distancia en km desde el punto al rectángulo (0 si
+// lo contiene), con la misma proyección local que DistanceToPolygonsKm
+func BBoxDistanceKm(b BBox, lon, lat float64) float64 {
+	if b.IsEmpty() {
+		return math.Inf(1)
+	}
+	kx := earthRadiusKm * math.Pi / 180 * math.Cos(lat*math.Pi/180)
+	ky := earthRadiusKm * math.Pi / 180
+
+	dx := math.Max(0, math.Max(b[0]-lon, lon-b[2])) * kx
+	dy := math.Max(0, math.Max(b[1]-lat, lat-b[3])) * ky
+	return math.Hypot(dx, dy)
+}
+
+// nearestEntry es un nodo o item pendiente en la búsqueda del más cercano
+type nearestEntry struct {
+	node  int // -1 para items
+	item  int
+	dist  float64
+	exact bool
+}
+
+// nearestQueue es una cola de prioridad por distancia
+type nearestQueue []nearestEntry
+
+func (q nearestQueue) Len() int            { return len(q) }
+func (q nearestQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q nearestQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *nearestQueue) Push(x interface{}) { *q = append(*q, x.(nearestEntry)) }
+func (q *nearestQueue) Pop() interface{} {
+	old := *q
+	entry := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return entry
+}
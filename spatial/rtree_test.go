@@ -0,0 +1,122 @@
+package spatial
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// randomItems genera rectángulos pequeños sobre El Salvador. Los IDs no
+// coinciden con la posición para comprobar que el índice retorna el ID.
+func randomItems(rng *rand.Rand, n int) []Item {
+	items := make([]Item, n)
+	for i := range items {
+		lon := -90.2 + rng.Float64()*2.5
+		lat := 13.1 + rng.Float64()*1.4
+		w, h := rng.Float64()*0.2, rng.Float64()*0.2
+		items[i] = Item{BBox: BBox{lon, lat, lon + w, lat + h}, ID: 1000 + i*3}
+	}
+	return items
+}
+
+func TestIndexSearchMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 15, 16, 17, 300, 2000} {
+		items := randomItems(rng, n)
+		idx := NewIndex(append([]Item(nil), items...))
+		if idx.Len() != n {
+			t.Fatalf("n=%d: Len = %d", n, idx.Len())
+		}
+
+		for q := 0; q < 50; q++ {
+			lon, lat := -90.4+rng.Float64()*3, 12.9+rng.Float64()*1.8
+			query := BBox{lon, lat, lon + rng.Float64()*0.5, lat + rng.Float64()*0.5}
+
+			var want []int
+			for _, item := range items {
+				if item.BBox.Intersects(query) {
+					want = append(want, item.ID)
+				}
+			}
+			var got []int
+			idx.Search(query, func(id int) bool {
+				got = append(got, id)
+				return true
+			})
+
+			sort.Ints(want)
+			sort.Ints(got)
+			if !equalInts(got, want) {
+				t.Fatalf("n=%d query %v: got %v, want %v", n, query, got, want)
+			}
+		}
+	}
+}
+
+func TestIndexSearchStops(t *testing.T) {
+	idx := NewIndex(randomItems(rand.New(rand.NewSource(2)), 500))
+
+	calls := 0
+	idx.Search(BBox{-91, 12, -87, 15}, func(int) bool {
+		calls++
+		return calls < 3
+	})
+	if calls != 3 {
+		t.Errorf("fn called %d times after returning false, want 3", calls)
+	}
+}
+
+func TestIndexNearestMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for _, n := range []int{1, 17, 300, 2000} {
+		items := randomItems(rng, n)
+		byID := make(map[int]BBox, n)
+		for _, item := range items {
+			byID[item.ID] = item.BBox
+		}
+		idx := NewIndex(append([]Item(nil), items...))
+
+		for q := 0; q < 100; q++ {
+			lon, lat := -91+rng.Float64()*4, 12.5+rng.Float64()*2.5
+			distance := func(id int) float64 { return BBoxDistanceKm(byID[id], lon, lat) }
+
+			want := math.Inf(1)
+			for _, item := range items {
+				want = math.Min(want, BBoxDistanceKm(item.BBox, lon, lat))
+			}
+
+			id, got := idx.Nearest(lon, lat, distance)
+			if _, ok := byID[id]; !ok {
+				t.Fatalf("n=%d: Nearest returned unknown ID %d", n, id)
+			}
+			// Con empates basta que la distancia sea la mínima
+			if got != want || distance(id) != want {
+				t.Fatalf("n=%d point (%g, %g): got ID %d at %g km, want %g km", n, lon, lat, id, got, want)
+			}
+		}
+	}
+}
+
+func TestIndexEmpty(t *testing.T) {
+	idx := NewIndex(nil)
+	idx.Search(BBox{-180, -90, 180, 90}, func(int) bool {
+		t.Error("Search called fn on an empty index")
+		return true
+	})
+	if id, dist := idx.Nearest(0, 0, func(int) float64 { return 0 }); id != -1 || !math.IsInf(dist, 1) {
+		t.Errorf("Nearest on empty index = %d, %g", id, dist)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
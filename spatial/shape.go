@@ -0,0 +1,347 @@
+package spatial
+
+import (
+	"fmt"
+	"math"
+)
+
+// Shape es una geometría GeoJSON arbitraria descompuesta en puntos, líneas y
+// polígonos, usada como área de consulta en las pruebas de intersección.
+type Shape struct {
+	Points   [][]float64
+	Lines    [][][]float64
+	Polygons [][][][]float64
+}
+
+// ShapeFromBBox retorna el rectángulo como un polígono
+func ShapeFromBBox(b BBox) Shape {
+	ring := [][]float64{{b[0], b[1]}, {b[2], b[1]}, {b[2], b[3]}, {b[0], b[3]}, {b[0], b[1]}}
+	return Shape{Polygons: [][][][]float64{{ring}}}
+}
+
+// MaxShapeVertices es la cantidad máxima de posiciones de una forma recibida;
+// cada posición se prueba contra los bordes de los features candidatos
+const MaxShapeVertices = 10000
+
+// ErrShapeTooLarge indica que la forma supera MaxShapeVertices
+var ErrShapeTooLarge = fmt.Errorf("la geometría supera el máximo de %d posiciones", MaxShapeVertices)
+
+// ParseShape convierte una geometría GeoJSON decodificada desde JSON. También
+// acepta un Feature, del que se usa su geometría. Las coordenadas mal formadas
+// (posiciones no numéricas, líneas de menos de 2 posiciones, anillos de menos de
+// 4 posiciones o sin cerrar) son un error; si la forma supera
+// MaxShapeVertices retorna ErrShapeTooLarge.
+func ParseShape(geojson map[string]interface{}) (Shape, error) {
+	var shape Shape
+	if err := shape.add(geojson, 0); err != nil {
+		return Shape{}, err
+	}
+	if shape.IsEmpty() {
+		return Shape{}, fmt.Errorf("la geometría no contiene coordenadas")
+	}
+	if shape.vertexCount() > MaxShapeVertices {
+		return Shape{}, ErrShapeTooLarge
+	}
+	return shape, nil
+}
+
+// add agrega una geometría a la forma; depth limita el anidamiento de GeometryCollection
+func (s *Shape) add(geojson map[string]interface{}, depth int) error {
+	if depth > 4 {
+		return fmt.Errorf("GeometryCollection anidada demasiado profundo")
+	}
+
+	geomType, _ := geojson["type"].(string)
+	coords := geojson["coordinates"]
+
+	switch geomType {
+	case "Feature":
+		geometry, ok := geojson["geometry"].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("el Feature no tiene geometría")
+		}
+		return s.add(geometry, depth+1)
+	case "GeometryCollection":
+		geometries, ok := geojson["geometries"].([]interface{})
+		if !ok {
+			return fmt.Errorf("GeometryCollection sin 'geometries'")
+		}
+		for _, raw := range geometries {
+			geometry, ok := raw.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("GeometryCollection con geometría inválida")
+			}
+			if err := s.add(geometry, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "Point":
+		point, err := parsePosition(coords)
+		if err != nil {
+			return fmt.Errorf("coordenadas de Point inválidas: %w", err)
+		}
+		s.Points = append(s.Points, point)
+	case "MultiPoint":
+		points, err := parsePositions(coords, 1)
+		if err != nil {
+			return fmt.Errorf("coordenadas de MultiPoint inválidas: %w", err)
+		}
+		s.Points = append(s.Points, points...)
+	case "LineString":
+		line, err := parsePositions(coords, 2)
+		if err != nil {
+			return fmt.Errorf("coordenadas de LineString inválidas: %w", err)
+		}
+		s.Lines = append(s.Lines, line)
+	case "MultiLineString":
+		lines, err := parseList(coords, func(raw any) ([][]float64, error) { return parsePositions(raw, 2) })
+		if err != nil {
+			return fmt.Errorf("coordenadas de MultiLineString inválidas: %w", err)
+		}
+		s.Lines = append(s.Lines, lines...)
+	case "Polygon":
+		polygon, err := parsePolygon(coords)
+		if err != nil {
+			return fmt.Errorf("coordenadas de Polygon inválidas: %w", err)
+		}
+		s.Polygons = append(s.Polygons, polygon)
+	case "MultiPolygon":
+		polys, err := parseList(coords, parsePolygon)
+		if err != nil {
+			return fmt.Errorf("coordenadas de MultiPolygon inválidas: %w", err)
+		}
+		s.Polygons = append(s.Polygons, polys...)
+	default:
+		return fmt.Errorf("tipo de geometría no soportado: %q", geomType)
+	}
+	return nil
+}
+
+// vertexCount retorna la cantidad de posiciones de la forma
+func (s Shape) vertexCount() int {
+	count := 0
+	s.eachPosition(func([]float64) { count++ })
+	return count
+}
+
+// parsePosition valida una posición [lon, lat] (o [lon, lat, altura]) decodificada desde JSON
+func parsePosition(raw any) ([]float64, error) {
+	pair, ok := raw.([]interface{})
+	if !ok || len(pair) < 2 {
+		return nil, fmt.Errorf("cada posición debe ser [lon, lat]")
+	}
+	for _, value := range pair {
+		if _, ok := value.(float64); !ok {
+			return nil, fmt.Errorf("la posición %v no es numérica", pair)
+		}
+	}
+	return []float64{pair[0].(float64), pair[1].(float64)}, nil
+}
+
+// parsePositions valida una lista de al menos min posiciones
+func parsePositions(raw any, min int) ([][]float64, error) {
+	positions, err := parseList(raw, parsePosition)
+	if err != nil {
+		return nil, err
+	}
+	if len(positions) < min {
+		return nil, fmt.Errorf("se necesitan al menos %d posiciones", min)
+	}
+	return positions, nil
+}
+
+// parsePolygon valida los anillos de un polígono: al menos uno, cada uno con
+// 4 o más posiciones y cerrado (la última posición igual a la primera)
+func parsePolygon(raw any) ([][][]float64, error) {
+	rings, err := parseList(raw, func(raw any) ([][]float64, error) {
+		ring, err := parsePositions(raw, 4)
+		if err != nil {
+			return nil, fmt.Errorf("anillo inválido: %w", err)
+		}
+		first, last := ring[0], ring[len(ring)-1]
+		if first[0] != last[0] || first[1] != last[1] {
+			return nil, fmt.Errorf("anillo sin cerrar: la última posición debe ser igual a la primera")
+		}
+		return ring, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(rings) == 0 {
+		return nil, fmt.Errorf("el polígono no tiene anillos")
+	}
+	return rings, nil
+}
+
+// parseList valida una lista JSON no vacía aplicando parse a cada elemento
+func parseList[T any](raw any, parse func(any) (T, error)) ([]T, error) {
+	list, ok := raw.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("se esperaba una lista de coordenadas")
+	}
+	result := make([]T, 0, len(list))
+	for _, item := range list {
+		value, err := parse(item)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+	}
+	return result, nil
+}
+
+// IsEmpty indica si la forma no tiene coordenadas
+func (s Shape) IsEmpty() bool {
+	return len(s.Points) == 0 && len(s.Lines) == 0 && len(s.Polygons) == 0
+}
+
+// Valid indica si todas las coordenadas están dentro del rango WGS84
+func (s Shape) Valid() bool {
+	valid := true
+	s.eachPosition(func(p []float64) {
+		if math.IsNaN(p[0]) || math.IsNaN(p[1]) || p[0] < -180 || p[0] > 180 || p[1] < -90 || p[1] > 90 {
+			valid = false
+		}
+	})
+	return valid
+}
+
+// Bounds retorna el rectángulo envolvente de la forma
+func (s Shape) Bounds() BBox {
+	bounds := EmptyBBox()
+	s.eachPosition(func(p []float64) { bounds.Extend(p[0], p[1]) })
+	return bounds
+}
+
+func (s Shape) eachPosition(fn func(p []float64)) {
+	for _, p := range s.Points {
+		fn(p)
+	}
+	for _, line := range s.Lines {
+		for _, p := range line {
+			fn(p)
+		}
+	}
+	for _, polygon := range s.Polygons {
+		for _, ring := range polygon {
+			for _, p := range ring {
+				fn(p)
+			}
+		}
+	}
+}
+
+// IntersectsPolygons indica si la forma toca o se superpone con el
+// multipolígono (bordes incluidos)
+func (s Shape) IntersectsPolygons(polys [][][][]float64) bool {
+	bounds := s.Bounds()
+
+	// Puntos dentro del multipolígono o sobre su borde
+	for _, p := range s.Points {
+		if PolygonsContain(polys, p[0], p[1]) || onPolygonsBoundary(polys, p[0], p[1]) {
+			return true
+		}
+	}
+
+	// Cruces entre bordes: líneas y anillos de la forma contra los anillos del multipolígono
+	for _, line := range s.Lines {
+		if pathCrossesPolygons(line, bounds, polys) {
+			return true
+		}
+	}
+	for _, polygon := range s.Polygons {
+		for _, ring := range polygon {
+			if pathCrossesPolygons(ring, bounds, polys) {
+				return true
+			}
+		}
+	}
+
+	// Sin cruces, uno puede contener completamente al otro
+	for _, line := range s.Lines {
+		if PolygonsContain(polys, line[0][0], line[0][1]) {
+			return true
+		}
+	}
+	for _, polygon := range s.Polygons {
+		if len(polygon) > 0 && len(polygon[0]) > 0 && PolygonsContain(polys, polygon[0][0][0], polygon[0][0][1]) {
+			return true
+		}
+	}
+	for _, polygon := range polys {
+		if len(polygon) > 0 && len(polygon[0]) > 0 && PolygonsContain(s.Polygons, polygon[0][0][0], polygon[0][0][1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathCrossesPolygons indica si algún segmento de path toca algún borde del
+// multipolígono. Solo se revisan los segmentos del multipolígono que
+// intersectan bounds, el rectángulo envolvente de la forma.
+func pathCrossesPolygons(path [][]float64, bounds BBox, polys [][][][]float64) bool {
+	for _, polygon := range polys {
+		for _, ring := range polygon {
+			for i := 1; i < len(ring); i++ {
+				a, b := ring[i-1], ring[i]
+				if len(a) < 2 || len(b) < 2 || !segmentBounds(a, b).Intersects(bounds) {
+					continue
+				}
+				for j := 1; j < len(path); j++ {
+					if segmentsIntersect(a, b, path[j-1], path[j]) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// onPolygonsBoundary indica si el punto cae exactamente sobre un borde
+func onPolygonsBoundary(polys [][][][]float64, lon, lat float64) bool {
+	p := []float64{lon, lat}
+	for _, polygon := range polys {
+		for _, ring := range polygon {
+			for i := 1; i < len(ring); i++ {
+				if segmentsIntersect(ring[i-1], ring[i], p, p) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func segmentBounds(a, b []float64) BBox {
+	return BBox{math.Min(a[0], b[0]), math.Min(a[1], b[1]), math.Max(a[0], b[0]), math.Max(a[1], b[1])}
+}
+
+// segmentsIntersect indica si los segmentos p1-p2 y q1-q2 se tocan, incluyendo
+// extremos compartidos y segmentos colineales superpuestos
+func segmentsIntersect(p1, p2, q1, q2 []float64) bool {
+	d1 := orientation(q1, q2, p1)
+	d2 := orientation(q1, q2, p2)
+	d3 := orientation(p1, p2, q1)
+	d4 := orientation(p1, p2, q2)
+
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	return (d1 == 0 && onSegment(q1, q2, p1)) ||
+		(d2 == 0 && onSegment(q1, q2, p2)) ||
+		(d3 == 0 && onSegment(p1, p2, q1)) ||
+		(d4 == 0 && onSegment(p1, p2, q2))
+}
+
+// orientation retorna el producto cruz de (b-a) y (c-a)
+func orientation(a, b, c []float64) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+// onSegment indica si c, colineal con a-b, cae dentro del segmento
+func onSegment(a, b, c []float64) bool {
+	return c[0] >= math.Min(a[0], b[0]) && c[0] <= math.Max(a[0], b[0]) &&
+		c[1] >= math.Min(a[1], b[1]) && c[1] <= math.Max(a[1], b[1])
+}
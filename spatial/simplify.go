@@ -0,0 +1,111 @@
+package spatial
+
+import "math"
+
+// ToleranceForZoom retorna la tolerancia de simplificación, en grados, que
+// equivale a un pixel en un mapa web de tiles de 256 px al zoom indicado
+func ToleranceForZoom(zoom int) float64 {
+	return 360 / (256 * math.Exp2(float64(zoom)))
+}
+
+// SnapTolerance ajusta una tolerancia en grados a la de ToleranceForZoom del
+// menor zoom (0 a 22) que no la supera, de modo que solo existan 23 niveles de
+// simplificación. Retorna 0 (sin simplificar) si tolerance no es positiva.
+func SnapTolerance(tolerance float64) float64 {
+	if !(tolerance > 0) {
+		return 0
+	}
+	for zoom := 0; zoom < 22; zoom++ {
+		if snapped := ToleranceForZoom(zoom); snapped <= tolerance {
+			return snapped
+		}
+	}
+	return ToleranceForZoom(22)
+}
+
+// SimplifyPath reduce los vértices de una línea con Douglas-Peucker, conservando
+// los extremos. tolerance está en las mismas unidades que las coordenadas.
+func SimplifyPath(path [][]float64, tolerance float64) [][]float64 {
+	if len(path) <= 2 || tolerance <= 0 {
+		return path
+	}
+
+	keep := make([]bool, len(path))
+	keep[0], keep[len(path)-1] = true, true
+	markDouglasPeucker(path, 0, len(path)-1, tolerance*tolerance, keep)
+
+	result := make([][]float64, 0, len(path))
+	for i, p := range path {
+		if keep[i] {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// SimplifyRing simplifica un anillo cerrado. Como los extremos de un anillo
+// coinciden, se divide en el vértice más lejano al primero y se simplifica
+// cada mitad. Retorna nil si el anillo colapsa a menos de 4 posiciones.
+func SimplifyRing(ring [][]float64, tolerance float64) [][]float64 {
+	if len(ring) < 4 || tolerance <= 0 {
+		return ring
+	}
+
+	split, farthest := 0, -1.0
+	for i, p := range ring {
+		if d := squaredDistance(ring[0], p); d > farthest {
+			split, farthest = i, d
+		}
+	}
+	if farthest == 0 {
+		return nil
+	}
+
+	head := SimplifyPath(ring[:split+1], tolerance)
+	tail := SimplifyPath(ring[split:], tolerance)
+	result := append(append(make([][]float64, 0, len(head)+len(tail)), head...), tail[1:]...)
+	if len(result) < 4 {
+		return nil
+	}
+	return result
+}
+
+// markDouglasPeucker marca en keep los vértices de path[first:last] que se
+// alejan más de la tolerancia (al cuadrado) del segmento entre los extremos
+func markDouglasPeucker(path [][]float64, first, last int, toleranceSq float64, keep []bool) {
+	// Pila explícita para no recurrir en anillos de miles de vértices
+	stack := [][2]int{{first, last}}
+	for len(stack) > 0 {
+		span := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		first, last := span[0], span[1]
+
+		index, maxDist := -1, toleranceSq
+		for i := first + 1; i < last; i++ {
+			if d := squaredSegmentDistance(path[i], path[first], path[last]); d > maxDist {
+				index, maxDist = i, d
+			}
+		}
+		if index < 0 {
+			continue
+		}
+		keep[index] = true
+		stack = append(stack, [2]int{first, index}, [2]int{index, last})
+	}
+}
+
+// squaredSegmentDistance retorna el cuadrado de la distancia de p al segmento a-b
+func squaredSegmentDistance(p, a, b []float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	t := 0.0
+	if lengthSq := dx*dx + dy*dy; lengthSq > 0 {
+		t = math.Max(0, math.Min(1, ((p[0]-a[0])*dx+(p[1]-a[1])*dy)/lengthSq))
+	}
+	ex, ey := p[0]-(a[0]+t*dx), p[1]-(a[1]+t*dy)
+	return ex*ex + ey*ey
+}
+
+func squaredDistance(a, b []float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	return dx*dx + dy*dy
+}
@@ -0,0 +1,100 @@
+package spatial
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// maxDeviation retorna la mayor distancia de un vértice de path al tramo de
+// la línea simplificada que lo cubre. Los vértices de simplified son un
+// subconjunto ordenado de los de path.
+func maxDeviation(path, simplified [][]float64) float64 {
+	worst, segment := 0.0, 0
+	for _, p := range path {
+		if segment+2 < len(simplified) && samePoint(p, simplified[segment+1]) {
+			segment++
+		}
+		d := math.Sqrt(squaredSegmentDistance(p, simplified[segment], simplified[segment+1]))
+		worst = math.Max(worst, d)
+	}
+	return worst
+}
+
+func samePoint(a, b []float64) bool {
+	return a[0] == b[0] && a[1] == b[1]
+}
+
+// randomWalk genera una línea irregular de n vértices
+func randomWalk(rng *rand.Rand, n int) [][]float64 {
+	path := make([][]float64, n)
+	x, y := -89.0, 13.5
+	for i := range path {
+		x += rng.Float64() * 0.01
+		y += (rng.Float64() - 0.5) * 0.02
+		path[i] = []float64{x, y}
+	}
+	return path
+}
+
+func TestSimplifyPathWithinTolerance(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, tolerance := range []float64{0.0005, 0.002, 0.01} {
+		path := randomWalk(rng, 2000)
+		simplified := SimplifyPath(path, tolerance)
+
+		if len(simplified) >= len(path) {
+			t.Errorf("tolerance %g: kept all %d vertices", tolerance, len(path))
+		}
+		if !samePoint(simplified[0], path[0]) || !samePoint(simplified[len(simplified)-1], path[len(path)-1]) {
+			t.Errorf("tolerance %g: endpoints not kept", tolerance)
+		}
+		if d := maxDeviation(path, simplified); d > tolerance+1e-12 {
+			t.Errorf("tolerance %g: vertex %g away from the simplified line", tolerance, d)
+		}
+	}
+}
+
+func TestSimplifyPathCollinear(t *testing.T) {
+	path := [][]float64{{0, 0}, {1, 0}, {2, 0}, {3, 0}, {4, 0}}
+	simplified := SimplifyPath(path, 0.1)
+	if len(simplified) != 2 || !samePoint(simplified[0], path[0]) || !samePoint(simplified[1], path[4]) {
+		t.Errorf("got %v, want the two endpoints", simplified)
+	}
+
+	if got := SimplifyPath(path, 0); len(got) != len(path) {
+		t.Errorf("tolerance 0 removed vertices: %v", got)
+	}
+}
+
+func TestSimplifyRing(t *testing.T) {
+	square := [][]float64{{0, 0}, {0.5, 0.001}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}
+	simplified := SimplifyRing(square, 0.01)
+	if len(simplified) != 5 || !RingClosed(simplified) {
+		t.Errorf("got %v, want the closed square without its near-collinear vertex", simplified)
+	}
+
+	// Un anillo más pequeño que la tolerancia colapsa
+	tiny := [][]float64{{0, 0}, {0.001, 0}, {0.001, 0.001}, {0, 0.001}, {0, 0}}
+	if got := SimplifyRing(tiny, 0.01); got != nil {
+		t.Errorf("tiny ring simplified to %v, want nil", got)
+	}
+}
+
+func TestSnapTolerance(t *testing.T) {
+	if got := SnapTolerance(0); got != 0 {
+		t.Errorf("SnapTolerance(0) = %g, want 0", got)
+	}
+	for zoom := 0; zoom <= 22; zoom++ {
+		tolerance := ToleranceForZoom(zoom)
+		if got := SnapTolerance(tolerance); got != tolerance {
+			t.Errorf("zoom %d: SnapTolerance(%g) = %g", zoom, tolerance, got)
+		}
+		if got := SnapTolerance(tolerance * 1.5); got != tolerance {
+			t.Errorf("zoom %d: SnapTolerance(%g) = %g, want %g", zoom, tolerance*1.5, got, tolerance)
+		}
+	}
+	if got := SnapTolerance(1e-12); got != ToleranceForZoom(22) {
+		t.Errorf("SnapTolerance(1e-12) = %g, want zoom 22", got)
+	}
+}
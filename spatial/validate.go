@@ -0,0 +1,69 @@
+package spatial
+
+import "sort"
+
+// RingClosed indica si el anillo termina en su primera posición
+func RingClosed(ring [][]float64) bool {
+	if len(ring) < 2 {
+		return false
+	}
+	first, last := ring[0], ring[len(ring)-1]
+	return len(first) >= 2 && len(last) >= 2 && first[0] == last[0] && first[1] == last[1]
+}
+
+// RingIsCounterClockwise indica si el anillo (cerrado) gira en sentido
+// antihorario, el de los anillos exteriores según RFC 7946
+func RingIsCounterClockwise(ring [][]float64) bool {
+	return RingSignedArea(ring) > 0
+}
+
+// RingSignedArea retorna el área plana (en grados²) del anillo cerrado:
+// positiva si gira en sentido antihorario y negativa si gira en sentido horario
+func RingSignedArea(ring [][]float64) float64 {
+	sum := 0.0
+	for i := 0; i+1 < len(ring); i++ {
+		a, b := ring[i], ring[i+1]
+		sum += a[0]*b[1] - b[0]*a[1]
+	}
+	return sum / 2
+}
+
+// RingSelfIntersection busca dos segmentos no consecutivos del anillo (cerrado)
+// que se crucen o se toquen. Retorna el inicio del primero de ellos y true si
+// existe. Recorre los segmentos ordenados por longitud mínima, comparando solo
+// los que se superponen en ese eje.
+func RingSelfIntersection(ring [][]float64) ([]float64, bool) {
+	n := len(ring) - 1 // segmentos: ring[i] -> ring[i+1]
+	if n < 3 {
+		return nil, false
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	minX := func(i int) float64 { return min(ring[i][0], ring[i+1][0]) }
+	maxX := func(i int) float64 { return max(ring[i][0], ring[i+1][0]) }
+	sort.Slice(order, func(a, b int) bool { return minX(order[a]) < minX(order[b]) })
+
+	for a, i := range order {
+		limit := maxX(i)
+		for _, j := range order[a+1:] {
+			if minX(j) > limit {
+				break
+			}
+			// Los segmentos consecutivos (incluido el último con el primero) comparten un vértice
+			if d := i - j; d == 1 || d == -1 || d == n-1 || d == 1-n {
+				continue
+			}
+			if max(ring[i][1], ring[i+1][1]) < min(ring[j][1], ring[j+1][1]) ||
+				max(ring[j][1], ring[j+1][1]) < min(ring[i][1], ring[i+1][1]) {
+				continue
+			}
+			if segmentsIntersect(ring[i], ring[i+1], ring[j], ring[j+1]) {
+				return ring[min(i, j)], true
+			}
+		}
+	}
+	return nil, false
+}
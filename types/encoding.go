@@ -0,0 +1,208 @@
+package types
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// Precisión máxima con Delta. Las coordenadas cuantizadas deben ser enteros
+// exactos (|coordenada| × 10^Precision < 2^53); con estos límites además caben
+// en enteros de 32 bits: 180° × 10^7 en grados y los menos de 2.000 km de las
+// coordenadas proyectadas de El Salvador × 10^3 en metros.
+const (
+	MaxDeltaPrecisionDegrees = 7
+	MaxDeltaPrecisionMeters  = 3
+)
+
+// CoordinateEncoding controla cómo se serializan las coordenadas de un Feature
+// o una colección. Se aplica al generar el JSON, por lo que las geometrías del
+// cache no se modifican.
+type CoordinateEncoding struct {
+	// Precision es la cantidad de decimales de las coordenadas
+	Precision int
+	// Delta cuantiza las coordenadas como enteros (coordenada × 10^Precision)
+	// y codifica cada lista de posiciones (línea o anillo) como deltas de la
+	// posición anterior, igual que los arcs de TopoJSON. Los puntos sueltos se
+	// cuantizan sin deltas.
+	Delta bool
+}
+
+// Transform retorna la transformación para decodificar las coordenadas
+// cuantizadas (x = Σdeltas × scale + translate), o nil si no hay cuantización
+func (e *CoordinateEncoding) Transform() *Transform {
+	if e == nil || !e.Delta {
+		return nil
+	}
+	scale := math.Pow10(-e.Precision)
+	return &Transform{Scale: []float64{scale, scale}, Translate: []float64{0, 0}}
+}
+
+// Geometry retorna una copia de la geometría GeoJSON (incluidas
+// GeometryCollection) con las coordenadas codificadas. Acepta tanto slices
+// tipados como geometrías decodificadas desde JSON.
+func (e *CoordinateEncoding) Geometry(geometry any) any {
+	geomMap, ok := geometry.(map[string]interface{})
+	if !ok || e == nil {
+		return geometry
+	}
+
+	result := make(map[string]interface{}, len(geomMap))
+	for key, value := range geomMap {
+		result[key] = value
+	}
+	if geometries, ok := geomMap["geometries"].([]interface{}); ok {
+		encoded := make([]interface{}, len(geometries))
+		for i, child := range geometries {
+			encoded[i] = e.Geometry(child)
+		}
+		result["geometries"] = encoded
+	}
+	if coords, ok := geomMap["coordinates"]; ok {
+		result["coordinates"] = e.coordinates(coords)
+	}
+	return result
+}
+
+// coordinates codifica coordenadas GeoJSON con cualquier anidamiento
+func (e *CoordinateEncoding) coordinates(coords any) any {
+	switch t := coords.(type) {
+	case []float64:
+		return e.position(t, nil)
+	case [][]float64:
+		return e.positions(t)
+	case [][][]float64:
+		out := make([][][]float64, len(t))
+		for i, list := range t {
+			out[i] = e.positions(list)
+		}
+		return out
+	case [][][][]float64:
+		out := make([][][][]float64, len(t))
+		for i, polygon := range t {
+			out[i] = e.coordinates(polygon).([][][]float64)
+		}
+		return out
+	case []interface{}:
+		if pos, ok := decodedPosition(t); ok {
+			return e.position(pos, nil)
+		}
+		// Una lista de posiciones se codifica junta para calcular los deltas
+		if len(t) > 0 {
+			if _, ok := decodedPosition(t[0]); ok {
+				list := make([][]float64, 0, len(t))
+				for _, raw := range t {
+					pos, ok := decodedPosition(raw)
+					if !ok {
+						return coords
+					}
+					list = append(list, pos)
+				}
+				return e.positions(list)
+			}
+		}
+		out := make([]interface{}, len(t))
+		for i, child := range t {
+			out[i] = e.coordinates(child)
+		}
+		return out
+	}
+	return coords
+}
+
+// positions codifica una lista de posiciones; con Delta cada posición es la
+// diferencia con la anterior
+func (e *CoordinateEncoding) positions(list [][]float64) [][]float64 {
+	out := make([][]float64, len(list))
+	var previous []float64
+	for i, pos := range list {
+		out[i] = e.position(pos, previous)
+		if e.Delta && len(pos) >= 2 {
+			previous = []float64{e.quantize(pos[0]), e.quantize(pos[1])}
+		}
+	}
+	return out
+}
+
+// position codifica una posición. Con Delta, x e y se cuantizan y se restan de
+// la posición anterior (si existe); las coordenadas extra (altura) solo se redondean.
+func (e *CoordinateEncoding) position(pos, previous []float64) []float64 {
+	out := make([]float64, len(pos))
+	for i, value := range pos {
+		switch {
+		case e.Delta && i < 2:
+			out[i] = e.quantize(value)
+			if previous != nil {
+				out[i] -= previous[i]
+			}
+		default:
+			out[i] = e.round(value)
+		}
+	}
+	return out
+}
+
+// round redondea un valor a Precision decimales
+func (e *CoordinateEncoding) round(value float64) float64 {
+	scale := math.Pow10(e.Precision)
+	return math.Round(value*scale) / scale
+}
+
+// quantize convierte un valor al entero de la cuadrícula de Precision decimales
+func (e *CoordinateEncoding) quantize(value float64) float64 {
+	return math.Round(value * math.Pow10(e.Precision))
+}
+
+// decodedPosition convierte una posición decodificada desde JSON
+func decodedPosition(raw any) ([]float64, bool) {
+	list, ok := raw.([]interface{})
+	if !ok || len(list) < 2 {
+		return nil, false
+	}
+	pos := make([]float64, len(list))
+	for i, value := range list {
+		number, ok := value.(float64)
+		if !ok {
+			return nil, false
+		}
+		pos[i] = number
+	}
+	return pos, true
+}
+
+// MarshalJSON serializa el Feature con sus coordenadas codificadas según
+// Encoding. Con cuantización se agrega el miembro "transform".
+func (f GeoFeature) MarshalJSON() ([]byte, error) {
+	type plain GeoFeature
+	if f.Encoding == nil {
+		return json.Marshal(plain(f))
+	}
+
+	encoded := f
+	encoded.Geometry = f.Encoding.Geometry(f.Geometry)
+	return json.Marshal(struct {
+		plain
+		Transform *Transform `json:"transform,omitempty"`
+	}{plain(encoded), f.Encoding.Transform()})
+}
+
+// MarshalJSON serializa la colección con las coordenadas de todos sus
+// features codificadas según Encoding. Con cuantización se agrega el miembro
+// "transform" a la colección.
+func (fc GeoFeatureCollection) MarshalJSON() ([]byte, error) {
+	type plain GeoFeatureCollection
+	if fc.Encoding == nil {
+		return json.Marshal(plain(fc))
+	}
+
+	encoded := fc
+	encoded.Features = make([]GeoFeature, len(fc.Features))
+	for i, feature := range fc.Features {
+		encoded.Features[i] = feature
+		encoded.Features[i].Geometry = fc.Encoding.Geometry(feature.Geometry)
+		encoded.Features[i].Encoding = nil
+	}
+	return json.Marshal(struct {
+		plain
+		Transform *Transform `json:"transform,omitempty"`
+	}{plain(encoded), fc.Encoding.Transform()})
+}
@@ -0,0 +1,125 @@
+package types
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// encodedLine es un Feature LineString serializado con "transform"
+type encodedLine struct {
+	Geometry struct {
+		Coordinates [][]float64 `json:"coordinates"`
+	} `json:"geometry"`
+	Transform *Transform `json:"transform"`
+}
+
+// roundTrip serializa una línea con Delta y la decodifica con su transform
+// (x = Σdeltas × scale + translate). Retorna las posiciones decodificadas y las
+// cuantizadas (Σdeltas).
+func roundTrip(t *testing.T, geometry any, precision int) (decoded, quantized [][]float64) {
+	t.Helper()
+
+	feature := GeoFeature{
+		Type:     "Feature",
+		Geometry: geometry,
+		Encoding: &CoordinateEncoding{Precision: precision, Delta: true},
+	}
+	raw, err := json.Marshal(feature)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var out encodedLine
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("unmarshal %s: %v", raw, err)
+	}
+	if out.Transform == nil {
+		t.Fatalf("missing transform in %s", raw)
+	}
+
+	var x, y float64
+	for _, delta := range out.Geometry.Coordinates {
+		for _, v := range delta[:2] {
+			if v != math.Trunc(v) {
+				t.Fatalf("delta %v is not an integer", delta)
+			}
+		}
+		x, y = x+delta[0], y+delta[1]
+		quantized = append(quantized, []float64{x, y})
+		decoded = append(decoded, []float64{
+			x*out.Transform.Scale[0] + out.Transform.Translate[0],
+			y*out.Transform.Scale[1] + out.Transform.Translate[1],
+		})
+	}
+	return decoded, quantized
+}
+
+func assertRoundTrip(t *testing.T, line [][]float64, geometry any, precision int) {
+	t.Helper()
+
+	decoded, quantized := roundTrip(t, geometry, precision)
+	if len(decoded) != len(line) {
+		t.Fatalf("decoded %d positions, want %d", len(decoded), len(line))
+	}
+
+	scale := math.Pow10(precision)
+	for i, pos := range line {
+		for axis := 0; axis < 2; axis++ {
+			// Las sumas de deltas reproducen exactamente la cuadrícula
+			if want := math.Round(pos[axis] * scale); quantized[i][axis] != want {
+				t.Errorf("position %d axis %d: quantized %v, want %v", i, axis, quantized[i][axis], want)
+			}
+			if diff := math.Abs(decoded[i][axis] - pos[axis]); diff > 0.5/scale+1e-12 {
+				t.Errorf("position %d axis %d: decoded %v, want %v (±%g)", i, axis, decoded[i][axis], pos[axis], 0.5/scale)
+			}
+		}
+	}
+}
+
+func TestDeltaRoundTripDegrees(t *testing.T) {
+	line := [][]float64{
+		{-89.2182345678, 13.6929123456},
+		{-89.2182345612, 13.6929123499},
+		{-87.6834999999, 13.1550000001},
+		{-90.1283749201, 14.4450192837},
+		{-179.9999999, -89.9999999},
+		{180, 90},
+	}
+	geometry := map[string]interface{}{"type": "LineString", "coordinates": line}
+	assertRoundTrip(t, line, geometry, MaxDeltaPrecisionDegrees)
+}
+
+func TestDeltaRoundTripDecodedJSON(t *testing.T) {
+	line := [][]float64{{-89.2182345678, 13.6929123456}, {-88.1, 13.5}, {-89.2182345678, 13.6929123456}}
+
+	// Las geometrías decodificadas desde JSON usan []interface{}
+	var geometry map[string]interface{}
+	raw, _ := json.Marshal(map[string]interface{}{"type": "LineString", "coordinates": line})
+	if err := json.Unmarshal(raw, &geometry); err != nil {
+		t.Fatal(err)
+	}
+	assertRoundTrip(t, line, geometry, MaxDeltaPrecisionDegrees)
+}
+
+func TestDeltaRoundTripMeters(t *testing.T) {
+	// Coordenadas EPSG:5460 y UTM 16N de los extremos de El Salvador
+	line := [][]float64{
+		{500000.1234567, 295809.1845678},
+		{376543.9876543, 244321.0004999},
+		{793456.0005001, 1523456.9994999},
+		{166021.443, 1597654.321},
+	}
+	geometry := map[string]interface{}{"type": "LineString", "coordinates": line}
+	assertRoundTrip(t, line, geometry, MaxDeltaPrecisionMeters)
+}
+
+func TestQuantizedFitsInt32(t *testing.T) {
+	// Con la precisión máxima las coordenadas cuantizadas caben en 32 bits
+	if v := 180 * math.Pow10(MaxDeltaPrecisionDegrees); v > math.MaxInt32 {
+		t.Errorf("180° quantized to %g exceeds int32", v)
+	}
+	if v := 2e6 * math.Pow10(MaxDeltaPrecisionMeters); v > math.MaxInt32 {
+		t.Errorf("2000 km quantized to %g exceeds int32", v)
+	}
+}
@@ -1,6 +1,9 @@
 package types
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Transform contiene la escala y traslación para reconstruir las coordenadas.
 type Transform struct {
@@ -13,34 +16,102 @@ type TopoJSON struct {
 	Type      string                `json:"type"`
 	Objects   map[string]TopoObject `json:"objects"`
 	Arcs      [][][]float64         `json:"arcs"`
-	Transform Transform             `json:"transform"`
+	Transform Transform             `json:"transform,omitzero"`
 }
 
-// TopoObject agrupa las geometrías.
+// TopoObject agrupa las geometrías. Un objeto también puede ser una geometría
+// suelta, en cuyo caso usa los campos de Geometry en vez de Geometries.
 type TopoObject struct {
-	Type       string     `json:"type"`
-	Geometries []Geometry `json:"geometries"`
+	Type        string          `json:"type"`
+	Geometries  []Geometry      `json:"geometries"`
+	ID          string          `json:"id,omitempty"`
+	Arcs        json.RawMessage `json:"arcs,omitempty"`
+	Coordinates json.RawMessage `json:"coordinates,omitempty"`
+	Properties  map[string]any  `json:"properties,omitempty"`
 }
 
 // Geometry ahora incluye un campo "Coordinates" para puntos y multipuntos.
 type Geometry struct {
 	Type        string          `json:"type"`
+	ID          string          `json:"id,omitempty"`
 	Arcs        json.RawMessage `json:"arcs"`
 	Coordinates json.RawMessage `json:"coordinates,omitempty"`
 	Properties  map[string]any  `json:"properties"`
 }
 
+// UnmarshalJSON acepta IDs numéricos además de strings, como permite la
+// especificación de TopoJSON.
+func (g *Geometry) UnmarshalJSON(data []byte) error {
+	type plain Geometry
+	var raw struct {
+		plain
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*g = Geometry(raw.plain)
+	g.ID = rawID(raw.ID)
+	return nil
+}
+
+// UnmarshalJSON acepta IDs numéricos además de strings.
+func (o *TopoObject) UnmarshalJSON(data []byte) error {
+	type plain TopoObject
+	var raw struct {
+		plain
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*o = TopoObject(raw.plain)
+	o.ID = rawID(raw.ID)
+	return nil
+}
+
+// rawID convierte un ID de JSON (string o número) a string
+func rawID(raw json.RawMessage) string {
+	var id string
+	if json.Unmarshal(raw, &id) == nil {
+		return id
+	}
+	var number json.Number
+	if json.Unmarshal(raw, &number) == nil {
+		return number.String()
+	}
+	return ""
+}
+
 // GeoFeature representa un Feature de GeoJSON.
 type GeoFeature struct {
 	Type       string                 `json:"type"`
+	ID         string                 `json:"id,omitempty"`
 	Geometry   any                    `json:"geometry"`
 	Properties map[string]interface{} `json:"properties"`
+	Encoding   *CoordinateEncoding    `json:"-"` // nil: coordenadas completas
 }
 
 // GeoFeatureCollection representa una colección de Features (GeoJSON).
 type GeoFeatureCollection struct {
-	Type     string       `json:"type"`
-	Features []GeoFeature `json:"features"`
+	Type     string              `json:"type"`
+	CRS      *NamedCRS           `json:"crs,omitempty"` // solo en colecciones proyectadas
+	Features []GeoFeature        `json:"features"`
+	Encoding *CoordinateEncoding `json:"-"` // nil: coordenadas completas
+}
+
+// NamedCRS es el miembro "crs" de GeoJSON 2008. RFC 7946 lo eliminó porque
+// todo GeoJSON es WGS84, pero QGIS y GDAL lo siguen leyendo para identificar
+// coordenadas proyectadas.
+type NamedCRS struct {
+	Type       string            `json:"type"`
+	Properties map[string]string `json:"properties"`
+}
+
+// NewNamedCRS retorna el miembro "crs" para el nombre indicado
+// ("urn:ogc:def:crs:EPSG::5460")
+func NewNamedCRS(name string) *NamedCRS {
+	return &NamedCRS{Type: "name", Properties: map[string]string{"name": name}}
 }
 
 // GeoData contiene listas de departamentos, municipios y distritos.
@@ -48,4 +119,111 @@ type GeoData struct {
 	Departamentos []string `json:"departamentos"`
 	Municipios    []string `json:"municipios"`
 	Distritos     []string `json:"distritos"`
-}
\ No newline at end of file
+}
+
+// Ubicacion indica la unidad administrativa que contiene un punto. Si el punto
+// no cae dentro de ningún polígono (mar o fuera del territorio), Offshore es
+// true y los nombres corresponden al feature más cercano, o quedan vacíos si
+// está demasiado lejos para atribuírselo.
+type Ubicacion struct {
+	Departamento string  `json:"departamento"`
+	Municipio    string  `json:"municipio"`
+	Distrito     string  `json:"distrito"`
+	Offshore     bool    `json:"offshore"`
+	DistanciaKm  float64 `json:"distanciaKm,omitempty"`
+}
+
+// Niveles de la jerarquía administrativa
+const (
+	NivelDepartamento = "departamento"
+	NivelMunicipio    = "municipio"
+	NivelDistrito     = "distrito"
+)
+
+// AdminUnit es un nodo de la jerarquía administrativa departamento → municipio → distrito.
+// El ID es estable: el slug del nombre precedido por los IDs de sus ancestros
+// ("la-libertad.la-libertad-costa.isla-tasajera").
+type AdminUnit struct {
+	ID              string       `json:"id"`
+	Nombre          string       `json:"nombre"`
+	Nivel           string       `json:"nivel"` // "departamento", "municipio" o "distrito"
+	TotalMunicipios int          `json:"totalMunicipios,omitempty"`
+	TotalDistritos  int          `json:"totalDistritos,omitempty"`
+	Hijos           []*AdminUnit `json:"hijos,omitempty"`
+}
+
+// GeoHierarchy es el árbol administrativo completo con sus totales.
+type GeoHierarchy struct {
+	Departamentos      []*AdminUnit `json:"departamentos"`
+	TotalDepartamentos int          `json:"totalDepartamentos"`
+	TotalMunicipios    int          `json:"totalMunicipios"`
+	TotalDistritos     int          `json:"totalDistritos"`
+}
+
+// PlaceSuggestion es un resultado del autocompletado de lugares.
+type PlaceSuggestion struct {
+	ID           string `json:"id"`
+	Nombre       string `json:"nombre"`
+	Nivel        string `json:"nivel"`
+	Departamento string `json:"departamento,omitempty"` // ancestros, si los tiene
+	Municipio    string `json:"municipio,omitempty"`
+	Coincidencia string `json:"coincidencia"`        // "exacta", "prefijo", "palabra", "contiene" o "aproximada"
+	Distancia    int    `json:"distancia,omitempty"` // ediciones en coincidencias aproximadas
+}
+
+// Vecino es una unidad administrativa que comparte borde con otra del mismo nivel.
+type Vecino struct {
+	ID           string  `json:"id"`
+	Nombre       string  `json:"nombre"`
+	Nivel        string  `json:"nivel"`
+	Departamento string  `json:"departamento,omitempty"`
+	Municipio    string  `json:"municipio,omitempty"`
+	BordeKm      float64 `json:"bordeKm"` // longitud del borde compartido
+}
+
+// FeatureMetrics contiene las métricas geodésicas de un feature. Los puntos son [lon, lat].
+type FeatureMetrics struct {
+	AreaKm2     float64    `json:"area_km2"`
+	PerimeterKm float64    `json:"perimeter_km"`
+	Centroid    [2]float64 `json:"centroid"`
+	LabelPoint  [2]float64 `json:"label_point"` // punto interior más alejado del borde
+}
+
+// GeometryIssue describe un problema encontrado al validar la geometría de un feature.
+type GeometryIssue struct {
+	FeatureID string    `json:"featureId"`
+	Nombre    string    `json:"nombre,omitempty"`
+	Tipo      string    `json:"tipo"`      // arcs_invalidos, arc_invalido, anillo_abierto, ...
+	Severidad string    `json:"severidad"` // error o advertencia
+	Poligono  int       `json:"poligono"`
+	Anillo    int       `json:"anillo"`
+	Arc       *int      `json:"arc,omitempty"`
+	Punto     []float64 `json:"punto,omitempty"`
+	Detalle   string    `json:"detalle"`
+}
+
+// ValidationReport resume la validación de las geometrías al cargar el TopoJSON.
+type ValidationReport struct {
+	Version                 string          `json:"version"` // versión del dataset validado
+	TotalFeatures           int             `json:"totalFeatures"`
+	FeaturesValidos         int             `json:"featuresValidos"`
+	FeaturesInvalidos       int             `json:"featuresInvalidos"` // sin geometría
+	FeaturesConAdvertencias int             `json:"featuresConAdvertencias"`
+	PorTipo                 map[string]int  `json:"porTipo"`
+	Problemas               []GeometryIssue `json:"problemas"`
+	GeneradoEn              time.Time       `json:"generadoEn"`
+}
+
+// DatasetInfo describe una capa geográfica cargada desde ASSETS_DIR.
+type DatasetInfo struct {
+	Nombre            string    `json:"nombre"`
+	Archivo           string    `json:"archivo"`
+	Objeto            string    `json:"objeto,omitempty"` // objeto dentro de la topología
+	Formato           string    `json:"formato"`          // topojson o geojson
+	TiposGeometria    []string  `json:"tiposGeometria"`
+	TotalFeatures     int       `json:"totalFeatures"`
+	FeaturesInvalidos int       `json:"featuresInvalidos"`
+	Propiedades       []string  `json:"propiedades"`
+	BBox              []float64 `json:"bbox,omitempty"` // minLon, minLat, maxLon, maxLat
+	Version           string    `json:"version"`        // SHA-256 abreviado del archivo
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// diacritics mapea las letras acentuadas del español (y otras comunes) a su
+// forma sin tilde
+var diacritics = map[rune]rune{
+	'á': 'a', 'à': 'a', 'ä': 'a', 'â': 'a', 'ã': 'a',
+	'é': 'e', 'è': 'e', 'ë': 'e', 'ê': 'e',
+	'í': 'i', 'ì': 'i', 'ï': 'i', 'î': 'i',
+	'ó': 'o', 'ò': 'o', 'ö': 'o', 'ô': 'o', 'õ': 'o',
+	'ú': 'u', 'ù': 'u', 'ü': 'u', 'û': 'u',
+	'ñ': 'n', 'ç': 'c',
+}
+
+// NormalizeText convierte un texto a minúsculas, sin tildes y con los espacios
+// colapsados, para comparar nombres sin importar cómo se escribieron
+// ("AHUACHAPÁN" y "ahuachapan" son iguales).
+func NormalizeText(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+
+	space := false
+	for _, r := range strings.TrimSpace(text) {
+		r = unicode.ToLower(r)
+		if plain, ok := diacritics[r]; ok {
+			r = plain
+		}
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Slugify genera un identificador estable para URLs a partir de un nombre:
+// "San Francisco Menéndez" -> "san-francisco-menendez"
+func Slugify(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+
+	dash := false
+	for _, r := range NormalizeText(text) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
+
+// HierarchicalID genera el ID estable de una unidad administrativa uniendo con
+// puntos los slugs de sus niveles, del departamento hacia abajo:
+// HierarchicalID("LA LIBERTAD", "La Libertad Costa") -> "la-libertad.la-libertad-costa"
+func HierarchicalID(levels ...string) string {
+	parts := make([]string, len(levels))
+	for i, level := range levels {
+		parts[i] = Slugify(level)
+	}
+	return strings.Join(parts, ".")
+}
@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"math"
 	"regexp"
+	"strconv"
 	"strings"
 	"unicode/utf8"
 )
@@ -100,4 +102,33 @@ func SanitizeString(input string) string {
 	input = spaceRegex.ReplaceAllString(input, " ")
 	
 	return input
-}
\ No newline at end of file
+}
+
+// ValidateBBox valida un bounding box con formato "minLon,minLat,maxLon,maxLat"
+func ValidateBBox(bbox string) ([4]float64, bool) {
+	var result [4]float64
+
+	parts := strings.Split(strings.TrimSpace(bbox), ",")
+	if len(parts) != 4 {
+		return result, false
+	}
+
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return result, false
+		}
+		result[i] = value
+	}
+
+	return result, IsValidBBox(result)
+}
+
+// IsValidBBox verifica que un bounding box esté ordenado y dentro del rango WGS84
+func IsValidBBox(bbox [4]float64) bool {
+	minLon, minLat, maxLon, maxLat := bbox[0], bbox[1], bbox[2], bbox[3]
+	if minLon < -180 || maxLon > 180 || minLat < -90 || maxLat > 90 {
+		return false
+	}
+	return minLon <= maxLon && minLat <= maxLat
+}